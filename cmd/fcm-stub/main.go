@@ -0,0 +1,23 @@
+// cmd/fcm-stub runs a local server speaking the FCM v1 send API. Point the
+// notify service at it with FCM_ENDPOINT=http://localhost:9099 to run
+// end-to-end push tests without Firebase credentials.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"notify-service/internal/fcm"
+)
+
+func main() {
+	addr := os.Getenv("FCM_STUB_ADDR")
+	if addr == "" {
+		addr = ":9099"
+	}
+	log.Printf("🧪 FCM stub listening on %s (POST /v1/projects/{project}/messages:send, GET|DELETE /messages, POST /failures)", addr)
+	if err := http.ListenAndServe(addr, fcm.NewStub()); err != nil {
+		log.Fatalf("❌ FCM stub stopped: %v", err)
+	}
+}
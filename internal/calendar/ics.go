@@ -0,0 +1,160 @@
+// internal/calendar/ics.go
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Method is the iTIP method of the calendar object (RFC 5546).
+type Method string
+
+const (
+	MethodRequest Method = "REQUEST"
+	MethodCancel  Method = "CANCEL"
+)
+
+const (
+	prodID          = "-//MusterBox//notify-service//EN"
+	uidDomain       = "musterbox.org"
+	icsTimeLayout   = "20060102T150405Z"
+	maxLineOctets   = 75
+	DefaultDuration = time.Hour
+)
+
+// Event holds everything needed to render a single VEVENT.
+type Event struct {
+	UID            string
+	Sequence       int
+	Method         Method
+	Start          time.Time
+	Duration       time.Duration // defaults to DefaultDuration if 0
+	Summary        string
+	Description    string
+	URL            string // join link
+	OrganizerName  string
+	OrganizerEmail string
+	AttendeeEmail  string
+	Stamp          time.Time // defaults to time.Now() if zero
+}
+
+// MatchUID returns the stable UID for a match, so updates and cancellations
+// replace the same event in the user's calendar.
+func MatchUID(matchID string) string {
+	return fmt.Sprintf("match-%s@%s", strings.TrimSpace(matchID), uidDomain)
+}
+
+// Build renders the event as an RFC 5545 iCalendar object (CRLF line endings).
+func Build(e Event) string {
+	if e.Method == "" {
+		e.Method = MethodRequest
+	}
+	if e.Duration <= 0 {
+		e.Duration = DefaultDuration
+	}
+	if e.Stamp.IsZero() {
+		e.Stamp = time.Now()
+	}
+
+	status := "CONFIRMED"
+	if e.Method == MethodCancel {
+		status = "CANCELLED"
+	}
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:" + prodID,
+		"CALSCALE:GREGORIAN",
+		"METHOD:" + string(e.Method),
+		"BEGIN:VEVENT",
+		"UID:" + e.UID,
+		fmt.Sprintf("SEQUENCE:%d", e.Sequence),
+		"DTSTAMP:" + formatTime(e.Stamp),
+		"DTSTART:" + formatTime(e.Start),
+		"DTEND:" + formatTime(e.Start.Add(e.Duration)),
+		"SUMMARY:" + escapeText(e.Summary),
+		"STATUS:" + status,
+	}
+	if e.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+escapeText(e.Description))
+	}
+	if e.URL != "" {
+		lines = append(lines, "URL:"+e.URL, "LOCATION:"+escapeText(e.URL))
+	}
+	if e.OrganizerEmail != "" {
+		organizer := "ORGANIZER"
+		if e.OrganizerName != "" {
+			organizer += ";CN=" + quoteParam(e.OrganizerName)
+		}
+		lines = append(lines, organizer+":mailto:"+e.OrganizerEmail)
+	}
+	if e.AttendeeEmail != "" {
+		lines = append(lines, "ATTENDEE;ROLE=REQ-PARTICIPANT;RSVP=FALSE:mailto:"+e.AttendeeEmail)
+	}
+	if e.Method == MethodRequest {
+		lines = append(lines,
+			"BEGIN:VALARM",
+			"TRIGGER:-PT15M",
+			"ACTION:DISPLAY",
+			"DESCRIPTION:"+escapeText(e.Summary),
+			"END:VALARM",
+		)
+	}
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(foldLine(l))
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+// ContentType returns the MIME type for an invite with the given method.
+func ContentType(m Method) string {
+	return fmt.Sprintf("text/calendar; charset=UTF-8; method=%s", m)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(icsTimeLayout)
+}
+
+// escapeText escapes TEXT values per RFC 5545 §3.3.11.
+func escapeText(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+	return r.Replace(s)
+}
+
+func quoteParam(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "'") + `"`
+}
+
+// foldLine splits content lines longer than 75 octets (RFC 5545 §3.1),
+// never breaking inside a multi-byte UTF-8 sequence.
+func foldLine(line string) string {
+	if len(line) <= maxLineOctets {
+		return line
+	}
+	var b strings.Builder
+	limit := maxLineOctets
+	count := 0
+	for _, r := range line {
+		size := len(string(r))
+		if count+size > limit {
+			b.WriteString("\r\n ")
+			count = 0
+			limit = maxLineOctets - 1 // continuation lines start with a space
+		}
+		b.WriteRune(r)
+		count += size
+	}
+	return b.String()
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -31,6 +32,7 @@ type Config struct {
 	// Auth
 	ServiceExpectedToken string
 	AuthServiceURL       string // URL of the auth service for SSE validation or internal use
+	AuthServiceToken     string // token this service presents to the auth service (MS_SERVICE_TOKEN)
 
 	// R2 Storage
 	R2AccountID       string
@@ -45,6 +47,40 @@ type Config struct {
 	// User Sync
 	ProfileServiceURL string // <--- Keep profile service URL
 	// Remove: ProfileServiceToken (we'll use ServiceExpectedToken instead)
+
+	// Calendar invites
+	CalendarLinkBaseURL string // public (gateway) base for in-app .ics download links
+
+	// Push
+	PushDriver                 string // fcm | memory | none ("" = fcm when credentials are set, else none)
+	FCMEndpoint                string // FCM v1-compatible base URL (local stub); used without credentials
+	FCMProjectID               string // project ID sent to FCMEndpoint
+	FCMTokenInactivityDays     int    // tokens not refreshed for this many days are expired (0 = never)
+	SyncPushMinIntervalSeconds int    // per-device minimum gap between silent sync pushes (0 = no limit)
+	PushDefaultBrand           string // brand used when a notification has no metadata.brand
+	PushBrandsJSON             string // {"<brand>": {"icon_url","badge_url","color"}}, merged over built-in defaults
+
+	// Presence
+	PresenceWindowSeconds    int    // a foreground heartbeat counts as "active" for this long (0 = ignore presence)
+	PresenceMode             string // skip | downgrade (silent sync push) while the user is active
+	PresenceAlwaysPushTypes  string // comma-separated notification types pushed even while active
+	PresenceAlwaysPushEvents string // comma-separated system event keys pushed even while active
+
+	// Real-time stream (SSE) & delta sync
+	StreamMaxConnectionsPerUser int // open streams allowed per user (0 = unlimited)
+	StreamMaxConnections        int // open streams allowed per replica (0 = unlimited)
+	StreamHeartbeatSeconds      int // comment line sent this often to keep proxies from closing idle streams
+	InboxEventRetentionHours    int // how long streams and sync tokens can resume (0 = keep forever)
+
+	// Acknowledgements (defaults; a notification's ack_policy overrides them)
+	AckReminderIntervalHours int    // hours between reminders to recipients who haven't acknowledged
+	AckReminderMax           int    // reminders sent per recipient at most (0 = never remind)
+	AckReminderChannels      string // comma-separated: push, email
+
+	// SMS (escalation policies' sms steps)
+	SMSDriver       string // webhook | memory | none ("" = webhook when SMS_WEBHOOK_URL is set, else none)
+	SMSWebhookURL   string // gateway endpoint receiving {"to","body"}
+	SMSWebhookToken string // bearer token for the gateway
 }
 
 func Load() *Config {
@@ -80,6 +116,7 @@ func Load() *Config {
 
 		ServiceExpectedToken: getEnv("SERVICE_TOKEN", "your-secret-service-token"),
 		AuthServiceURL:       getEnv("AUTH_SERVICE_URL", "http://auth-service:8080"), // Add this line
+		AuthServiceToken:     os.Getenv("MS_SERVICE_TOKEN"),
 
 		// R2 Configuration
 		R2AccountID:       os.Getenv("R2_ACCOUNT_ID"),
@@ -94,6 +131,40 @@ func Load() *Config {
 		// User Sync Configuration
 		ProfileServiceURL: getEnv("PROFILE_SERVICE_URL", "http://localhost:3000"), // <--- Keep profile service URL
 		// Remove: ProfileServiceToken
+
+		// Calendar Configuration
+		CalendarLinkBaseURL: getEnv("CALENDAR_LINK_BASE_URL", "https://api.musterbox.org/v1/notify/s"),
+
+		// Push Configuration
+		PushDriver:                 os.Getenv("PUSH_DRIVER"),
+		FCMEndpoint:                os.Getenv("FCM_ENDPOINT"),
+		FCMProjectID:               getEnv("FCM_PROJECT_ID", "notify-stub"),
+		FCMTokenInactivityDays:     getEnvInt("FCM_TOKEN_INACTIVITY_DAYS", 60),
+		SyncPushMinIntervalSeconds: getEnvInt("SYNC_PUSH_MIN_INTERVAL_SECONDS", 30),
+		PushDefaultBrand:           getEnv("PUSH_DEFAULT_BRAND", "musterbox"),
+		PushBrandsJSON:             os.Getenv("PUSH_BRANDS_JSON"),
+
+		// Presence Configuration
+		PresenceWindowSeconds:    getEnvInt("PRESENCE_WINDOW_SECONDS", 60),
+		PresenceMode:             getEnvOneOf("PRESENCE_MODE", "downgrade", "skip", "downgrade"),
+		PresenceAlwaysPushTypes:  getEnv("PRESENCE_ALWAYS_PUSH_TYPES", "security"),
+		PresenceAlwaysPushEvents: os.Getenv("PRESENCE_ALWAYS_PUSH_EVENTS"),
+
+		// Real-time Stream Configuration
+		StreamMaxConnectionsPerUser: getEnvInt("SSE_MAX_CONNECTIONS_PER_USER", 5),
+		StreamMaxConnections:        getEnvInt("SSE_MAX_CONNECTIONS", 10000),
+		StreamHeartbeatSeconds:      getEnvInt("SSE_HEARTBEAT_SECONDS", 25),
+		InboxEventRetentionHours:    getEnvInt("INBOX_EVENT_RETENTION_HOURS", 168),
+
+		// Acknowledgement Configuration
+		AckReminderIntervalHours: getEnvInt("ACK_REMINDER_INTERVAL_HOURS", 24),
+		AckReminderMax:           getEnvInt("ACK_REMINDER_MAX", 3),
+		AckReminderChannels:      getEnv("ACK_REMINDER_CHANNELS", "push"),
+
+		// SMS Configuration
+		SMSDriver:       os.Getenv("SMS_DRIVER"),
+		SMSWebhookURL:   os.Getenv("SMS_WEBHOOK_URL"),
+		SMSWebhookToken: os.Getenv("SMS_WEBHOOK_TOKEN"),
 	}
 }
 
@@ -102,4 +173,31 @@ func getEnv(key, fallback string) string {
 		return value
 	}
 	return fallback
-}
\ No newline at end of file
+}
+
+// getEnvOneOf reads an enumerated setting; unknown values stop startup rather
+// than silently falling back.
+func getEnvOneOf(key, fallback string, allowed ...string) string {
+	value := getEnv(key, fallback)
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	log.Fatalf("❌ Invalid %s=%q: must be one of %s", key, value, strings.Join(allowed, ", "))
+	return ""
+}
+
+// getEnvInt reads a numeric setting; like getEnvOneOf (and SMTP_PORT), a
+// value that doesn't parse stops startup rather than silently falling back.
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("❌ Invalid %s=%q: must be an integer", key, value)
+	}
+	return i
+}
@@ -0,0 +1,134 @@
+// internal/email/schemas.go
+package email
+
+import (
+	"sort"
+	"strings"
+
+	"notify-service/internal/schema"
+)
+
+const (
+	currencyPattern = `^[A-Z]{3,5}$`
+	otpPattern      = `^\d{6}$`
+)
+
+// ContextSchemas declares the expected EmailRequest.Context per email type.
+// Transactional types wrap their fields in a "data" object.
+var ContextSchemas = map[string]*schema.Schema{
+	"email_verification": schema.Object(map[string]*schema.Schema{
+		"verify_url": schema.Formatted("uri"),
+	}, "verify_url"),
+
+	"password_reset": schema.Object(map[string]*schema.Schema{
+		"reset_link": schema.Formatted("uri"),
+	}, "reset_link"),
+
+	"otp": schema.Object(map[string]*schema.Schema{
+		"otp": schema.Matching(otpPattern),
+	}, "otp"),
+
+	"pin_recovery": schema.Object(map[string]*schema.Schema{
+		"otp": schema.Matching(otpPattern),
+	}, "otp"),
+
+	"new_login": dataObject(map[string]*schema.Schema{
+		"user_name":          schema.String(),
+		"timestamp":          schema.String(),
+		"ip_address":         schema.Formatted("ip"),
+		"device_os":          schema.String(),
+		"user_agent_snippet": {Type: "string"},
+	}, "user_name", "timestamp", "ip_address", "device_os"),
+
+	"deposit_detected": dataObject(map[string]*schema.Schema{
+		"user_name":   schema.String(),
+		"amount":      schema.Decimal(),
+		"currency":    schema.Matching(currencyPattern),
+		"new_balance": schema.Decimal(),
+		"txid":        schema.String(),
+		"timestamp":   schema.String(),
+		"logo_url":    schema.Formatted("uri"),
+		"year":        schema.Integer(),
+	}, "user_name", "amount", "currency", "new_balance", "txid", "timestamp"),
+
+	"withdraw_completed": dataObject(map[string]*schema.Schema{
+		"user_name":   schema.String(),
+		"amount":      schema.Decimal(),
+		"currency":    schema.Matching(currencyPattern),
+		"destination": schema.String(),
+		"txid":        schema.String(),
+		"fee_amount":  schema.Decimal(),
+		"timestamp":   schema.String(),
+		"logo_url":    schema.Formatted("uri"),
+		"year":        schema.Integer(),
+	}, "user_name", "amount", "currency", "destination", "txid", "fee_amount", "timestamp"),
+
+	"conversion_sol_to_fiat_completed": dataObject(map[string]*schema.Schema{
+		"user_name":      schema.String(),
+		"sol_amount":     schema.Decimal(),
+		"fiat_amount":    schema.Decimal(),
+		"fiat_currency":  schema.Matching(currencyPattern),
+		"fee_amount_sol": schema.Decimal(),
+		"exchange_rate":  schema.Decimal(),
+		"txid":           schema.String(),
+		"timestamp":      schema.String(),
+		"logo_url":       schema.Formatted("uri"),
+		"year":           schema.Integer(),
+	}, "user_name", "sol_amount", "fiat_amount", "fiat_currency", "fee_amount_sol", "exchange_rate", "txid", "timestamp"),
+
+	"conversion_fiat_to_sol_completed": dataObject(map[string]*schema.Schema{
+		"user_name":       schema.String(),
+		"fiat_amount":     schema.Decimal(),
+		"fiat_currency":   schema.Matching(currencyPattern),
+		"sol_amount":      schema.Decimal(),
+		"fee_amount_fiat": schema.Decimal(),
+		"exchange_rate":   schema.Decimal(),
+		"txid":            schema.String(),
+		"timestamp":       schema.String(),
+		"logo_url":        schema.Formatted("uri"),
+		"year":            schema.Integer(),
+	}, "user_name", "fiat_amount", "fiat_currency", "sol_amount", "fee_amount_fiat", "exchange_rate", "txid", "timestamp"),
+}
+
+// SupportedTypes returns the email types that have a declared schema, sorted.
+func SupportedTypes() []string {
+	types := make([]string, 0, len(ContextSchemas))
+	for t := range ContextSchemas {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// ValidateRequest checks the recipient address, type and context of an email
+// request against the declared schemas and returns every violation.
+func ValidateRequest(emailType, to string, context map[string]interface{}) []schema.Violation {
+	var violations []schema.Violation
+	violations = append(violations, schema.Formatted("email").Validate(to)...)
+	for i := range violations {
+		violations[i].Field = "to"
+	}
+
+	s, ok := ContextSchemas[emailType]
+	if !ok {
+		return append(violations, schema.Violation{
+			Field:   "type",
+			Rule:    "enum",
+			Message: "must be one of: " + strings.Join(SupportedTypes(), ", "),
+		})
+	}
+	if context == nil {
+		context = map[string]interface{}{}
+	}
+	for _, v := range s.Validate(context) {
+		v.Field = "context." + v.Field
+		violations = append(violations, v)
+	}
+	return violations
+}
+
+func dataObject(props map[string]*schema.Schema, required ...string) *schema.Schema {
+	return schema.Object(map[string]*schema.Schema{
+		"data": schema.Object(props, required...),
+	}, "data")
+}
@@ -4,6 +4,7 @@ package email
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"strings"
 	"time"
@@ -23,15 +24,42 @@ func NewSender(cfg *config.Config) *Sender {
 	return &Sender{cfg: cfg}
 }
 
+// Attachment is an in-memory file attached to an outgoing email.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
 func (s *Sender) Send(ctx context.Context, to, subject, body string) error {
+	return s.SendWithAttachments(ctx, to, subject, body)
+}
+
+// SendWithAttachments sends an HTML email with optional attachments.
+// text/calendar attachments are also added as an alternative part so mail
+// clients render them as an invite instead of a plain file.
+func (s *Sender) SendWithAttachments(ctx context.Context, to, subject, body string, attachments ...Attachment) error {
 	// Heavy logging — per your preference
-	log.Printf("📧 [SEND] To: %s | Subject: %s", to, subject)
+	log.Printf("📧 [SEND] To: %s | Subject: %s | Attachments: %d", to, subject, len(attachments))
 
 	m := gomail.NewMessage()
 	m.SetHeader("From", fmt.Sprintf("%s <%s>", s.cfg.SMTPFromName, s.cfg.SMTPFrom))
 	m.SetHeader("To", to)
 	m.SetHeader("Subject", subject)
 	m.SetBody("text/html", body)
+	for _, a := range attachments {
+		content := a.Content
+		if strings.HasPrefix(a.ContentType, "text/calendar") {
+			m.AddAlternative(a.ContentType, string(content))
+		}
+		m.Attach(a.Filename,
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(content)
+				return err
+			}),
+			gomail.SetHeader(map[string][]string{"Content-Type": {a.ContentType}}),
+		)
+	}
 
 	dialer := gomail.NewDialer(s.cfg.SMTPHost, s.cfg.SMTPPort, s.cfg.SMTPUser, s.cfg.SMTPPass)
 
@@ -0,0 +1,32 @@
+// notify-service/internal/email/templates/ack_reminder.go
+package templates
+
+import (
+	"html/template"
+	"strings"
+	"time"
+)
+
+var ackReminderTmpl = template.Must(template.New("ack_reminder").Parse(ackReminderHTML))
+
+type AckReminderData struct {
+	UserName  string
+	Heading   string
+	Title     string
+	Message   string
+	ActionURL string // content link of the notice, if any
+	LogoURL   string
+	Year      int
+}
+
+func RenderAckReminderEmail(data AckReminderData) (string, error) {
+	if data.Year == 0 {
+		data.Year = time.Now().Year()
+	}
+	if data.LogoURL == "" {
+		data.LogoURL = "https://www.musterbox.org/icon.png"
+	}
+	var buf strings.Builder
+	err := ackReminderTmpl.Execute(&buf, data)
+	return buf.String(), err
+}
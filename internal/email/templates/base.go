@@ -26,4 +26,13 @@ var withdrawCompletedHTML string
 var conversionSolToFiatHTML string
 
 //go:embed conversion_fiat_to_sol.html
-var conversionFiatToSolHTML string
\ No newline at end of file
+var conversionFiatToSolHTML string
+
+//go:embed match_invite.html
+var matchInviteHTML string
+
+//go:embed ack_reminder.html
+var ackReminderHTML string
+
+//go:embed escalation.html
+var escalationHTML string
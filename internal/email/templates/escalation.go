@@ -0,0 +1,32 @@
+// notify-service/internal/email/templates/escalation.go
+package templates
+
+import (
+	"html/template"
+	"strings"
+	"time"
+)
+
+var escalationTmpl = template.Must(template.New("escalation").Parse(escalationHTML))
+
+type EscalationData struct {
+	UserName  string
+	Heading   string
+	Title     string
+	Message   string
+	ActionURL string // content link of the notice, if any
+	LogoURL   string
+	Year      int
+}
+
+func RenderEscalationEmail(data EscalationData) (string, error) {
+	if data.Year == 0 {
+		data.Year = time.Now().Year()
+	}
+	if data.LogoURL == "" {
+		data.LogoURL = "https://www.musterbox.org/icon.png"
+	}
+	var buf strings.Builder
+	err := escalationTmpl.Execute(&buf, data)
+	return buf.String(), err
+}
@@ -0,0 +1,36 @@
+// notify-service/internal/email/templates/match_invite.go
+package templates
+
+import (
+	_ "embed"
+	"html/template"
+	"strings"
+	"time"
+)
+
+var matchInviteTmpl = template.Must(template.New("match_invite").Parse(matchInviteHTML))
+
+type MatchInviteData struct {
+	UserName        string
+	GameName        string
+	OpponentName    string
+	StartTime       string // human-readable start time
+	DurationMinutes int
+	JoinURL         string
+	Updated         bool // true when this replaces an earlier invite
+	Cancelled       bool
+	LogoURL         string
+	Year            int
+}
+
+func RenderMatchInviteEmail(data MatchInviteData) (string, error) {
+	if data.Year == 0 {
+		data.Year = time.Now().Year()
+	}
+	if data.LogoURL == "" {
+		data.LogoURL = "https://www.musterbox.org/icon.png"
+	}
+	var buf strings.Builder
+	err := matchInviteTmpl.Execute(&buf, data)
+	return buf.String(), err
+}
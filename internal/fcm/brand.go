@@ -0,0 +1,67 @@
+// internal/fcm/brand.go
+package fcm
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const defaultBrandName = "musterbox"
+
+// Branding is the per-brand look of a push: web icon/badge and Android accent color.
+type Branding struct {
+	IconURL  string `json:"icon_url"`
+	BadgeURL string `json:"badge_url"`
+	Color    string `json:"color"` // #RRGGBB
+}
+
+// Brands resolves a brand name (from notification metadata) to its branding.
+type Brands struct {
+	Default string
+	ByName  map[string]Branding
+}
+
+// DefaultBrands is used when no brand configuration is provided.
+func DefaultBrands() Brands {
+	return Brands{
+		Default: defaultBrandName,
+		ByName: map[string]Branding{
+			defaultBrandName: {
+				IconURL:  "https://www.musterbox.org/icon.png",
+				BadgeURL: "https://www.musterbox.org/icon.png",
+				Color:    "#7c3aed",
+			},
+		},
+	}
+}
+
+// ParseBrands decodes a JSON object of brand name → Branding (e.g. from
+// PUSH_BRANDS_JSON). Empty input returns DefaultBrands.
+func ParseBrands(defaultName, raw string) (Brands, error) {
+	brands := DefaultBrands()
+	if raw == "" {
+		return brands, nil
+	}
+	var byName map[string]Branding
+	if err := json.Unmarshal([]byte(raw), &byName); err != nil {
+		return brands, fmt.Errorf("invalid brands JSON: %w", err)
+	}
+	for name, b := range byName {
+		brands.ByName[name] = b
+	}
+	if defaultName != "" {
+		if _, ok := brands.ByName[defaultName]; !ok {
+			return brands, fmt.Errorf("default brand %q is not configured", defaultName)
+		}
+		brands.Default = defaultName
+	}
+	return brands, nil
+}
+
+// Resolve returns the branding for name, falling back to the default brand.
+func (b Brands) Resolve(name string) Branding {
+	if br, ok := b.ByName[name]; ok {
+		return br
+	}
+	return b.ByName[b.Default]
+}
@@ -3,9 +3,12 @@ package fcm
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
+	"time"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/messaging"
@@ -13,12 +16,32 @@ import (
 )
 
 type FCMClient struct {
-	client *messaging.Client
+	client      *messaging.Client
+	brands      Brands
+	iidEndpoint string // topic management override for a local stub ("" = Firebase SDK)
 }
 
-func NewFCMClient(ctx context.Context, credentialsJSON []byte) (*FCMClient, error) {
-	conf := &firebase.Config{}
-	app, err := firebase.NewApp(ctx, conf, option.WithCredentialsJSON(credentialsJSON))
+func NewFCMClient(ctx context.Context, credentialsJSON []byte, brands Brands) (*FCMClient, error) {
+	return newFCMClient(ctx, &firebase.Config{}, brands, option.WithCredentialsJSON(credentialsJSON))
+}
+
+// NewFCMClientForEndpoint talks to an FCM v1-compatible server without
+// credentials, e.g. a local Stub at http://localhost:9099.
+func NewFCMClientForEndpoint(ctx context.Context, baseURL, projectID string, brands Brands) (*FCMClient, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	client, err := newFCMClient(ctx, &firebase.Config{ProjectID: projectID}, brands,
+		option.WithEndpoint(baseURL+"/v1"),
+		option.WithoutAuthentication(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	client.iidEndpoint = baseURL + "/iid/v1"
+	return client, nil
+}
+
+func newFCMClient(ctx context.Context, conf *firebase.Config, brands Brands, opts ...option.ClientOption) (*FCMClient, error) {
+	app, err := firebase.NewApp(ctx, conf, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("firebase init failed: %w", err)
 	}
@@ -28,7 +51,7 @@ func NewFCMClient(ctx context.Context, credentialsJSON []byte) (*FCMClient, erro
 		return nil, fmt.Errorf("messaging client init failed: %w", err)
 	}
 
-	return &FCMClient{client: messagingClient}, nil
+	return &FCMClient{client: messagingClient, brands: brands}, nil
 }
 
 // convertDataToStringMap safely converts map[string]interface{} → map[string]string
@@ -51,100 +74,269 @@ func convertDataToStringMap(data map[string]interface{}) map[string]string {
 	return result
 }
 
-func intPtr(i int) *int {
-	return &i
-}
-
-func (f *FCMClient) SendToToken(ctx context.Context, token string, title, body string, data map[string]interface{}) error {
-    stringData := convertDataToStringMap(data)
-    
-    // Ensure click_action is in data for Android
-    if _, hasClickAction := stringData["click_action"]; !hasClickAction {
-        stringData["click_action"] = "OPEN_NOTIFICATION"
-    }
-
-    badge := intPtr(1)
-
-    message := &messaging.Message{
-        Token: token,
-        Notification: &messaging.Notification{
-            Title: title,
-            Body:  body,
-        },
-        Data: stringData,
-        APNS: &messaging.APNSConfig{
-            Payload: &messaging.APNSPayload{
-                Aps: &messaging.Aps{
-                    Sound: "default",
-                    Badge: badge,
-                },
-            },
-        },
-        Android: &messaging.AndroidConfig{
-            Notification: &messaging.AndroidNotification{
-                Sound: "default",
-                // Android uses click_action in data payload
-            },
-            Priority: "high",
-        },
-        Webpush: &messaging.WebpushConfig{
-            Notification: &messaging.WebpushNotification{
-                Icon: "https://your-app.com/icon.png",
-                Badge: "https://your-app.com/badge.png",
-                Actions: []*messaging.WebpushNotificationAction{
-                    {
-                        Action: "open",
-                        Title:  "View",
-                    },
-                },
-            },
-        },
-    }
-
-    resp, err := f.client.Send(ctx, message)
-    if err != nil {
-        return fmt.Errorf("FCM send failed: %w", err)
-    }
-    log.Printf("✅ FCM sent to %s → msg ID: %s", maskToken(token), resp)
-    return nil
-}
-
-func (f *FCMClient) SendToMultipleTokens(ctx context.Context, tokens []string, title, body string, data map[string]interface{}) error {
-	if len(tokens) == 0 {
-		return nil
+// Action is a push action button (from a notification's ActionLinks).
+type Action struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Options are per-push delivery settings. Zero values keep the FCM defaults
+// we always used: high priority, default sound, no expiry or collapsing.
+type Options struct {
+	ChannelID         string         // Android notification channel
+	Priority          string         // "high" (default) | "normal"
+	TTL               *time.Duration // nil = FCM default (4 weeks)
+	CollapseKey       string         // Android collapse_key, apns-collapse-id, Web Push Topic
+	Sound             string         // "" = default, "none" = no sound
+	InterruptionLevel string         // iOS 15+: passive | active | time-sensitive | critical
+}
+
+func (o Options) priority() string {
+	if o.Priority == "normal" {
+		return "normal"
 	}
+	return "high"
+}
 
-	stringData := convertDataToStringMap(data)
-	badge := intPtr(1)
+func (o Options) sound() string {
+	switch o.Sound {
+	case "":
+		return "default"
+	case "none":
+		return ""
+	}
+	return o.Sound
+}
 
-	var messages []*messaging.Message
-	for _, token := range tokens {
-		messages = append(messages, &messaging.Message{
+// Payload is what gets pushed to each token.
+type Payload struct {
+	Title string
+	Body  string
+	Data  map[string]interface{}
+	// Badge is the app icon badge (the user's unread count); nil leaves it unchanged.
+	Badge *int
+	// Silent sends a data-only, content-available push: nothing is shown,
+	// the app wakes up in the background to sync state.
+	Silent bool
+	// ContentAvailable also wakes the app in the background for a visible push.
+	ContentAvailable bool
+
+	// Rich content
+	ImageURL string   // big picture (Android/web), fetched by the iOS service extension
+	Link     string   // deep link opened on tap
+	Actions  []Action // action buttons
+	Brand    string   // selects icon/badge/color; "" = default brand
+
+	Options Options
+}
+
+// buildMessage maps a payload onto the per-platform FCM message for one token.
+func (f *FCMClient) buildMessage(token string, p Payload) *messaging.Message {
+	stringData := convertDataToStringMap(p.Data)
+
+	if p.Silent {
+		return &messaging.Message{
 			Token: token,
-			Notification: &messaging.Notification{
-				Title: title,
-				Body:  body,
-			},
-			Data: stringData, // ✅ reused (immutable safe)
+			Data:  stringData,
 			APNS: &messaging.APNSConfig{
+				Headers: map[string]string{
+					"apns-push-type": "background",
+					"apns-priority":  "5",
+				},
 				Payload: &messaging.APNSPayload{
 					Aps: &messaging.Aps{
-						Sound: "default",
-						Badge: badge,
+						ContentAvailable: true,
+						Badge:            p.Badge,
 					},
 				},
 			},
 			Android: &messaging.AndroidConfig{
-				Notification: &messaging.AndroidNotification{
-					Sound: "default",
-				},
-				Priority: "high",
+				Priority: "normal",
 			},
-		})
+		}
+	}
+
+	brand := f.brands.Resolve(p.Brand)
+
+	// Ensure click_action is in data for Android
+	if _, hasClickAction := stringData["click_action"]; !hasClickAction {
+		stringData["click_action"] = "OPEN_NOTIFICATION"
+	}
+	// Clients read rich fields from data as well (Android data handling, iOS extension)
+	if p.Link != "" {
+		stringData["link"] = p.Link
+	}
+	if p.ImageURL != "" {
+		stringData["image_url"] = p.ImageURL
+	}
+	if len(p.Actions) > 0 {
+		if b, err := json.Marshal(p.Actions); err == nil {
+			stringData["actions"] = string(b)
+		}
+	}
+
+	opts := p.Options
+	androidNotification := &messaging.AndroidNotification{
+		Sound:     opts.sound(),
+		ImageURL:  p.ImageURL,
+		Color:     brand.Color,
+		ChannelID: opts.ChannelID,
+		Tag:       opts.CollapseKey, // replaces the shown notification too, not just the queued one
+		// Android uses click_action in data payload
+	}
+	if p.Badge != nil {
+		androidNotification.NotificationCount = p.Badge
+	}
+
+	aps := &messaging.Aps{
+		Sound:            opts.sound(),
+		Badge:            p.Badge,
+		MutableContent:   p.ImageURL != "" || len(p.Actions) > 0,
+		ContentAvailable: p.ContentAvailable,
+	}
+	if opts.InterruptionLevel != "" {
+		aps.CustomData = map[string]interface{}{"interruption-level": opts.InterruptionLevel}
+	}
+	if len(p.Actions) > 0 {
+		// Categories are registered in the iOS app per button count
+		aps.Category = fmt.Sprintf("ACTIONS_%d", len(p.Actions))
+	}
+	apnsHeaders := map[string]string{"apns-priority": "10"}
+	if opts.priority() == "normal" {
+		apnsHeaders["apns-priority"] = "5"
+	}
+	if opts.TTL != nil {
+		expiration := int64(0)
+		if *opts.TTL > 0 {
+			expiration = time.Now().Add(*opts.TTL).Unix()
+		}
+		apnsHeaders["apns-expiration"] = strconv.FormatInt(expiration, 10)
+	}
+	if opts.CollapseKey != "" {
+		apnsHeaders["apns-collapse-id"] = opts.CollapseKey
+	}
+	apns := &messaging.APNSConfig{
+		Headers: apnsHeaders,
+		Payload: &messaging.APNSPayload{Aps: aps},
+	}
+	if p.ImageURL != "" {
+		apns.FCMOptions = &messaging.APNSFCMOptions{ImageURL: p.ImageURL}
+	}
+
+	webActions := []*messaging.WebpushNotificationAction{
+		{
+			Action: "open",
+			Title:  "View",
+		},
+	}
+	if len(p.Actions) > 0 {
+		webActions = webActions[:0]
+		for _, a := range p.Actions {
+			webActions = append(webActions, &messaging.WebpushNotificationAction{Action: a.ID, Title: a.Title})
+		}
+	}
+	webHeaders := map[string]string{"Urgency": opts.priority()}
+	if opts.TTL != nil {
+		webHeaders["TTL"] = strconv.Itoa(int(opts.TTL.Seconds()))
+	}
+	if topic := webPushTopic(opts.CollapseKey); topic != "" {
+		webHeaders["Topic"] = topic
+	}
+	webpush := &messaging.WebpushConfig{
+		Headers: webHeaders,
+		Notification: &messaging.WebpushNotification{
+			Title:   p.Title,
+			Body:    p.Body,
+			Icon:    brand.IconURL,
+			Badge:   brand.BadgeURL,
+			Image:   p.ImageURL,
+			Actions: webActions,
+		},
+	}
+	// FCM only accepts HTTPS links here; custom-scheme deep links stay in data
+	if strings.HasPrefix(p.Link, "https://") {
+		webpush.FCMOptions = &messaging.WebpushFCMOptions{Link: p.Link}
+	}
+
+	return &messaging.Message{
+		Token: token,
+		Notification: &messaging.Notification{
+			Title:    p.Title,
+			Body:     p.Body,
+			ImageURL: p.ImageURL,
+		},
+		Data: stringData,
+		APNS: apns,
+		Android: &messaging.AndroidConfig{
+			Notification: androidNotification,
+			Priority:     opts.priority(),
+			TTL:          opts.TTL,
+			CollapseKey:  opts.CollapseKey,
+		},
+		Webpush: webpush,
+	}
+}
+
+func (f *FCMClient) SendToToken(ctx context.Context, token string, p Payload) error {
+	message := f.buildMessage(token, p)
+
+	resp, err := f.client.Send(ctx, message)
+	if err != nil {
+		return fmt.Errorf("FCM send failed: %w", err)
+	}
+	log.Printf("✅ FCM sent to %s → msg ID: %s", maskToken(token), resp)
+	return nil
+}
+
+// Reasons a token is permanently rejected by FCM and should be pruned.
+const (
+	ReasonUnregistered   = "unregistered"
+	ReasonInvalidToken   = "invalid_token"
+	ReasonSenderMismatch = "sender_id_mismatch"
+)
+
+// TokenFailure is a token FCM rejected permanently; retrying it is pointless.
+type TokenFailure struct {
+	Token  string
+	Reason string
+}
+
+// InvalidTokenReason classifies a per-token send error. It returns "" for
+// transient or non-token errors (quota, unavailable, internal...).
+// INVALID_ARGUMENT only counts when FCM blames the registration token: it is
+// also returned for bad payloads (oversize data, image URL, APNs headers),
+// which say nothing about the token.
+func InvalidTokenReason(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case messaging.IsUnregistered(err):
+		return ReasonUnregistered
+	case messaging.IsSenderIDMismatch(err):
+		return ReasonSenderMismatch
+	case messaging.IsInvalidArgument(err) && strings.Contains(strings.ToLower(err.Error()), "registration token"):
+		return ReasonInvalidToken
+	default:
+		return ""
+	}
+}
+
+// SendToMultipleTokens sends to every token and returns the tokens FCM
+// rejected permanently so the caller can prune them.
+func (f *FCMClient) SendToMultipleTokens(ctx context.Context, tokens []string, p Payload) ([]TokenFailure, error) {
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	var messages []*messaging.Message
+	for _, token := range tokens {
+		messages = append(messages, f.buildMessage(token, p))
 	}
 
 	// Send in batches of up to 500 (FCM SendEach limit)
 	const batchSize = 500
+	var failures []TokenFailure
 	for i := 0; i < len(messages); i += batchSize {
 		end := i + batchSize
 		if end > len(messages) {
@@ -154,18 +346,40 @@ func (f *FCMClient) SendToMultipleTokens(ctx context.Context, tokens []string, t
 		batch := messages[i:end]
 		resp, err := f.client.SendEach(ctx, batch)
 		if err != nil {
-			return fmt.Errorf("FCM batch[%d:%d] failed: %w", i, end, err)
+			return failures, fmt.Errorf("FCM batch[%d:%d] failed: %w", i, end, err)
 		}
 
 		for j, r := range resp.Responses {
-			if !r.Success {
-				log.Printf("⚠️ FCM token %s (idx %d in batch %d) failed: %v",
-					maskToken(tokens[i+j]), j, i, r.Error)
+			if r.Success {
+				continue
+			}
+			log.Printf("⚠️ FCM token %s (idx %d in batch %d) failed: %v",
+				maskToken(tokens[i+j]), j, i, r.Error)
+			if reason := InvalidTokenReason(r.Error); reason != "" {
+				failures = append(failures, TokenFailure{Token: tokens[i+j], Reason: reason})
 			}
 		}
 	}
 
-	return nil
+	return failures, nil
+}
+
+// webPushTopic turns a collapse key into a Web Push Topic header, which only
+// allows up to 32 URL-safe base64 characters.
+func webPushTopic(collapseKey string) string {
+	var b strings.Builder
+	for _, r := range collapseKey {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+		if b.Len() == 32 {
+			break
+		}
+	}
+	return b.String()
 }
 
 // maskToken hides all but last 6 chars for logging safety
@@ -0,0 +1,182 @@
+// internal/fcm/sender.go
+package fcm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// PushSender delivers pushes to device tokens. FCMClient talks to Firebase
+// (or a local stub); Recorder keeps everything in memory.
+type PushSender interface {
+	SendToToken(ctx context.Context, token string, p Payload) error
+	SendToMultipleTokens(ctx context.Context, tokens []string, p Payload) ([]TokenFailure, error)
+	SendToTopic(ctx context.Context, topic string, p Payload) error
+	SendToCondition(ctx context.Context, condition string, p Payload) error
+	SubscribeToTopic(ctx context.Context, tokens []string, topic string) ([]TokenFailure, error)
+	UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) ([]TokenFailure, error)
+}
+
+var (
+	_ PushSender = (*FCMClient)(nil)
+	_ PushSender = (*Recorder)(nil)
+)
+
+// RecordedPush is one message captured by a Recorder.
+type RecordedPush struct {
+	Token     string             `json:"token,omitempty"`
+	Topic     string             `json:"topic,omitempty"`
+	Condition string             `json:"condition,omitempty"`
+	Payload   Payload            `json:"payload"`
+	Message   *messaging.Message `json:"message"` // exactly what FCMClient would send
+	SentAt    time.Time          `json:"sent_at"`
+}
+
+// Recorder is an in-memory PushSender for tests and offline environments.
+// Tokens can be marked as failing to exercise pruning.
+type Recorder struct {
+	mu       sync.Mutex
+	builder  *FCMClient
+	sent     []RecordedPush
+	failures map[string]string          // token -> Reason*
+	topics   map[string]map[string]bool // topic -> subscribed tokens
+}
+
+// NewRecorder returns an empty recorder rendering messages with the given brands.
+func NewRecorder(brands Brands) *Recorder {
+	return &Recorder{
+		builder:  &FCMClient{brands: brands},
+		failures: make(map[string]string),
+		topics:   make(map[string]map[string]bool),
+	}
+}
+
+// FailToken makes every later send to token fail with reason (one of the Reason* constants).
+func (r *Recorder) FailToken(token, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[token] = reason
+}
+
+// Sent returns a copy of everything recorded so far.
+func (r *Recorder) Sent() []RecordedPush {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedPush(nil), r.sent...)
+}
+
+// SentTo returns the pushes recorded for one token.
+func (r *Recorder) SentTo(token string) []RecordedPush {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []RecordedPush
+	for _, p := range r.sent {
+		if p.Token == token {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Reset forgets recorded pushes and configured failures.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = nil
+	r.failures = make(map[string]string)
+	r.topics = make(map[string]map[string]bool)
+}
+
+// Subscribers returns the tokens currently subscribed to topic.
+func (r *Recorder) Subscribers(topic string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tokens []string
+	for t := range r.topics[topic] {
+		tokens = append(tokens, t)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+func (r *Recorder) SendToToken(ctx context.Context, token string, p Payload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if reason, ok := r.failures[token]; ok {
+		return fmt.Errorf("FCM send failed: %s", reason)
+	}
+	r.record(token, p)
+	return nil
+}
+
+func (r *Recorder) SendToMultipleTokens(ctx context.Context, tokens []string, p Payload) ([]TokenFailure, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var failures []TokenFailure
+	for _, token := range tokens {
+		if reason, ok := r.failures[token]; ok {
+			failures = append(failures, TokenFailure{Token: token, Reason: reason})
+			continue
+		}
+		r.record(token, p)
+	}
+	return failures, nil
+}
+
+func (r *Recorder) SendToTopic(ctx context.Context, topic string, p Payload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msg := r.builder.buildMessage("", p)
+	msg.Topic = topic
+	r.sent = append(r.sent, RecordedPush{Topic: topic, Payload: p, Message: msg, SentAt: time.Now()})
+	return nil
+}
+
+func (r *Recorder) SendToCondition(ctx context.Context, condition string, p Payload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msg := r.builder.buildMessage("", p)
+	msg.Condition = condition
+	r.sent = append(r.sent, RecordedPush{Condition: condition, Payload: p, Message: msg, SentAt: time.Now()})
+	return nil
+}
+
+func (r *Recorder) SubscribeToTopic(ctx context.Context, tokens []string, topic string) ([]TokenFailure, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var failures []TokenFailure
+	for _, token := range tokens {
+		if reason, ok := r.failures[token]; ok {
+			failures = append(failures, TokenFailure{Token: token, Reason: reason})
+			continue
+		}
+		if r.topics[topic] == nil {
+			r.topics[topic] = make(map[string]bool)
+		}
+		r.topics[topic][token] = true
+	}
+	return failures, nil
+}
+
+func (r *Recorder) UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) ([]TokenFailure, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range tokens {
+		delete(r.topics[topic], token)
+	}
+	return nil, nil
+}
+
+func (r *Recorder) record(token string, p Payload) {
+	r.sent = append(r.sent, RecordedPush{
+		Token:   token,
+		Payload: p,
+		Message: r.builder.buildMessage(token, p),
+		SentAt:  time.Now(),
+	})
+}
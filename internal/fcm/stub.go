@@ -0,0 +1,293 @@
+// internal/fcm/stub.go
+package fcm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StubMessage is one request received by Stub.
+type StubMessage struct {
+	Name         string          `json:"name"`
+	Project      string          `json:"project"`
+	Token        string          `json:"token,omitempty"`
+	Topic        string          `json:"topic,omitempty"`
+	Condition    string          `json:"condition,omitempty"`
+	ValidateOnly bool            `json:"validate_only,omitempty"`
+	Message      json.RawMessage `json:"message"`
+	ReceivedAt   time.Time       `json:"received_at"`
+}
+
+// Stub is a local HTTP server speaking the FCM v1 send API
+// (POST /v1/projects/{project}/messages:send), so the real Firebase client can
+// be pointed at it with FCM_ENDPOINT. Tokens can be made to fail with FCM
+// error codes (UNREGISTERED, SENDER_ID_MISMATCH, INVALID_ARGUMENT, UNAVAILABLE...).
+//
+// Topic management (POST /iid/v1:batchAdd, /iid/v1:batchRemove) is served
+// too; see NewFCMClientForEndpoint.
+//
+// Inspection endpoints for end-to-end tests:
+//
+//	GET    /messages           recorded messages (?token= / ?topic= filter)
+//	DELETE /messages           reset messages, failures and subscriptions
+//	POST   /failures           {"token": "...", "error_code": "UNREGISTERED"}
+//	GET    /topics             topic -> subscribed tokens
+type Stub struct {
+	mu       sync.Mutex
+	seq      int
+	messages []StubMessage
+	failures map[string]string          // token -> FCM error code
+	topics   map[string]map[string]bool // topic -> subscribed tokens
+}
+
+// maxDataBytes is FCM's limit on a message's data payload.
+const maxDataBytes = 4096
+
+// NewStub returns an empty stub.
+func NewStub() *Stub {
+	return &Stub{failures: make(map[string]string), topics: make(map[string]map[string]bool)}
+}
+
+// Topics returns the current subscriptions, topic -> sorted tokens.
+func (s *Stub) Topics() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string][]string, len(s.topics))
+	for topic, tokens := range s.topics {
+		list := make([]string, 0, len(tokens))
+		for t := range tokens {
+			list = append(list, t)
+		}
+		sort.Strings(list)
+		out[topic] = list
+	}
+	return out
+}
+
+// FailToken makes sends to token fail with an FCM v1 error code.
+func (s *Stub) FailToken(token, errorCode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[token] = errorCode
+}
+
+// Messages returns a copy of everything received so far.
+func (s *Stub) Messages() []StubMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]StubMessage(nil), s.messages...)
+}
+
+// Reset forgets received messages and configured failures.
+func (s *Stub) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+	s.failures = make(map[string]string)
+	s.topics = make(map[string]map[string]bool)
+}
+
+func (s *Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/messages" && r.Method == http.MethodGet:
+		token, topic := r.URL.Query().Get("token"), r.URL.Query().Get("topic")
+		out := []StubMessage{}
+		for _, m := range s.Messages() {
+			if (token == "" || m.Token == token) && (topic == "" || m.Topic == topic) {
+				out = append(out, m)
+			}
+		}
+		writeJSON(w, http.StatusOK, out)
+	case r.URL.Path == "/topics" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.Topics())
+	case r.Method == http.MethodPost && (r.URL.Path == "/iid/v1:batchAdd" || r.URL.Path == "/iid/v1:batchRemove"):
+		s.handleTopicManagement(w, r, strings.HasSuffix(r.URL.Path, "batchAdd"))
+	case r.URL.Path == "/messages" && r.Method == http.MethodDelete:
+		s.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/failures" && r.Method == http.MethodPost:
+		var req struct {
+			Token     string `json:"token"`
+			ErrorCode string `json:"error_code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" || req.ErrorCode == "" {
+			writeFCMError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "token and error_code are required", "")
+			return
+		}
+		s.FailToken(req.Token, req.ErrorCode)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/messages:send"):
+		s.handleSend(w, r)
+	default:
+		writeFCMError(w, http.StatusNotFound, "NOT_FOUND", "unknown endpoint "+r.URL.Path, "")
+	}
+}
+
+func (s *Stub) handleSend(w http.ResponseWriter, r *http.Request) {
+	// /v1/projects/{project}/messages:send
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 4 || parts[0] != "v1" || parts[1] != "projects" {
+		writeFCMError(w, http.StatusNotFound, "NOT_FOUND", "unknown endpoint "+r.URL.Path, "")
+		return
+	}
+	project := parts[2]
+
+	var req struct {
+		ValidateOnly bool            `json:"validate_only"`
+		Message      json.RawMessage `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Message) == 0 {
+		writeFCMError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "request must contain a message", "INVALID_ARGUMENT")
+		return
+	}
+	var target struct {
+		Token     string            `json:"token"`
+		Topic     string            `json:"topic"`
+		Condition string            `json:"condition"`
+		Data      map[string]string `json:"data"`
+	}
+	_ = json.Unmarshal(req.Message, &target)
+	if target.Token == "" && target.Topic == "" && target.Condition == "" {
+		writeFCMError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "message must specify a token, topic or condition", "INVALID_ARGUMENT")
+		return
+	}
+	// Like FCM, reject oversize data as a payload error that doesn't blame the token
+	size := 0
+	for k, v := range target.Data {
+		size += len(k) + len(v)
+	}
+	if size > maxDataBytes {
+		writeFCMError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Message is too big", "INVALID_ARGUMENT")
+		return
+	}
+
+	s.mu.Lock()
+	if code, ok := s.failures[target.Token]; ok && target.Token != "" {
+		s.mu.Unlock()
+		writeFCMError(w, statusForErrorCode(code), statusNameForErrorCode(code), messageForErrorCode(code), code)
+		return
+	}
+	s.seq++
+	name := fmt.Sprintf("projects/%s/messages/stub-%d", project, s.seq)
+	s.messages = append(s.messages, StubMessage{
+		Name:         name,
+		Project:      project,
+		Token:        target.Token,
+		Topic:        target.Topic,
+		Condition:    target.Condition,
+		ValidateOnly: req.ValidateOnly,
+		Message:      req.Message,
+		ReceivedAt:   time.Now(),
+	})
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]string{"name": name})
+}
+
+func (s *Stub) handleTopicManagement(w http.ResponseWriter, r *http.Request, add bool) {
+	var req struct {
+		To     string   `json:"to"`
+		Tokens []string `json:"registration_tokens"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.To == "" || len(req.Tokens) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "INVALID_ARGUMENT"})
+		return
+	}
+	topic := strings.TrimPrefix(req.To, "/topics/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]map[string]string, len(req.Tokens))
+	for i, token := range req.Tokens {
+		results[i] = map[string]string{}
+		if code, ok := s.failures[token]; ok {
+			if code == "UNREGISTERED" {
+				code = "NOT_FOUND"
+			}
+			results[i]["error"] = code
+			continue
+		}
+		if add {
+			if s.topics[topic] == nil {
+				s.topics[topic] = make(map[string]bool)
+			}
+			s.topics[topic][token] = true
+		} else {
+			delete(s.topics[topic], token)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// statusForErrorCode maps FCM v1 error codes to the HTTP status FCM returns.
+func statusForErrorCode(code string) int {
+	switch code {
+	case "UNREGISTERED":
+		return http.StatusNotFound
+	case "SENDER_ID_MISMATCH", "THIRD_PARTY_AUTH_ERROR":
+		return http.StatusForbidden
+	case "QUOTA_EXCEEDED":
+		return http.StatusTooManyRequests
+	case "UNAVAILABLE":
+		return http.StatusServiceUnavailable
+	case "INTERNAL":
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func statusNameForErrorCode(code string) string {
+	switch code {
+	case "UNREGISTERED":
+		return "NOT_FOUND"
+	case "SENDER_ID_MISMATCH", "THIRD_PARTY_AUTH_ERROR":
+		return "PERMISSION_DENIED"
+	case "QUOTA_EXCEEDED":
+		return "RESOURCE_EXHAUSTED"
+	case "UNAVAILABLE", "INTERNAL":
+		return code
+	default:
+		return "INVALID_ARGUMENT"
+	}
+}
+
+// messageForErrorCode is the error message FCM sends with a per-token code.
+// INVALID_ARGUMENT carries FCM's registration token wording, which is what
+// InvalidTokenReason keys on.
+func messageForErrorCode(code string) string {
+	switch code {
+	case "UNREGISTERED":
+		return "Requested entity was not found."
+	case "INVALID_ARGUMENT":
+		return "The registration token is not a valid FCM registration token"
+	default:
+		return "stubbed failure: " + code
+	}
+}
+
+func writeFCMError(w http.ResponseWriter, status int, statusName, message, errorCode string) {
+	body := map[string]interface{}{
+		"code":    status,
+		"message": message,
+		"status":  statusName,
+	}
+	if errorCode != "" {
+		body["details"] = []map[string]string{{
+			"@type":     "type.googleapis.com/google.firebase.fcm.v1.FcmError",
+			"errorCode": errorCode,
+		}}
+	}
+	writeJSON(w, status, map[string]interface{}{"error": body})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
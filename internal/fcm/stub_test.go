@@ -0,0 +1,112 @@
+package fcm
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newStubClient(t *testing.T) (*Stub, *FCMClient) {
+	t.Helper()
+	stub := NewStub()
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	client, err := NewFCMClientForEndpoint(context.Background(), srv.URL, "stub-project", DefaultBrands())
+	if err != nil {
+		t.Fatalf("NewFCMClientForEndpoint: %v", err)
+	}
+	return stub, client
+}
+
+func TestStubSendErrorMapping(t *testing.T) {
+	tests := []struct {
+		name       string
+		errorCode  string
+		data       map[string]interface{}
+		wantReason string
+	}{
+		{name: "unregistered", errorCode: "UNREGISTERED", wantReason: ReasonUnregistered},
+		{name: "sender mismatch", errorCode: "SENDER_ID_MISMATCH", wantReason: ReasonSenderMismatch},
+		{name: "invalid token", errorCode: "INVALID_ARGUMENT", wantReason: ReasonInvalidToken},
+		{name: "auth error is not the token's fault", errorCode: "THIRD_PARTY_AUTH_ERROR"},
+		{name: "quota is transient", errorCode: "QUOTA_EXCEEDED"},
+		{name: "oversize payload is not the token's fault", data: map[string]interface{}{"blob": strings.Repeat("x", maxDataBytes)}},
+		{name: "delivered"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub, client := newStubClient(t)
+			if tt.errorCode != "" {
+				stub.FailToken("bad-token", tt.errorCode)
+			}
+			failures, err := client.SendToMultipleTokens(context.Background(), []string{"bad-token"}, Payload{
+				Title: "Hello",
+				Body:  "World",
+				Data:  tt.data,
+			})
+			if err != nil {
+				t.Fatalf("SendToMultipleTokens: %v", err)
+			}
+			var got string
+			if len(failures) > 0 {
+				if len(failures) != 1 || failures[0].Token != "bad-token" {
+					t.Fatalf("failures = %+v, want at most one for bad-token", failures)
+				}
+				got = failures[0].Reason
+			}
+			if got != tt.wantReason {
+				t.Errorf("reason = %q, want %q", got, tt.wantReason)
+			}
+			delivered := tt.errorCode == "" && tt.data == nil
+			if n := len(stub.Messages()); delivered != (n == 1) {
+				t.Errorf("stub recorded %d message(s), delivered = %v", n, delivered)
+			}
+		})
+	}
+}
+
+func TestStubSendMixedBatch(t *testing.T) {
+	stub, client := newStubClient(t)
+	stub.FailToken("gone", "UNREGISTERED")
+	stub.FailToken("busy", "QUOTA_EXCEEDED")
+
+	failures, err := client.SendToMultipleTokens(context.Background(), []string{"ok-1", "gone", "busy", "ok-2"}, Payload{Title: "Hi"})
+	if err != nil {
+		t.Fatalf("SendToMultipleTokens: %v", err)
+	}
+	if len(failures) != 1 || failures[0].Token != "gone" || failures[0].Reason != ReasonUnregistered {
+		t.Errorf("failures = %+v, want only gone/%s", failures, ReasonUnregistered)
+	}
+	if n := len(stub.Messages()); n != 2 {
+		t.Errorf("stub recorded %d message(s), want 2", n)
+	}
+}
+
+func TestStubTopicErrorMapping(t *testing.T) {
+	stub, client := newStubClient(t)
+	stub.FailToken("gone", "UNREGISTERED")
+	stub.FailToken("bad", "INVALID_ARGUMENT")
+	stub.FailToken("busy", "UNAVAILABLE")
+
+	failures, err := client.SubscribeToTopic(context.Background(), []string{"ok", "gone", "bad", "busy"}, TopicAll)
+	if err != nil {
+		t.Fatalf("SubscribeToTopic: %v", err)
+	}
+	got := map[string]string{}
+	for _, f := range failures {
+		got[f.Token] = f.Reason
+	}
+	want := map[string]string{"gone": ReasonUnregistered, "bad": ReasonInvalidToken}
+	if len(got) != len(want) {
+		t.Fatalf("failures = %v, want %v", got, want)
+	}
+	for token, reason := range want {
+		if got[token] != reason {
+			t.Errorf("%s: reason = %q, want %q", token, got[token], reason)
+		}
+	}
+	if subs := stub.Topics()[TopicAll]; len(subs) != 1 || subs[0] != "ok" {
+		t.Errorf("subscribers = %v, want [ok]", subs)
+	}
+}
@@ -0,0 +1,256 @@
+// internal/fcm/topics.go
+package fcm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// TopicAll is subscribed by every active device; broadcasts go here.
+const TopicAll = "all"
+
+// maxConditionTopics is FCM's limit on topics referenced by one condition.
+const maxConditionTopics = 5
+
+var topicUnsafe = regexp.MustCompile(`[^a-zA-Z0-9\-_.~%]`)
+
+// PlatformTopic is the per-platform topic, e.g. "platform-ios".
+func PlatformTopic(platform string) string {
+	return "platform-" + topicUnsafe.ReplaceAllString(strings.ToLower(platform), "_")
+}
+
+// LocaleTopic is the per-language topic, e.g. "fr-CA" → "locale-fr".
+func LocaleTopic(locale string) string {
+	lang := strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		lang = lang[:i]
+	}
+	return "locale-" + topicUnsafe.ReplaceAllString(lang, "_")
+}
+
+// TopicsFor returns the topics a device with this platform/locale belongs to.
+func TopicsFor(platform, locale string) []string {
+	topics := []string{TopicAll}
+	if platform != "" && platform != "unknown" {
+		topics = append(topics, PlatformTopic(platform))
+	}
+	if strings.TrimSpace(locale) != "" {
+		topics = append(topics, LocaleTopic(locale))
+	}
+	return topics
+}
+
+// Audience narrows a broadcast push by platform and/or locale.
+type Audience struct {
+	Platforms []string `json:"platforms,omitempty"`
+	Locales   []string `json:"locales,omitempty"`
+}
+
+// Target resolves the audience to a single topic or an FCM condition, e.g.
+// ('platform-ios' in topics || 'platform-android' in topics) && 'locale-fr' in topics.
+func (a Audience) Target() (topic, condition string, err error) {
+	if len(a.Platforms) == 0 && len(a.Locales) == 0 {
+		return TopicAll, "", nil
+	}
+	if len(a.Platforms)+len(a.Locales) > maxConditionTopics {
+		return "", "", fmt.Errorf("audience may reference at most %d platforms/locales", maxConditionTopics)
+	}
+	var platforms, locales []string
+	for _, p := range a.Platforms {
+		platforms = append(platforms, PlatformTopic(p))
+	}
+	for _, l := range a.Locales {
+		locales = append(locales, LocaleTopic(l))
+	}
+	if len(platforms)+len(locales) == 1 {
+		return append(platforms, locales...)[0], "", nil
+	}
+	var clauses []string
+	for _, group := range [][]string{platforms, locales} {
+		if len(group) == 0 {
+			continue
+		}
+		terms := make([]string, len(group))
+		for i, t := range group {
+			terms[i] = fmt.Sprintf("'%s' in topics", t)
+		}
+		clause := strings.Join(terms, " || ")
+		if len(terms) > 1 {
+			clause = "(" + clause + ")"
+		}
+		clauses = append(clauses, clause)
+	}
+	return "", strings.Join(clauses, " && "), nil
+}
+
+// Includes reports whether a device with this platform/locale is in the
+// audience, for devices not (yet) subscribed to their topics.
+func (a Audience) Includes(platform, locale string) bool {
+	return matchesTopic(a.Platforms, PlatformTopic, platform) && matchesTopic(a.Locales, LocaleTopic, locale)
+}
+
+// Reaches reports whether a device subscribed to topics receives the push
+// Target sends to this audience.
+func (a Audience) Reaches(topics []string) bool {
+	subscribed := make(map[string]bool, len(topics))
+	for _, t := range topics {
+		subscribed[t] = true
+	}
+	if len(a.Platforms) == 0 && len(a.Locales) == 0 {
+		return subscribed[TopicAll]
+	}
+	return anySubscribed(a.Platforms, PlatformTopic, subscribed) && anySubscribed(a.Locales, LocaleTopic, subscribed)
+}
+
+// anySubscribed reports whether one of wanted's topics is subscribed; an
+// empty wanted list doesn't narrow the condition.
+func anySubscribed(wanted []string, topicOf func(string) string, subscribed map[string]bool) bool {
+	if len(wanted) == 0 {
+		return true
+	}
+	for _, w := range wanted {
+		if subscribed[topicOf(w)] {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesTopic reports whether value maps to the same topic as one of wanted;
+// an empty wanted list matches everything.
+func matchesTopic(wanted []string, topicOf func(string) string, value string) bool {
+	if len(wanted) == 0 {
+		return true
+	}
+	for _, w := range wanted {
+		if topicOf(w) == topicOf(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FCMClient) SendToTopic(ctx context.Context, topic string, p Payload) error {
+	message := f.buildMessage("", p)
+	message.Topic = topic
+	resp, err := f.client.Send(ctx, message)
+	if err != nil {
+		return fmt.Errorf("FCM topic send failed: %w", err)
+	}
+	log.Printf("✅ FCM sent to topic %s → msg ID: %s", topic, resp)
+	return nil
+}
+
+func (f *FCMClient) SendToCondition(ctx context.Context, condition string, p Payload) error {
+	message := f.buildMessage("", p)
+	message.Condition = condition
+	resp, err := f.client.Send(ctx, message)
+	if err != nil {
+		return fmt.Errorf("FCM condition send failed: %w", err)
+	}
+	log.Printf("✅ FCM sent to condition %q → msg ID: %s", condition, resp)
+	return nil
+}
+
+// SubscribeToTopic adds tokens to a topic and returns tokens FCM rejected permanently.
+func (f *FCMClient) SubscribeToTopic(ctx context.Context, tokens []string, topic string) ([]TokenFailure, error) {
+	return f.manageTopic(ctx, "batchAdd", tokens, topic)
+}
+
+// UnsubscribeFromTopic removes tokens from a topic.
+func (f *FCMClient) UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) ([]TokenFailure, error) {
+	return f.manageTopic(ctx, "batchRemove", tokens, topic)
+}
+
+// topicBatchSize is the IID limit on tokens per topic management call.
+const topicBatchSize = 1000
+
+func (f *FCMClient) manageTopic(ctx context.Context, op string, tokens []string, topic string) ([]TokenFailure, error) {
+	var failures []TokenFailure
+	for i := 0; i < len(tokens); i += topicBatchSize {
+		end := i + topicBatchSize
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		batch := tokens[i:end]
+
+		var resp *messaging.TopicManagementResponse
+		var err error
+		switch {
+		case f.iidEndpoint != "":
+			resp, err = postTopicRequest(ctx, f.iidEndpoint, op, batch, topic)
+		case op == "batchAdd":
+			resp, err = f.client.SubscribeToTopic(ctx, batch, topic)
+		default:
+			resp, err = f.client.UnsubscribeFromTopic(ctx, batch, topic)
+		}
+		if err != nil {
+			return failures, fmt.Errorf("FCM topic %s %s[%d:%d] failed: %w", op, topic, i, end, err)
+		}
+		for _, e := range resp.Errors {
+			if reason := topicErrorReason(e.Reason); reason != "" {
+				failures = append(failures, TokenFailure{Token: batch[e.Index], Reason: reason})
+			} else {
+				log.Printf("⚠️ FCM topic %s %s failed for %s: %s", op, topic, maskToken(batch[e.Index]), e.Reason)
+			}
+		}
+	}
+	return failures, nil
+}
+
+// topicErrorReason maps IID per-token errors to the token failure reasons.
+func topicErrorReason(reason string) string {
+	switch reason {
+	case "NOT_FOUND", "registration-token-not-registered":
+		return ReasonUnregistered
+	case "INVALID_ARGUMENT", "invalid-argument":
+		return ReasonInvalidToken
+	}
+	return ""
+}
+
+// postTopicRequest speaks the IID batchAdd/batchRemove API against a local
+// stub; the Firebase SDK always targets iid.googleapis.com.
+func postTopicRequest(ctx context.Context, endpoint, op string, tokens []string, topic string) (*messaging.TopicManagementResponse, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"to":                  "/topics/" + topic,
+		"registration_tokens": tokens,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+":"+op, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	httpResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("topic management returned HTTP %d", httpResp.StatusCode)
+	}
+	var result struct {
+		Results []map[string]string `json:"results"`
+	}
+	if err := json.NewDecoder(httpResp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	resp := &messaging.TopicManagementResponse{}
+	for i, r := range result.Results {
+		if r["error"] == "" {
+			resp.SuccessCount++
+			continue
+		}
+		resp.FailureCount++
+		resp.Errors = append(resp.Errors, &messaging.ErrorInfo{Index: i, Reason: r["error"]})
+	}
+	return resp, nil
+}
@@ -0,0 +1,93 @@
+package fcm
+
+import "testing"
+
+func TestAudienceTarget(t *testing.T) {
+	tests := []struct {
+		name          string
+		audience      Audience
+		wantTopic     string
+		wantCondition string
+		wantErr       bool
+	}{
+		{name: "everyone", wantTopic: TopicAll},
+		{name: "one platform", audience: Audience{Platforms: []string{"iOS"}}, wantTopic: "platform-ios"},
+		{name: "one locale", audience: Audience{Locales: []string{"fr-CA"}}, wantTopic: "locale-fr"},
+		{
+			name:          "platforms and locale",
+			audience:      Audience{Platforms: []string{"ios", "android"}, Locales: []string{"fr"}},
+			wantCondition: "('platform-ios' in topics || 'platform-android' in topics) && 'locale-fr' in topics",
+		},
+		{
+			name:     "too many topics",
+			audience: Audience{Platforms: []string{"ios", "android", "web"}, Locales: []string{"fr", "de", "es"}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic, condition, err := tt.audience.Target()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if topic != tt.wantTopic || condition != tt.wantCondition {
+				t.Errorf("Target() = (%q, %q), want (%q, %q)", topic, condition, tt.wantTopic, tt.wantCondition)
+			}
+		})
+	}
+}
+
+func TestAudienceIncludes(t *testing.T) {
+	audience := Audience{Platforms: []string{"iOS"}, Locales: []string{"fr"}}
+	tests := []struct {
+		platform, locale string
+		want             bool
+	}{
+		{"ios", "fr-CA", true},
+		{"ios", "fr_FR", true},
+		{"android", "fr", false},
+		{"ios", "en", false},
+		{"ios", "", false},
+	}
+	for _, tt := range tests {
+		if got := audience.Includes(tt.platform, tt.locale); got != tt.want {
+			t.Errorf("Includes(%q, %q) = %v, want %v", tt.platform, tt.locale, got, tt.want)
+		}
+	}
+	if !(Audience{}).Includes("unknown", "") {
+		t.Error("empty audience should include every device")
+	}
+}
+
+func TestAudienceReaches(t *testing.T) {
+	tests := []struct {
+		name     string
+		audience Audience
+		topics   []string
+		want     bool
+	}{
+		{name: "everyone, subscribed", topics: []string{TopicAll}, want: true},
+		{name: "everyone, unsynced", topics: nil, want: false},
+		{name: "platform topic", audience: Audience{Platforms: []string{"iOS"}}, topics: []string{TopicAll, "platform-ios"}, want: true},
+		{name: "platform subscription failed", audience: Audience{Platforms: []string{"ios"}}, topics: []string{TopicAll}, want: false},
+		{
+			name:     "condition needs both groups",
+			audience: Audience{Platforms: []string{"ios", "android"}, Locales: []string{"fr"}},
+			topics:   []string{TopicAll, "platform-android"},
+			want:     false,
+		},
+		{
+			name:     "condition met",
+			audience: Audience{Platforms: []string{"ios", "android"}, Locales: []string{"fr"}},
+			topics:   []string{TopicAll, "platform-android", "locale-fr"},
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.audience.Reaches(tt.topics); got != tt.want {
+				t.Errorf("Reaches(%v) = %v, want %v", tt.topics, got, tt.want)
+			}
+		})
+	}
+}
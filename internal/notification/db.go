@@ -13,18 +13,27 @@ import (
 
 var db *gorm.DB
 
-func InitDB(cfg *config.Config) {
-	dsn := fmt.Sprintf(
+// DSN is the connection string for the notification database. Besides GORM
+// it's used by the real-time listener, which needs its own connection.
+func DSN(cfg *config.Config) string {
+	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s TimeZone=Africa/Lagos",
 		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPass, cfg.DBName, cfg.DBSSLMode,
 	)
+}
 
+func InitDB(cfg *config.Config) {
 	var err error
-	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err = gorm.Open(postgres.Open(DSN(cfg)), &gorm.Config{})
 	if err != nil {
 		log.Fatalf("❌ Failed to connect to DB: %v", err)
 	}
 
+	// Categories are backfilled only when the column is first added, so
+	// explicit categories set afterwards are never overwritten
+	needsCategoryBackfill := db.Migrator().HasTable(&models.Notification{}) &&
+		!db.Migrator().HasColumn(&models.Notification{}, "Category")
+
 	// Auto-migrate (safe in dev; use migrations in prod)
 	err = db.AutoMigrate(
 		&models.SyncConfig{}, 
@@ -33,6 +42,12 @@ func InitDB(cfg *config.Config) {
 		&models.User{}, 
 		&models.SystemNotificationTemplate{}, 
 		&models.FCMToken{},
+		&models.CalendarInvite{},
+		&models.DevicePresence{},
+		&models.InboxEvent{},
+		&models.NotificationInteraction{},
+		&models.ReadWatermark{},
+		&models.Segment{},
 	)
 	if err != nil {
 		log.Fatalf("❌ Failed to migrate: %v", err)
@@ -47,6 +62,22 @@ func InitDB(cfg *config.Config) {
 		log.Println("✅ FCM token constraints ensured")
 	}
 
+	if err := ensureInboxIndexes(db); err != nil {
+		log.Printf("⚠️ Failed to ensure inbox indexes: %v", err)
+	} else {
+		log.Println("✅ Inbox indexes ensured")
+	}
+
+	if err := backfillTokenLastSeen(db); err != nil {
+		log.Printf("⚠️ Failed to backfill FCM token last_seen_at: %v", err)
+	}
+
+	if needsCategoryBackfill {
+		if err := backfillCategories(db); err != nil {
+			log.Printf("⚠️ Failed to backfill notification categories: %v", err)
+		}
+	}
+
 	// ✅ Seed system templates after migration
 	if err := seedSystemNotificationTemplates(db); err != nil {
 		log.Printf("⚠️ Failed to seed system notification templates: %v", err)
@@ -88,6 +119,84 @@ func ensureFCMTokenConstraints(db *gorm.DB) error {
 	return nil
 }
 
+// ensureInboxIndexes backs the cursor-paginated lists (the user inbox on
+// (delivered_at, id) per user, admin lists on (created_at, id), history on
+// (delivered_at, id)), unread counts (partial index on unread, unarchived
+// rows), the per-user event log behind streams and delta sync, inbox search
+// (full-text vector and event key) and the outstanding acknowledgements list.
+func ensureInboxIndexes(db *gorm.DB) error {
+	statements := []string{
+		// Full-text search over a user's inbox: an expression index, so
+		// nothing is stored and the table is never rewritten
+		`CREATE INDEX IF NOT EXISTS idx_notifications_search
+			ON notifications USING GIN (` + models.NotificationSearchVector + `)`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_event_key
+			ON notifications ((metadata->>'event_key'))`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_requires_ack
+			ON notifications (created_at DESC, id DESC)
+			WHERE requires_ack AND deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_escalation
+			ON notifications (id)
+			WHERE escalation_policy IS NOT NULL AND deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_expiry
+			ON notifications (expires_at)
+			WHERE expires_at IS NOT NULL AND expiry_swept_at IS NULL AND deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_recipients_unread
+			ON notification_recipients (user_id, notification_id)
+			WHERE status IN ('pending', 'delivered') AND archived_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_inbox_events_user_id
+			ON inbox_events (user_id, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_recipients_user_delivered
+			ON notification_recipients (user_id, delivered_at DESC, id DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_created
+			ON notifications (created_at DESC, id DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_delivered
+			ON notifications (delivered_at DESC, id DESC) WHERE delivered_at IS NOT NULL`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+	return nil
+}
+
+// backfillCategories assigns categories to notifications created before the
+// column existed, using the same rules as models.CategoryFor.
+func backfillCategories(db *gorm.DB) error {
+	result := db.Exec(`
+		UPDATE notifications SET category = CASE
+			WHEN COALESCE(metadata->>'event_key', metadata->>'email_type', '') ~ '^(user\.login\.|account\.|kyc\.|pin\.|profile\.email\.|email_verification|password_reset|otp|new_login|pin_recovery)' THEN 'security'
+			WHEN COALESCE(metadata->>'event_key', metadata->>'email_type', '') ~ '^(wallet\.|conversion|deposit_|withdraw_)' THEN 'wallet'
+			WHEN COALESCE(metadata->>'event_key', '') ~ '^(match\.|quiz\.|post\.|profile\.)' THEN 'social'
+			WHEN type = 'security' THEN 'security'
+			WHEN type IN ('promotional', 'video') THEN 'promotional'
+			ELSE 'general'
+		END`)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("🛠️ Recomputed categories for %d notification(s)", result.RowsAffected)
+	}
+	return nil
+}
+
+// backfillTokenLastSeen starts the inactivity clock now for tokens registered
+// before last_seen_at existed; registration always sets it, so this only
+// touches legacy rows. Without it they would all expire on the first sweep.
+func backfillTokenLastSeen(db *gorm.DB) error {
+	result := db.Exec(`UPDATE fcm_tokens SET last_seen_at = NOW()
+		WHERE last_seen_at IS NULL AND deleted_at IS NULL`)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("🛠️ Backfilled last_seen_at for %d legacy FCM token(s)", result.RowsAffected)
+	}
+	return nil
+}
+
 func GetDB() *gorm.DB {
 	return db
 }
\ No newline at end of file
@@ -8,6 +8,7 @@ import (
 	"log"
 
 	"gorm.io/gorm"
+	"notify-service/internal/schema"
 	"notify-service/pkg/models"
 )
 
@@ -17,6 +18,72 @@ func jsonList(values []string) []byte {
 	return b
 }
 
+// templateVarSchemas types well-known template variables. Variables not listed
+// here are only required to be present.
+var templateVarSchemas = map[string]*schema.Schema{
+	"amount":        schema.Decimal(),
+	"new_balance":   schema.Decimal(),
+	"fee_amount":    schema.Decimal(),
+	"fiat_amount":   schema.Decimal(),
+	"sol_amount":    schema.Decimal(),
+	"currency":      schema.Matching(`^[A-Z]{3,5}$`),
+	"fiat_currency": schema.Matching(`^[A-Z]{3,5}$`),
+	"ip_address":    schema.Formatted("ip"),
+	"start_time":    schema.Formatted("date-time"),
+	"attempt_count": schema.Integer(),
+	"lock_duration": schema.Integer(),
+	"score":         schema.Integer(),
+	"total":         schema.Integer(),
+	"xp_change":     schema.Integer(),
+	"xp_earned":     schema.Integer(),
+	"user_name":     schema.String(),
+	"game_name":     schema.String(),
+	"opponent_name": schema.String(),
+	"match_id":      schema.String(),
+	"quiz_id":       schema.String(),
+	"txid":          schema.String(),
+	"reference":     schema.String(),
+	"destination":   schema.String(),
+	"device_os":     schema.String(),
+	"device_id":     schema.String(),
+	"timestamp":     schema.String(),
+}
+
+// matchPushOptions collapses every push about the same match into one on the
+// device, so a reschedule replaces the original "match created" alert.
+var matchPushOptions = func() []byte {
+	b, _ := json.Marshal(models.PushOptions{
+		ChannelID:         "matches",
+		CollapseKey:       "match-{{match_id}}",
+		InterruptionLevel: "time-sensitive",
+	})
+	return b
+}()
+
+// urgentEscalation emails recipients who leave an urgent account notice
+// unread for an hour, and texts them if it's still unread two hours later.
+var urgentEscalation = func() []byte {
+	b, _ := json.Marshal(models.EscalationPolicy{Steps: []models.EscalationStep{
+		{Channel: models.EscalationChannelEmail, AfterMinutes: 60},
+		{Channel: models.EscalationChannelSMS, AfterMinutes: 180},
+	}})
+	return b
+}()
+
+// ContextSchemaForVars builds the default variables schema for a template:
+// every listed variable is required and typed via templateVarSchemas.
+func ContextSchemaForVars(vars []string) *schema.Schema {
+	props := make(map[string]*schema.Schema, len(vars))
+	for _, v := range vars {
+		if s, ok := templateVarSchemas[v]; ok {
+			props[v] = s
+		} else {
+			props[v] = &schema.Schema{}
+		}
+	}
+	return schema.Object(props, vars...)
+}
+
 // seedSystemNotificationTemplates populates the database with default system templates
 func seedSystemNotificationTemplates(db *gorm.DB) error {
 
@@ -99,26 +166,29 @@ func seedSystemNotificationTemplates(db *gorm.DB) error {
 			TemplateVars: jsonList([]string{"user_name", "timestamp"}),
 		},
 		{
-			EventKey:     "kyc.rejected",
-			Name:         "KYC Rejected",
-			Enabled:      true,
-			Heading:      "❌ KYC rejected",
-			Title:        "Verification Failed",
-			Message:      "Reason: {{rejection_reason}}. You may resubmit with corrections.",
-			Type:         "error",
-			Icon:         "user-x",
-			TemplateVars: jsonList([]string{"user_name", "rejection_reason", "timestamp"}),
+			EventKey:         "kyc.rejected",
+			Name:             "KYC Rejected",
+			Enabled:          true,
+			Heading:          "❌ KYC rejected",
+			Title:            "Verification Failed",
+			Message:          "Reason: {{rejection_reason}}. You may resubmit with corrections.",
+			Type:             "error",
+			Icon:             "user-x",
+			TemplateVars:     jsonList([]string{"user_name", "rejection_reason", "timestamp"}),
+			RequiresAck:      true, // resubmission notices must be acknowledged
+			EscalationPolicy: urgentEscalation,
 		},
 		{
-			EventKey:     "account.suspended",
-			Name:         "Account Suspended",
-			Enabled:      true,
-			Heading:      "🔒 Account suspended",
-			Title:        "Action Required",
-			Message:      "Your account was suspended at {{timestamp}}. Reason: {{reason}}.",
-			Type:         "error",
-			Icon:         "lock",
-			TemplateVars: jsonList([]string{"user_name", "reason", "timestamp"}),
+			EventKey:         "account.suspended",
+			Name:             "Account Suspended",
+			Enabled:          true,
+			Heading:          "🔒 Account suspended",
+			Title:            "Action Required",
+			Message:          "Your account was suspended at {{timestamp}}. Reason: {{reason}}.",
+			Type:             "error",
+			Icon:             "lock",
+			TemplateVars:     jsonList([]string{"user_name", "reason", "timestamp"}),
+			EscalationPolicy: urgentEscalation,
 		},
 		{
 			EventKey:     "account.suspension.lifted",
@@ -141,6 +211,31 @@ func seedSystemNotificationTemplates(db *gorm.DB) error {
 			Type:         "info",
 			Icon:         "gamepad-2",
 			TemplateVars: jsonList([]string{"user_name", "opponent_name", "game_name", "start_time", "match_id"}),
+			PushOptions:  matchPushOptions,
+		},
+		{
+			EventKey:     "match.updated",
+			Name:         "Match Updated",
+			Enabled:      true,
+			Heading:      "🎮 Match updated: {{game_name}}",
+			Title:        "Match Rescheduled",
+			Message:      "Your match vs {{opponent_name}} now starts at {{start_time}}.",
+			Type:         "info",
+			Icon:         "calendar-clock",
+			TemplateVars: jsonList([]string{"user_name", "opponent_name", "game_name", "start_time", "match_id"}),
+			PushOptions:  matchPushOptions,
+		},
+		{
+			EventKey:     "match.cancelled",
+			Name:         "Match Cancelled",
+			Enabled:      true,
+			Heading:      "🚫 Match cancelled: {{game_name}}",
+			Title:        "Match Cancelled",
+			Message:      "Your match vs {{opponent_name}} has been cancelled.",
+			Type:         "warning",
+			Icon:         "calendar-x",
+			TemplateVars: jsonList([]string{"user_name", "opponent_name", "game_name", "match_id"}),
+			PushOptions:  matchPushOptions,
 		},
 		{
 			EventKey:     "match.result",
@@ -281,11 +376,41 @@ func seedSystemNotificationTemplates(db *gorm.DB) error {
 	}
 
 	for _, t := range templates {
+		var vars []string
+		_ = json.Unmarshal(t.TemplateVars, &vars)
+		schemaJSON, _ := json.Marshal(ContextSchemaForVars(vars))
+		t.ContextSchema = schemaJSON
+
 		var count int64
 		db.Model(&models.SystemNotificationTemplate{}).
 			Where("event_key = ?", t.EventKey).
 			Count(&count)
 
+		if count > 0 {
+			// Backfill schema on templates seeded before typed schemas existed
+			db.Model(&models.SystemNotificationTemplate{}).
+				Where("event_key = ? AND context_schema IS NULL", t.EventKey).
+				Update("context_schema", t.ContextSchema)
+			// Templates seeded before acknowledgements existed have neither
+			// flag nor escalation policy; once the policy is set below the
+			// admin's requires_ack choice is left alone
+			if t.RequiresAck {
+				db.Model(&models.SystemNotificationTemplate{}).
+					Where("event_key = ? AND NOT requires_ack AND escalation_policy IS NULL", t.EventKey).
+					Update("requires_ack", true)
+			}
+			if t.PushOptions != nil {
+				db.Model(&models.SystemNotificationTemplate{}).
+					Where("event_key = ? AND push_options IS NULL", t.EventKey).
+					Update("push_options", t.PushOptions)
+			}
+			if t.EscalationPolicy != nil {
+				db.Model(&models.SystemNotificationTemplate{}).
+					Where("event_key = ? AND escalation_policy IS NULL", t.EventKey).
+					Update("escalation_policy", t.EscalationPolicy)
+			}
+		}
+
 		if count == 0 {
 			if err := db.Create(&t).Error; err != nil {
 				return fmt.Errorf("failed to seed template %s: %w", t.EventKey, err)
@@ -0,0 +1,161 @@
+// Package realtime fans inbox events out to connected stream clients. Events
+// are published through Postgres NOTIFY so every replica sees every event,
+// and each replica delivers only to the clients connected to it.
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Channel is the Postgres NOTIFY channel inbox events are published on.
+const Channel = "inbox_events"
+
+// subscriberBuffer is how many events a slow client may lag behind before it
+// is dropped; it reconnects and resumes with Last-Event-ID.
+const subscriberBuffer = 64
+
+var (
+	ErrTooManyConnections = errors.New("too many open streams for this user")
+	ErrServerFull         = errors.New("stream capacity reached")
+)
+
+// Event is one inbox change as sent over NOTIFY and to clients.
+type Event struct {
+	ID             int64           `json:"id"`
+	UserID         *uuid.UUID      `json:"user_id,omitempty"` // nil = every user
+	Type           string          `json:"type"`
+	OriginDeviceID string          `json:"origin_device_id,omitempty"`
+	Data           json.RawMessage `json:"data,omitempty"`
+	Partial        bool            `json:"partial,omitempty"` // data left out of NOTIFY (too large); load by ID
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// Subscriber is one open stream.
+type Subscriber struct {
+	UserID   uuid.UUID
+	DeviceID string
+	Events   chan Event
+	Done     chan struct{} // closed when the hub drops the stream
+	once     sync.Once
+}
+
+func (s *Subscriber) close() {
+	s.once.Do(func() { close(s.Done) })
+}
+
+// Hub tracks open streams per user.
+type Hub struct {
+	mu         sync.RWMutex
+	users      map[uuid.UUID]map[*Subscriber]struct{}
+	total      int
+	maxPerUser int // 0 = unlimited
+	maxTotal   int // 0 = unlimited
+}
+
+func NewHub(maxPerUser, maxTotal int) *Hub {
+	return &Hub{
+		users:      make(map[uuid.UUID]map[*Subscriber]struct{}),
+		maxPerUser: maxPerUser,
+		maxTotal:   maxTotal,
+	}
+}
+
+// Subscribe opens a stream for a user's device, enforcing connection limits.
+func (h *Hub) Subscribe(userID uuid.UUID, deviceID string) (*Subscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.maxTotal > 0 && h.total >= h.maxTotal {
+		return nil, ErrServerFull
+	}
+	subs := h.users[userID]
+	if h.maxPerUser > 0 && len(subs) >= h.maxPerUser {
+		return nil, ErrTooManyConnections
+	}
+	if subs == nil {
+		subs = make(map[*Subscriber]struct{})
+		h.users[userID] = subs
+	}
+	sub := &Subscriber{
+		UserID:   userID,
+		DeviceID: deviceID,
+		Events:   make(chan Event, subscriberBuffer),
+		Done:     make(chan struct{}),
+	}
+	subs[sub] = struct{}{}
+	h.total++
+	return sub, nil
+}
+
+// Unsubscribe removes a stream. Safe to call more than once.
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+func (h *Hub) remove(sub *Subscriber) {
+	subs := h.users[sub.UserID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.users, sub.UserID)
+	}
+	h.total--
+	sub.close()
+}
+
+// Dispatch hands an event to the matching local streams. The device that made
+// the change is skipped; streams that can't keep up are dropped.
+func (h *Hub) Dispatch(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ev.UserID != nil {
+		for sub := range h.users[*ev.UserID] {
+			h.deliver(sub, ev)
+		}
+		return
+	}
+	for _, subs := range h.users {
+		for sub := range subs {
+			h.deliver(sub, ev)
+		}
+	}
+}
+
+func (h *Hub) deliver(sub *Subscriber, ev Event) {
+	if ev.OriginDeviceID != "" && ev.OriginDeviceID == sub.DeviceID {
+		return
+	}
+	select {
+	case sub.Events <- ev:
+	default:
+		log.Printf("⚠️ [STREAM] Dropping slow stream for user %s (device %s)", sub.UserID, sub.DeviceID)
+		h.remove(sub)
+	}
+}
+
+// Close drops every stream, e.g. on shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subs := range h.users {
+		for sub := range subs {
+			h.remove(sub)
+		}
+	}
+}
+
+// Stats returns the number of connected users and open streams.
+func (h *Hub) Stats() (users, streams int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.users), h.total
+}
@@ -0,0 +1,145 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// MaxNotifyPayload keeps NOTIFY messages under Postgres' 8000-byte limit.
+// Larger events are sent without data and loaded by ID on receipt.
+const MaxNotifyPayload = 7500
+
+// catchUpLimit bounds how many missed events are replayed after the listener
+// reconnects; clients further behind resume via Last-Event-ID.
+const catchUpLimit = 1000
+
+// Store reads the event log.
+type Store interface {
+	// InboxEventByID returns one event with its data.
+	InboxEventByID(ctx context.Context, id int64) (*Event, error)
+	// InboxEventsAfter returns events with ID > afterID in order, for every
+	// user when userID is nil or for one user (and broadcasts) otherwise.
+	InboxEventsAfter(ctx context.Context, userID *uuid.UUID, afterID int64, limit int) ([]Event, error)
+}
+
+// Listen holds a dedicated connection LISTENing on Channel and dispatches
+// every event to hub until ctx is done. It reconnects with backoff and
+// replays whatever was published while it was disconnected.
+func Listen(ctx context.Context, dsn string, hub *Hub, store Store) {
+	defer hub.Close()
+	seen := newSeenEvents(catchUpLimit)
+	backoff := time.Second
+	for {
+		err := listenOnce(ctx, dsn, hub, store, seen, func() { backoff = time.Second })
+		if ctx.Err() != nil {
+			log.Println("🛑 [STREAM] Listener stopped")
+			return
+		}
+		log.Printf("⚠️ [STREAM] Listener disconnected: %v (retrying in %v)", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func listenOnce(ctx context.Context, dsn string, hub *Hub, store Store, seen *seenEvents, connected func()) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	connected()
+	log.Printf("📡 [STREAM] Listening on %q", Channel)
+
+	// Anything published while we were away. Events can commit out of ID
+	// order, so this starts at the oldest event remembered, not the newest
+	if from, ok := seen.from(); ok {
+		missed, err := store.InboxEventsAfter(ctx, nil, from, catchUpLimit+seen.len())
+		if err != nil {
+			return err
+		}
+		for _, ev := range missed {
+			if seen.add(ev.ID) {
+				hub.Dispatch(ev)
+			}
+		}
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var ev Event
+		if err := json.Unmarshal([]byte(n.Payload), &ev); err != nil {
+			log.Printf("⚠️ [STREAM] Ignoring malformed event: %v", err)
+			continue
+		}
+		if ev.Partial {
+			full, err := store.InboxEventByID(ctx, ev.ID)
+			if err != nil {
+				log.Printf("⚠️ [STREAM] Failed to load event %d: %v", ev.ID, err)
+				continue
+			}
+			ev = *full
+		}
+		if seen.add(ev.ID) {
+			hub.Dispatch(ev)
+		}
+	}
+}
+
+// seenEvents remembers the IDs of the last dispatched events, so a catch-up
+// can start before the newest one without dispatching anything twice.
+type seenEvents struct {
+	limit int
+	ids   map[int64]bool
+	order []int64 // oldest first
+}
+
+func newSeenEvents(limit int) *seenEvents {
+	return &seenEvents{limit: limit, ids: make(map[int64]bool)}
+}
+
+// add records id and reports whether it is new.
+func (s *seenEvents) add(id int64) bool {
+	if s.ids[id] {
+		return false
+	}
+	s.ids[id] = true
+	s.order = append(s.order, id)
+	if len(s.order) > s.limit {
+		delete(s.ids, s.order[0])
+		s.order = s.order[1:]
+	}
+	return true
+}
+
+// from is where a catch-up starts: just before the oldest remembered event.
+func (s *seenEvents) from() (int64, bool) {
+	if len(s.order) == 0 {
+		return 0, false
+	}
+	lowest := s.order[0]
+	for _, id := range s.order {
+		lowest = min(lowest, id)
+	}
+	return lowest - 1, true
+}
+
+func (s *seenEvents) len() int {
+	return len(s.order)
+}
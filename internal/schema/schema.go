@@ -0,0 +1,331 @@
+// internal/schema/schema.go
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is a JSON-Schema-style declaration of a context/variables payload.
+// Only the subset of keywords we need is supported; field names match JSON
+// Schema so declarations can be stored as JSONB and edited by admins.
+type Schema struct {
+	Type        string             `json:"type,omitempty"`   // string | number | integer | boolean | object | array ("" = any)
+	Format      string             `json:"format,omitempty"` // email | uri | date-time | uuid | ip | decimal
+	Enum        []string           `json:"enum,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Description string             `json:"description,omitempty"`
+
+	re *regexp.Regexp // Pattern, compiled by Parse or Matching
+}
+
+// Violation describes a single validation failure.
+type Violation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error wraps a list of violations so it can travel through error returns.
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, fmt.Sprintf("%s: %s", v.Field, v.Message))
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Parse decodes a stored (JSONB) schema. Empty input returns nil.
+func Parse(raw []byte) (*Schema, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var s Schema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := s.check(""); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Object is shorthand for an object schema with the given properties and required keys.
+func Object(props map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: props, Required: required}
+}
+
+// String returns a non-empty string schema.
+func String() *Schema {
+	one := 1
+	return &Schema{Type: "string", MinLength: &one}
+}
+
+// Formatted returns a string schema with the given format.
+func Formatted(format string) *Schema {
+	s := String()
+	s.Format = format
+	return s
+}
+
+// Matching returns a string schema constrained by a regular expression.
+func Matching(pattern string) *Schema {
+	s := String()
+	s.Pattern = pattern
+	s.re = regexp.MustCompile(pattern)
+	return s
+}
+
+// Decimal accepts JSON numbers or numeric strings (amounts, balances, rates).
+func Decimal() *Schema {
+	return &Schema{Format: "decimal"}
+}
+
+// Integer returns an integer schema.
+func Integer() *Schema {
+	return &Schema{Type: "integer"}
+}
+
+// OneOf returns a string schema restricted to the given values.
+func OneOf(values ...string) *Schema {
+	return &Schema{Type: "string", Enum: values}
+}
+
+// Validate checks value against the schema and returns every violation found.
+func (s *Schema) Validate(value interface{}) []Violation {
+	if s == nil {
+		return nil
+	}
+	var out []Violation
+	s.validate("", value, &out)
+	return out
+}
+
+func (s *Schema) validate(path string, value interface{}, out *[]Violation) {
+	field := path
+	if field == "" {
+		field = "$"
+	}
+	add := func(rule, msg string, args ...interface{}) {
+		*out = append(*out, Violation{Field: field, Rule: rule, Message: fmt.Sprintf(msg, args...)})
+	}
+
+	if s.Type != "" && !matchesType(s.Type, value) {
+		add("type", "must be of type %s", s.Type)
+		return
+	}
+
+	if str, ok := value.(string); ok {
+		n := len([]rune(str))
+		if s.MinLength != nil && n < *s.MinLength {
+			if *s.MinLength == 1 {
+				add("minLength", "must not be empty")
+			} else {
+				add("minLength", "must be at least %d characters", *s.MinLength)
+			}
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			add("maxLength", "must be at most %d characters", *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			switch re, err := s.pattern(); {
+			case err != nil:
+				add("pattern", "has an invalid pattern: %v", err)
+			case !re.MatchString(str):
+				add("pattern", "must match %s", s.Pattern)
+			}
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		str := fmt.Sprintf("%v", value)
+		found := false
+		for _, e := range s.Enum {
+			if e == str {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add("enum", "must be one of: %s", strings.Join(s.Enum, ", "))
+		}
+	}
+
+	if s.Format != "" {
+		if msg := checkFormat(s.Format, value); msg != "" {
+			add("format", "%s", msg)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := v[key]; !ok {
+				*out = append(*out, Violation{Field: join(path, key), Rule: "required", Message: "is required"})
+			}
+		}
+		keys := make([]string, 0, len(s.Properties))
+		for k := range s.Properties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if pv, ok := v[k]; ok && pv != nil {
+				s.Properties[k].validate(join(path, k), pv, out)
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", field, i), item, out)
+			}
+		}
+	}
+}
+
+// check verifies the schema itself is well-formed (types, formats, patterns).
+func (s *Schema) check(path string) error {
+	switch s.Type {
+	case "", "string", "number", "integer", "boolean", "object", "array":
+	default:
+		return fmt.Errorf("invalid schema: unknown type %q at %s", s.Type, join(path, ""))
+	}
+	switch s.Format {
+	case "", "email", "uri", "date-time", "uuid", "ip", "decimal":
+	default:
+		return fmt.Errorf("invalid schema: unknown format %q at %s", s.Format, join(path, ""))
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid schema: bad pattern at %s: %w", join(path, ""), err)
+		}
+		s.re = re
+	}
+	for k, p := range s.Properties {
+		if p == nil {
+			return fmt.Errorf("invalid schema: null property %s", join(path, k))
+		}
+		if err := p.check(join(path, k)); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "[]")
+	}
+	return nil
+}
+
+// pattern is the compiled Pattern. Schemas built as struct literals skip
+// Parse, so theirs is compiled here, and a bad one is reported, not ignored.
+func (s *Schema) pattern() (*regexp.Regexp, error) {
+	if s.re != nil {
+		return s.re, nil
+	}
+	return regexp.Compile(s.Pattern)
+}
+
+func matchesType(t string, value interface{}) bool {
+	switch t {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		switch value.(type) {
+		case float64, float32, int, int64, json.Number:
+			return true
+		}
+		return false
+	case "integer":
+		switch n := value.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return n == float64(int64(n))
+		}
+		return false
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	}
+	return true
+}
+
+func checkFormat(format string, value interface{}) string {
+	if format == "decimal" {
+		switch v := value.(type) {
+		case float64, float32, int, int64:
+			return ""
+		case string:
+			if _, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && strings.TrimSpace(v) != "" {
+				return ""
+			}
+		}
+		return "must be a decimal number"
+	}
+	str, ok := value.(string)
+	if !ok {
+		return ""
+	}
+	switch format {
+	case "email":
+		if _, err := mail.ParseAddress(str); err != nil {
+			return "must be a valid email address"
+		}
+	case "uri":
+		if u, err := url.Parse(str); err != nil || u.Scheme == "" || u.Host == "" {
+			return "must be an absolute URI"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			return "must be an RFC3339 date-time"
+		}
+	case "uuid":
+		if _, err := uuid.Parse(str); err != nil {
+			return "must be a UUID"
+		}
+	case "ip":
+		if net.ParseIP(str) == nil {
+			return "must be an IP address"
+		}
+	}
+	return ""
+}
+
+func join(path, key string) string {
+	if path == "" {
+		if key == "" {
+			return "$"
+		}
+		return key
+	}
+	if key == "" {
+		return path
+	}
+	return path + "." + key
+}
@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	maxTwo := 2
+	sch := Object(map[string]*Schema{
+		"email":    Formatted("email"),
+		"currency": Matching(`^[A-Z]{3,5}$`),
+		"amount":   Decimal(),
+		"count":    Integer(),
+		"status":   OneOf("open", "closed"),
+		"code":     {Type: "string", MaxLength: &maxTwo},
+		"tags":     {Type: "array", Items: String()},
+	}, "email", "amount")
+
+	tests := []struct {
+		name  string
+		value map[string]interface{}
+		want  []string // field:rule
+	}{
+		{
+			name:  "valid",
+			value: map[string]interface{}{"email": "a@b.co", "amount": "12.50", "currency": "USDC", "count": float64(3), "status": "open", "tags": []interface{}{"x"}},
+		},
+		{
+			name:  "missing required",
+			value: map[string]interface{}{},
+			want:  []string{"email:required", "amount:required"},
+		},
+		{
+			name:  "pattern",
+			value: map[string]interface{}{"email": "a@b.co", "amount": 1.5, "currency": "usd"},
+			want:  []string{"currency:pattern"},
+		},
+		{
+			name:  "format and enum",
+			value: map[string]interface{}{"email": "nope", "amount": "ten", "status": "pending"},
+			want:  []string{"amount:format", "email:format", "status:enum"},
+		},
+		{
+			name:  "type, length and items",
+			value: map[string]interface{}{"email": "a@b.co", "amount": 1, "count": 1.5, "code": "abc", "tags": []interface{}{""}},
+			want:  []string{"code:maxLength", "count:type", "tags[0]:minLength"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range sch.Validate(tt.value) {
+				got = append(got, v.Field+":"+v.Rule)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{name: "empty", raw: ``},
+		{name: "null", raw: `null`},
+		{name: "object", raw: `{"type":"object","properties":{"code":{"type":"string","pattern":"^[0-9]{6}$"}},"required":["code"]}`},
+		{name: "not json", raw: `{`, wantErr: true},
+		{name: "unknown type", raw: `{"type":"date"}`, wantErr: true},
+		{name: "unknown format", raw: `{"type":"string","format":"phone"}`, wantErr: true},
+		{name: "bad pattern", raw: `{"properties":{"code":{"pattern":"[0-9"}}}`, wantErr: true},
+		{name: "null property", raw: `{"properties":{"code":null}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseCompilesPattern(t *testing.T) {
+	sch, err := Parse([]byte(`{"type":"object","properties":{"code":{"type":"string","pattern":"^[0-9]{6}$"}}}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if sch.Properties["code"].re == nil {
+		t.Fatal("pattern was not compiled by Parse")
+	}
+	if v := sch.Validate(map[string]interface{}{"code": "12a456"}); len(v) != 1 || v[0].Rule != "pattern" {
+		t.Errorf("violations = %+v, want one pattern violation", v)
+	}
+}
+
+func TestBadPatternIsReported(t *testing.T) {
+	sch := &Schema{Type: "string", Pattern: "[0-9"}
+	if v := sch.Validate("123"); len(v) != 1 || v[0].Rule != "pattern" {
+		t.Errorf("violations = %+v, want the bad pattern reported", v)
+	}
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"notify-service/internal/email/templates"
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAckNotRequired = errors.New("notification does not require acknowledgement")
+	ErrNotRecipient   = errors.New("notification not found in inbox")
+	ErrAckPending     = errors.New("notification must be acknowledged before it can be deleted")
+)
+
+// ackReminderBatch bounds how many reminders one SendAckReminders run sends.
+const ackReminderBatch = 500
+
+// marshalAckPolicy validates a per-notification ack policy for storage.
+func marshalAckPolicy(policy *models.AckPolicy) (datatypes.JSON, error) {
+	if policy == nil {
+		return nil, nil
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ack_policy: %w", err)
+	}
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ack_policy: %w", err)
+	}
+	return datatypes.JSON(b), nil
+}
+
+// ackPendingSQL matches recipient rows (columns qualified by alias) still
+// owed an acknowledgement: the notification requires one, is live, and the
+// recipient hasn't acknowledged it before it expired.
+func ackPendingSQL(alias string) string {
+	return alias + "acknowledged_at IS NULL AND " + alias + "expired_at IS NULL AND EXISTS (" +
+		"SELECT 1 FROM notifications ack_n WHERE ack_n.id = " + alias + "notification_id" +
+		" AND ack_n.requires_ack AND ack_n.retracted_at IS NULL AND ack_n.deleted_at IS NULL)"
+}
+
+// ackChannels is the policy's reminder channels, or the configured default.
+func (s *NotifyService) ackChannels(policy models.AckPolicy) []string {
+	if len(policy.Channels) > 0 {
+		return policy.Channels
+	}
+	var channels []string
+	for _, ch := range strings.Split(s.cfg.AckReminderChannels, ",") {
+		if ch = strings.TrimSpace(ch); ch != "" {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// Acknowledge records that the user acknowledged a notification, from which
+// device and when, and marks it read. Acknowledging twice keeps the first.
+func (s *NotifyService) Acknowledge(ctx context.Context, userID uuid.UUID, deviceID string, notificationID uuid.UUID) (*models.NotificationRecipient, error) {
+	var notif models.Notification
+	if err := s.db.WithContext(ctx).Select("id", "requires_ack").First(&notif, "id = ?", notificationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotRecipient
+		}
+		return nil, err
+	}
+	if !notif.RequiresAck {
+		return nil, ErrAckNotRequired
+	}
+
+	now := time.Now()
+	unread := unreadSQL("notification_recipients.")
+	updates := map[string]interface{}{
+		"acknowledged_at": now,
+		"updated_at":      now,
+		"status":          gorm.Expr("CASE WHEN "+unread+" THEN ? ELSE status END", models.RecipientStatusRead),
+		"read_at":         gorm.Expr("CASE WHEN "+unread+" THEN ? ELSE read_at END", now),
+	}
+	if deviceID != "" {
+		updates["ack_device_id"] = deviceID
+	}
+	var acknowledged bool
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.NotificationRecipient{}).
+			Where("user_id = ? AND notification_id = ? AND acknowledged_at IS NULL", userID, notificationID).
+			Updates(updates)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		acknowledged = true
+		return recordInboxChange(tx, &userID, deviceID, SyncInboxState, []uuid.UUID{notificationID})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var recipient models.NotificationRecipient
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND notification_id = ?", userID, notificationID).
+		First(&recipient).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotRecipient
+		}
+		return nil, err
+	}
+	if acknowledged {
+		log.Printf("✅ [ACK] User %s acknowledged %s", userID, notificationID)
+		s.RequestSync(userID, deviceID, SyncInboxState, []uuid.UUID{notificationID})
+	}
+	return &recipient, nil
+}
+
+// SendAckReminders reminds recipients who haven't acknowledged a notification
+// once its reminder interval has passed since delivery (or the last
+// reminder), until the reminder limit. Snoozed items aren't reminded until
+// they wake; archived ones still are, since archiving isn't acknowledging.
+// Rows are claimed with SKIP LOCKED so replicas don't remind twice.
+func (s *NotifyService) SendAckReminders(ctx context.Context) error {
+	now := time.Now()
+	var due []models.NotificationRecipient
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("notification_recipients nr").
+			Select("nr.*").
+			Joins("INNER JOIN notifications n ON n.id = nr.notification_id AND n.deleted_at IS NULL AND n.retracted_at IS NULL").
+			Where("n.requires_ack AND nr.acknowledged_at IS NULL AND nr.delivered_at IS NOT NULL AND nr.expired_at IS NULL").
+			Where(awakeSQL("nr.")).
+			Where("nr.ack_reminders < COALESCE((n.ack_policy->>'max_reminders')::int, ?)", s.cfg.AckReminderMax).
+			Where(`COALESCE(nr.ack_reminded_at, nr.delivered_at) <= ?::timestamptz -
+				make_interval(hours => COALESCE(NULLIF((n.ack_policy->>'reminder_interval_hours')::int, 0), ?))`,
+				now, s.cfg.AckReminderIntervalHours).
+			Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "nr"}, Options: "SKIP LOCKED"}).
+			Limit(ackReminderBatch).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(due))
+		for i, r := range due {
+			ids[i] = r.ID
+		}
+		return tx.Model(&models.NotificationRecipient{}).
+			Where("id IN ?", ids).
+			UpdateColumns(map[string]interface{}{
+				"ack_reminders":   gorm.Expr("ack_reminders + 1"),
+				"ack_reminded_at": now,
+			}).Error
+	})
+	if err != nil || len(due) == 0 {
+		return err
+	}
+
+	byNotification := make(map[uuid.UUID][]models.NotificationRecipient)
+	for _, r := range due {
+		byNotification[r.NotificationID] = append(byNotification[r.NotificationID], r)
+	}
+	for notifID, recipients := range byNotification {
+		var notif models.Notification
+		if err := s.db.WithContext(ctx).First(&notif, "id = ?", notifID).Error; err != nil {
+			log.Printf("⚠️ [ACK] Can't remind for %s: %v", notifID, err)
+			continue
+		}
+		policy, err := models.ParseAckPolicy(notif.AckPolicy)
+		if err != nil {
+			log.Printf("⚠️ [ACK] Ignoring bad ack_policy on notification %s: %v", notifID, err)
+		}
+		for _, ch := range s.ackChannels(policy) {
+			for _, r := range recipients {
+				switch ch {
+				case models.AckChannelPush:
+					reminder := notif
+					reminder.Title = "Reminder: " + notif.Title
+					go s.sendPushNotificationToUser(r.UserID, &reminder)
+				case models.AckChannelEmail:
+					s.sendAckReminderEmail(r.UserID, &notif)
+				}
+			}
+		}
+	}
+	log.Printf("🔔 [ACK] Sent %d acknowledgement reminder(s) for %d notification(s)", len(due), len(byNotification))
+	return nil
+}
+
+// sendAckReminderEmail emails an acknowledgement reminder (async).
+func (s *NotifyService) sendAckReminderEmail(userID uuid.UUID, notif *models.Notification) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var user models.User
+		if err := s.db.WithContext(ctx).Where("id = ?", userID.String()).First(&user).Error; err != nil || user.Email == "" {
+			log.Printf("⚠️ [ACK] No email on file for user %s, skipping reminder email", userID)
+			return
+		}
+		actionURL := ""
+		if notif.ContentLink != nil {
+			actionURL = *notif.ContentLink
+		}
+		body, err := templates.RenderAckReminderEmail(templates.AckReminderData{
+			UserName:  user.Username,
+			Heading:   notif.Heading,
+			Title:     notif.Title,
+			Message:   notif.Message,
+			ActionURL: actionURL,
+		})
+		if err != nil {
+			log.Printf("❌ [ACK] Render reminder failed for user %s: %v", userID, err)
+			return
+		}
+		if err := s.emailSender.Send(ctx, user.Email, "Action required: "+notif.Title, body); err != nil {
+			log.Printf("⚠️ [ACK] Reminder email failed for user %s: %v", userID, err)
+		}
+	}()
+}
+
+// ListOutstandingAcks pages ack-required notifications that some recipients
+// haven't acknowledged yet, newest first.
+func (s *NotifyService) ListOutstandingAcks(ctx context.Context, page PageRequest) (Page[*models.AckSummary], error) {
+	query := s.db.WithContext(ctx).
+		Table("notifications n").
+		Select(`n.id AS notification_id, n.title, n.created_at,
+			COUNT(nr.id) AS recipients,
+			COUNT(nr.acknowledged_at) AS acknowledged,
+			COUNT(nr.id) - COUNT(nr.acknowledged_at) AS outstanding`).
+		Joins("INNER JOIN notification_recipients nr ON nr.notification_id = n.id AND nr.delivered_at IS NOT NULL").
+		Where("n.requires_ack AND n.deleted_at IS NULL AND n.retracted_at IS NULL").
+		Group("n.id, n.title, n.created_at").
+		Having("COUNT(nr.id) > COUNT(nr.acknowledged_at)")
+	var rows []*models.AckSummary
+	if err := keyset(query, "n.created_at", "n.id", page).Scan(&rows).Error; err != nil {
+		return Page[*models.AckSummary]{}, err
+	}
+	return paginate(rows, page, func(a *models.AckSummary) Cursor {
+		return Cursor{At: a.CreatedAt, ID: a.NotificationID}
+	}), nil
+}
+
+// GetNotificationAcks pages a notification's recipients with their
+// acknowledgement state, latest delivery first. acknowledged filters to
+// recipients who have (true) or haven't (false) acknowledged.
+func (s *NotifyService) GetNotificationAcks(ctx context.Context, notifID uuid.UUID, acknowledged *bool, page PageRequest) (Page[*models.AckView], error) {
+	query := s.db.WithContext(ctx).
+		Table("notification_recipients nr").
+		Select(`nr.id AS recipient_id, nr.user_id, COALESCE(u.username, 'unknown') AS username,
+			COALESCE(u.email, '') AS email, nr.delivered_at, nr.acknowledged_at, nr.ack_device_id,
+			nr.ack_reminders AS reminders_sent, nr.ack_reminded_at AS last_reminder_at`).
+		Joins("LEFT JOIN users u ON u.id = nr.user_id::text").
+		Where("nr.notification_id = ? AND nr.delivered_at IS NOT NULL", notifID)
+	if acknowledged != nil {
+		if *acknowledged {
+			query = query.Where("nr.acknowledged_at IS NOT NULL")
+		} else {
+			query = query.Where("nr.acknowledged_at IS NULL")
+		}
+	}
+	var rows []*ackRow
+	if err := keyset(query, "nr.delivered_at", "nr.id", page).Scan(&rows).Error; err != nil {
+		return Page[*models.AckView]{}, err
+	}
+	result := paginate(rows, page, func(r *ackRow) Cursor {
+		return Cursor{At: *r.DeliveredAt, ID: r.RecipientID}
+	})
+	views := make([]*models.AckView, len(result.Items))
+	for i, r := range result.Items {
+		views[i] = &r.AckView
+	}
+	return Page[*models.AckView]{Items: views, NextCursor: result.NextCursor, PrevCursor: result.PrevCursor}, nil
+}
+
+// ackRow is an AckView plus the recipient ID it pages on.
+type ackRow struct {
+	models.AckView `gorm:"embedded"`
+	RecipientID    uuid.UUID
+}
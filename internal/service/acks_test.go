@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+func TestAckPendingItemsCantBeDeleted(t *testing.T) {
+	s := newTestService(t, nil)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	notif := publishTestNotification(t, s, models.NotificationRequest{RequiresAck: true}, userID)
+
+	if _, err := s.DeleteForUser(ctx, userID, "", []uuid.UUID{notif.ID}); !errors.Is(err, ErrAckPending) {
+		t.Errorf("deleting it: err = %v, want ErrAckPending", err)
+	}
+	if n, err := s.DeleteForUser(ctx, userID, "", nil); err != nil || n != 0 {
+		t.Errorf("clearing the inbox: removed %d, err %v; want it skipped", n, err)
+	}
+	testRecipient(t, s, notif.ID, userID) // still there
+
+	if _, err := s.Acknowledge(ctx, userID, "phone", notif.ID); err != nil {
+		t.Fatalf("Acknowledge: %v", err)
+	}
+	r := testRecipient(t, s, notif.ID, userID)
+	if r.AcknowledgedAt == nil || r.AckDeviceID == nil || *r.AckDeviceID != "phone" || r.Status != models.RecipientStatusRead {
+		t.Errorf("after ack: acknowledged_at %v, device %v, status %q", r.AcknowledgedAt, r.AckDeviceID, r.Status)
+	}
+	if n, err := s.DeleteForUser(ctx, userID, "", []uuid.UUID{notif.ID}); err != nil || n != 1 {
+		t.Errorf("deleting it after ack: removed %d, err %v", n, err)
+	}
+}
+
+func TestAckRemindersHoldWhileSnoozed(t *testing.T) {
+	s := newTestService(t, nil)
+	s.cfg.AckReminderMax = 3
+	s.cfg.AckReminderIntervalHours = 24
+	ctx := context.Background()
+
+	awake, snoozed := uuid.New(), uuid.New()
+	notif := publishTestNotification(t, s, models.NotificationRequest{RequiresAck: true}, awake, snoozed)
+	if _, err := s.ApplyInboxAction(ctx, snoozed, "", InboxSnooze, []uuid.UUID{notif.ID},
+		SnoozeOptions{Until: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("snooze: %v", err)
+	}
+	// Delivered two days ago, so a reminder is due
+	if err := s.db.Model(&models.NotificationRecipient{}).
+		Where("notification_id = ?", notif.ID).
+		UpdateColumn("delivered_at", time.Now().Add(-48*time.Hour)).Error; err != nil {
+		t.Fatalf("backdate delivery: %v", err)
+	}
+
+	if err := s.SendAckReminders(ctx); err != nil {
+		t.Fatalf("SendAckReminders: %v", err)
+	}
+	if r := testRecipient(t, s, notif.ID, awake); r.AckReminders != 1 {
+		t.Errorf("awake recipient: %d reminder(s), want 1", r.AckReminders)
+	}
+	if r := testRecipient(t, s, notif.ID, snoozed); r.AckReminders != 0 {
+		t.Errorf("snoozed recipient: %d reminder(s), want 0", r.AckReminders)
+	}
+}
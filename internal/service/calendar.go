@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"notify-service/internal/calendar"
+	"notify-service/internal/email"
+	"notify-service/internal/email/templates"
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// calendarEventMethods maps system events that carry a match calendar invite
+// to the iTIP method of the generated .ics.
+var calendarEventMethods = map[string]calendar.Method{
+	"match.created":   calendar.MethodRequest,
+	"match.updated":   calendar.MethodRequest,
+	"match.cancelled": calendar.MethodCancel,
+}
+
+// IsCalendarEvent reports whether a system event should produce a calendar invite.
+func IsCalendarEvent(eventKey string) bool {
+	_, ok := calendarEventMethods[eventKey]
+	return ok
+}
+
+// PrepareCalendarInvite renders the .ics for a match event from its template
+// variables. Re-issuing for the same match_id keeps the UID and bumps SEQUENCE
+// so calendars update (or cancel) the same entry. Nothing is stored until
+// SaveCalendarInvite, so a delivery that fails doesn't use up a SEQUENCE.
+//
+// Variables: match_id (required), start_time (RFC3339, required unless a
+// previous invite exists), duration_minutes, title, join_url, game_name,
+// opponent_name.
+func (s *NotifyService) PrepareCalendarInvite(ctx context.Context, eventKey string, userID uuid.UUID, vars map[string]interface{}) (*models.CalendarInvite, error) {
+	method, ok := calendarEventMethods[eventKey]
+	if !ok {
+		return nil, fmt.Errorf("event %s does not support calendar invites", eventKey)
+	}
+	matchID := getString(vars["match_id"])
+	if matchID == "" {
+		return nil, fmt.Errorf("match_id is required for calendar invites")
+	}
+	uid := calendar.MatchUID(matchID)
+
+	var invite models.CalendarInvite
+	err := s.db.WithContext(ctx).Where("uid = ? AND user_id = ?", uid, userID).First(&invite).Error
+	exists := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("calendar invite lookup failed: %w", err)
+	}
+
+	start := invite.StartsAt
+	if raw := getString(vars["start_time"]); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("start_time must be RFC3339 for calendar invites: %w", err)
+		}
+		start = t
+	}
+	if start.IsZero() {
+		return nil, fmt.Errorf("start_time is required for calendar invites")
+	}
+
+	duration := calendar.DefaultDuration
+	if exists {
+		duration = invite.EndsAt.Sub(invite.StartsAt)
+	}
+	if minutes := getInt(vars["duration_minutes"]); minutes > 0 {
+		duration = time.Duration(minutes) * time.Minute
+	}
+
+	summary := getString(vars["title"])
+	if summary == "" {
+		summary = matchSummary(getString(vars["game_name"]), getString(vars["opponent_name"]))
+	}
+	joinURL := getString(vars["join_url"])
+	if joinURL == "" && invite.JoinURL != nil {
+		joinURL = *invite.JoinURL
+	}
+
+	sequence := 0
+	if exists {
+		sequence = invite.Sequence + 1
+	}
+
+	var user models.User
+	attendee := ""
+	if err := s.db.WithContext(ctx).Where("id = ?", userID.String()).First(&user).Error; err == nil {
+		attendee = user.Email
+	}
+
+	content := calendar.Build(calendar.Event{
+		UID:            uid,
+		Sequence:       sequence,
+		Method:         method,
+		Start:          start,
+		Duration:       duration,
+		Summary:        summary,
+		Description:    matchDescription(summary, joinURL),
+		URL:            joinURL,
+		OrganizerName:  s.cfg.SMTPFromName,
+		OrganizerEmail: s.cfg.SMTPFrom,
+		AttendeeEmail:  attendee,
+	})
+
+	if !exists {
+		invite.ID = uuid.New() // the action link needs it before the save
+	}
+	invite.UID = uid
+	invite.UserID = userID
+	invite.EventKey = eventKey
+	invite.Method = string(method)
+	invite.Sequence = sequence
+	invite.Summary = summary
+	invite.StartsAt = start
+	invite.EndsAt = start.Add(duration)
+	invite.Content = content
+	if joinURL != "" {
+		invite.JoinURL = &joinURL
+	}
+	return &invite, nil
+}
+
+// SaveCalendarInvite stores a prepared invite for download, once the
+// notification carrying it has been delivered.
+func (s *NotifyService) SaveCalendarInvite(ctx context.Context, invite *models.CalendarInvite) error {
+	if err := s.db.WithContext(ctx).Save(invite).Error; err != nil {
+		return fmt.Errorf("failed to save calendar invite: %w", err)
+	}
+	log.Printf("📅 [CALENDAR] %s invite %s (seq %d) for user %s", invite.Method, invite.UID, invite.Sequence, invite.UserID)
+	return nil
+}
+
+// CalendarInviteLink is the in-app download link for an invite.
+func (s *NotifyService) CalendarInviteLink(invite *models.CalendarInvite) string {
+	return fmt.Sprintf("%s/user/%s/calendar/%s.ics",
+		strings.TrimSuffix(s.cfg.CalendarLinkBaseURL, "/"), invite.UserID, invite.ID)
+}
+
+// CalendarActionLink is the action button added to the in-app notification.
+func (s *NotifyService) CalendarActionLink(invite *models.CalendarInvite) models.ActionLink {
+	label := "Add to Calendar"
+	if invite.Method == string(calendar.MethodCancel) {
+		label = "Remove from Calendar"
+	}
+	return models.ActionLink{Label: label, URL: s.CalendarInviteLink(invite), Style: "secondary"}
+}
+
+// GetCalendarInvite returns a user's invite by ID.
+func (s *NotifyService) GetCalendarInvite(ctx context.Context, userID, inviteID uuid.UUID) (*models.CalendarInvite, error) {
+	var invite models.CalendarInvite
+	err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", inviteID, userID).First(&invite).Error
+	return &invite, err
+}
+
+// SendCalendarInviteEmail emails the invite as a text/calendar attachment (async).
+func (s *NotifyService) SendCalendarInviteEmail(invite *models.CalendarInvite, vars map[string]interface{}) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var user models.User
+		if err := s.db.WithContext(ctx).Where("id = ?", invite.UserID.String()).First(&user).Error; err != nil || user.Email == "" {
+			log.Printf("⚠️ [CALENDAR] No email on file for user %s, skipping invite email", invite.UserID)
+			return
+		}
+
+		cancelled := invite.Method == string(calendar.MethodCancel)
+		joinURL := ""
+		if invite.JoinURL != nil {
+			joinURL = *invite.JoinURL
+		}
+		userName := getString(vars["user_name"])
+		if userName == "" {
+			userName = user.Username
+		}
+		body, err := templates.RenderMatchInviteEmail(templates.MatchInviteData{
+			UserName:        userName,
+			GameName:        getString(vars["game_name"]),
+			OpponentName:    getString(vars["opponent_name"]),
+			StartTime:       invite.StartsAt.UTC().Format("Mon, 02 Jan 2006 15:04 MST"),
+			DurationMinutes: int(invite.EndsAt.Sub(invite.StartsAt).Minutes()),
+			JoinURL:         joinURL,
+			Updated:         invite.Sequence > 0,
+			Cancelled:       cancelled,
+		})
+		if err != nil {
+			log.Printf("❌ [CALENDAR] Render match invite failed for user %s: %v", invite.UserID, err)
+			return
+		}
+
+		subject := "📅 " + invite.Summary
+		switch {
+		case cancelled:
+			subject = "❌ Cancelled: " + invite.Summary
+		case invite.Sequence > 0:
+			subject = "📅 Updated: " + invite.Summary
+		}
+
+		attachment := email.Attachment{
+			Filename:    "invite.ics",
+			ContentType: calendar.ContentType(calendar.Method(invite.Method)),
+			Content:     []byte(invite.Content),
+		}
+		if err := s.emailSender.SendWithAttachments(ctx, user.Email, subject, body, attachment); err != nil {
+			log.Printf("⚠️ [CALENDAR] Invite email failed for user %s: %v", invite.UserID, err)
+		}
+	}()
+}
+
+func matchSummary(gameName, opponentName string) string {
+	summary := "MusterBox match"
+	if gameName != "" {
+		summary += ": " + gameName
+	}
+	if opponentName != "" {
+		summary += " vs " + opponentName
+	}
+	return summary
+}
+
+func matchDescription(summary, joinURL string) string {
+	description := summary + " (scheduled via MusterBox)."
+	if joinURL != "" {
+		description += "\nJoin: " + joinURL
+	}
+	return description
+}
+
+// getInt extracts an int from JSON numbers or numeric strings.
+func getInt(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	case string:
+		i, _ := strconv.Atoi(strings.TrimSpace(n))
+		return i
+	}
+	return 0
+}
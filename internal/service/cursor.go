@@ -0,0 +1,119 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is an opaque keyset position: the sort key and ID of the row a page
+// starts after. Prev cursors page towards newer rows. Rank is set for search
+// results, which sort by relevance first.
+type Cursor struct {
+	At   time.Time `json:"t"`
+	ID   uuid.UUID `json:"id"`
+	Rank float32   `json:"r,omitempty"`
+	Prev bool      `json:"p,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == uuid.Nil || c.At.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// PageRequest selects one page of a newest-first list. Offset is only honoured
+// when no cursor is given, for clients that predate cursors.
+type PageRequest struct {
+	Limit  int
+	Offset int
+	Cursor *Cursor
+}
+
+// Page is one page of results with cursors to its neighbours ("" = none).
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+	PrevCursor string
+}
+
+// keyset orders a query newest-first by (sortCol, idCol) and applies the
+// page window, fetching one extra row to detect whether more follow.
+func keyset(query *gorm.DB, sortCol, idCol string, page PageRequest) *gorm.DB {
+	var after []interface{}
+	if page.Cursor != nil {
+		after = []interface{}{page.Cursor.At, page.Cursor.ID}
+	}
+	return keysetOn(query, []string{sortCol, idCol}, after, page)
+}
+
+// rankedKeyset is keyset for search results: best match first, then newest.
+func rankedKeyset(query *gorm.DB, rankExpr, sortCol, idCol string, page PageRequest) *gorm.DB {
+	var after []interface{}
+	if page.Cursor != nil {
+		after = []interface{}{page.Cursor.Rank, page.Cursor.At, page.Cursor.ID}
+	}
+	return keysetOn(query, []string{rankExpr, sortCol, idCol}, after, page)
+}
+
+func keysetOn(query *gorm.DB, cols []string, after []interface{}, page PageRequest) *gorm.DB {
+	tuple := "(" + strings.Join(cols, ", ") + ")"
+	params := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ") + ")"
+	switch {
+	case page.Cursor != nil && page.Cursor.Prev:
+		query = query.Where(tuple+" > "+params, after...).
+			Order(strings.Join(cols, " ASC, ") + " ASC")
+	case page.Cursor != nil:
+		query = query.Where(tuple+" < "+params, after...).
+			Order(strings.Join(cols, " DESC, ") + " DESC")
+	default:
+		query = query.Order(strings.Join(cols, " DESC, ") + " DESC").Offset(page.Offset)
+	}
+	return query.Limit(page.Limit + 1)
+}
+
+// paginate trims rows fetched by keyset to the page and builds its cursors.
+func paginate[T any](rows []T, page PageRequest, key func(T) Cursor) Page[T] {
+	backward := page.Cursor != nil && page.Cursor.Prev
+	more := len(rows) > page.Limit
+	if more {
+		rows = rows[:page.Limit]
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	result := Page[T]{Items: rows}
+	if len(rows) == 0 {
+		return result
+	}
+	if more || backward {
+		result.NextCursor = key(rows[len(rows)-1]).Encode()
+	}
+	if (backward && more) || (!backward && (page.Cursor != nil || page.Offset > 0)) {
+		prev := key(rows[0])
+		prev.Prev = true
+		result.PrevCursor = prev.Encode()
+	}
+	return result
+}
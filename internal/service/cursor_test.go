@@ -0,0 +1,77 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 30, 0, 123456000, time.UTC)
+	for _, c := range []Cursor{
+		{At: at, ID: uuid.New()},
+		{At: at, ID: uuid.New(), Prev: true},
+		{At: at, ID: uuid.New(), Rank: 0.25},
+	} {
+		got, err := DecodeCursor(c.Encode())
+		if err != nil {
+			t.Fatalf("DecodeCursor(%+v): %v", c, err)
+		}
+		if !got.At.Equal(c.At) || got.ID != c.ID || got.Rank != c.Rank || got.Prev != c.Prev {
+			t.Errorf("round trip = %+v, want %+v", *got, c)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	for name, s := range map[string]string{
+		"empty":      "",
+		"not base64": "!!!",
+		"not json":   base64.RawURLEncoding.EncodeToString([]byte("nope")),
+		"no id":      base64.RawURLEncoding.EncodeToString([]byte(`{"t":"2026-03-01T00:00:00Z"}`)),
+		"no time":    base64.RawURLEncoding.EncodeToString([]byte(`{"id":"` + uuid.NewString() + `"}`)),
+	} {
+		if _, err := DecodeCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	key := func(i int) Cursor {
+		return Cursor{At: base.Add(time.Duration(i) * time.Minute), ID: uuid.NewSHA1(uuid.Nil, []byte{byte(i)})}
+	}
+
+	// First page: one extra row fetched means there is a next page, no prev
+	first := paginate([]int{5, 4, 3}, PageRequest{Limit: 2}, key)
+	if len(first.Items) != 2 || first.Items[1] != 4 {
+		t.Fatalf("first page = %v", first.Items)
+	}
+	if first.NextCursor == "" || first.PrevCursor != "" {
+		t.Fatalf("first page cursors: next=%q prev=%q", first.NextCursor, first.PrevCursor)
+	}
+	next, err := DecodeCursor(first.NextCursor)
+	if err != nil || next.ID != key(4).ID || next.Prev {
+		t.Fatalf("next cursor = %+v, %v", next, err)
+	}
+
+	// Last page reached going forward: prev only
+	last := paginate([]int{2, 1}, PageRequest{Limit: 2, Cursor: next}, key)
+	if last.NextCursor != "" || last.PrevCursor == "" {
+		t.Fatalf("last page cursors: next=%q prev=%q", last.NextCursor, last.PrevCursor)
+	}
+
+	// Backward pages arrive oldest-first and are flipped back to newest-first
+	prev, _ := DecodeCursor(last.PrevCursor)
+	back := paginate([]int{3, 4, 5}, PageRequest{Limit: 2, Cursor: prev}, key)
+	if len(back.Items) != 2 || back.Items[0] != 4 || back.Items[1] != 3 {
+		t.Fatalf("backward page = %v, want [4 3]", back.Items)
+	}
+	if back.NextCursor == "" || back.PrevCursor == "" {
+		t.Fatalf("backward page cursors: next=%q prev=%q", back.NextCursor, back.PrevCursor)
+	}
+}
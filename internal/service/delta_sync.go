@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidSyncToken = errors.New("invalid sync token")
+
+// syncTokenPrefix versions the token format so it can change later.
+const syncTokenPrefix = "s1:"
+
+// inboxEventSettleTime is how old an event must be before every event with a
+// lower ID is assumed committed. Events are written in the transaction making
+// the change, and concurrent transactions can commit their IDs out of order;
+// the service's transactions are short, and the margin covers clock skew
+// between replicas.
+const inboxEventSettleTime = 30 * time.Second
+
+// EncodeSyncToken wraps an inbox event ID as an opaque token.
+func EncodeSyncToken(eventID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(syncTokenPrefix + strconv.FormatInt(eventID, 10)))
+}
+
+func decodeSyncToken(token string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(b), syncTokenPrefix) {
+		return 0, ErrInvalidSyncToken
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(string(b), syncTokenPrefix), 10, 64)
+	if err != nil || id < 0 {
+		return 0, ErrInvalidSyncToken
+	}
+	return id, nil
+}
+
+// GetInboxDelta replays the inbox event log after token and reports the
+// current state of every item touched since: upserts, read-state changes and
+// tombstones for items no longer in the inbox. An empty or expired token
+// returns Reset with a fresh token; the client reloads its inbox first.
+func (s *NotifyService) GetInboxDelta(ctx context.Context, userID uuid.UUID, token string, limit int) (*models.InboxDelta, error) {
+	delta := &models.InboxDelta{
+		Upserts:    []*models.InboxItem{},
+		ReadState:  []models.ReadStateChange{},
+		Tombstones: []models.Tombstone{},
+	}
+	latest, err := s.LatestInboxEventID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	settled, err := s.settledInboxEventID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Reset tokens start at the settled point: events after it may be
+	// replayed over the reloaded inbox, which is harmless, but none is missed
+	if token == "" {
+		delta.Reset, delta.SyncToken = true, EncodeSyncToken(settled)
+		return delta, nil
+	}
+	afterID, err := decodeSyncToken(token)
+	if err != nil {
+		return nil, err
+	}
+	oldest, err := s.OldestInboxEventID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if syncTokenExpired(afterID, oldest, latest) {
+		delta.Reset, delta.SyncToken = true, EncodeSyncToken(settled)
+		return delta, nil
+	}
+
+	events, err := s.InboxEventsAfter(ctx, &userID, afterID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > limit {
+		events, delta.HasMore = events[:limit], true
+	}
+
+	// Which items were touched, and whether only their read state changed
+	readOnly := make(map[uuid.UUID]bool)
+	lastTouched := make(map[uuid.UUID]int)
+	var ids []uuid.UUID
+	for i, ev := range events {
+		// Preference changes reach devices by push; the inbox is unaffected
+		if ev.Type == string(SyncPreferences) {
+			continue
+		}
+		var data struct {
+			NotificationIDs []uuid.UUID `json:"notification_ids"`
+		}
+		_ = json.Unmarshal(ev.Data, &data)
+		if len(data.NotificationIDs) == 0 {
+			// A change we can't attribute to items; only a reload is safe
+			delta.Reset, delta.SyncToken = true, EncodeSyncToken(settled)
+			return delta, nil
+		}
+		for _, id := range data.NotificationIDs {
+			if _, seen := readOnly[id]; !seen {
+				ids = append(ids, id)
+				readOnly[id] = true
+			}
+			readOnly[id] = readOnly[id] && ev.Type == string(SyncReadState)
+			lastTouched[id] = i
+		}
+	}
+
+	if len(ids) > 0 {
+		items, err := s.findInbox(s.inboxQuery(ctx, userID).Where("notifications.id IN ?", ids))
+		if err != nil {
+			return nil, err
+		}
+		present := make(map[uuid.UUID]*models.InboxItem, len(items))
+		for _, item := range items {
+			present[item.Notification.ID] = item
+		}
+		for _, id := range ids {
+			item, ok := present[id]
+			switch {
+			case !ok:
+				delta.Tombstones = append(delta.Tombstones, models.Tombstone{
+					NotificationID: id,
+					DeletedAt:      events[lastTouched[id]].CreatedAt,
+				})
+			case readOnly[id]:
+				delta.ReadState = append(delta.ReadState, models.ReadStateChange{
+					NotificationID: id,
+					Status:         item.Delivery.Status,
+					ReadAt:         item.Delivery.ReadAt,
+				})
+			default:
+				delta.Upserts = append(delta.Upserts, item)
+			}
+		}
+	}
+
+	var lastReturned int64
+	if len(events) > 0 {
+		lastReturned = events[len(events)-1].ID
+	}
+	delta.SyncToken = EncodeSyncToken(nextSyncPosition(afterID, lastReturned, settled, delta.HasMore))
+	return delta, nil
+}
+
+// syncTokenExpired reports whether a token can no longer be replayed: it is
+// ahead of the log (from another database) or the events after it were trimmed.
+func syncTokenExpired(afterID, oldest, latest int64) bool {
+	return afterID > latest || (afterID > 0 && (oldest == 0 || afterID < oldest-1))
+}
+
+// nextSyncPosition is where the next delta starts: the settled point, or the
+// last event returned if that comes first, since a lower ID may still commit
+// after unsettled events. Unsettled events are replayed next time, which is
+// harmless. Only a full page of unsettled events moves past the settled
+// point, so paging can't stall. It never moves back.
+func nextSyncPosition(afterID, lastReturned, settled int64, hasMore bool) int64 {
+	next := settled
+	if hasMore && lastReturned < next {
+		next = lastReturned
+	}
+	if next <= afterID {
+		next = afterID
+		if hasMore {
+			next = lastReturned
+		}
+	}
+	return next
+}
+
+// settledInboxEventID is the newest event old enough that every event before
+// it has committed (0 if none).
+func (s *NotifyService) settledInboxEventID(ctx context.Context) (int64, error) {
+	var id *int64
+	if err := s.db.WithContext(ctx).Model(&models.InboxEvent{}).
+		Select("MAX(id)").
+		Where("created_at < NOW() - make_interval(secs => ?)", inboxEventSettleTime.Seconds()).
+		Scan(&id).Error; err != nil || id == nil {
+		return 0, err
+	}
+	return *id, nil
+}
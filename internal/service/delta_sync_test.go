@@ -0,0 +1,73 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestSyncTokenRoundTrip(t *testing.T) {
+	for _, id := range []int64{0, 1, 9876543210} {
+		got, err := decodeSyncToken(EncodeSyncToken(id))
+		if err != nil || got != id {
+			t.Errorf("decodeSyncToken(EncodeSyncToken(%d)) = %d, %v", id, got, err)
+		}
+	}
+}
+
+func TestDecodeSyncTokenRejectsGarbage(t *testing.T) {
+	for name, token := range map[string]string{
+		"not base64":     "!!!",
+		"no prefix":      base64.RawURLEncoding.EncodeToString([]byte("42")),
+		"future version": base64.RawURLEncoding.EncodeToString([]byte("s2:42")),
+		"not a number":   base64.RawURLEncoding.EncodeToString([]byte("s1:abc")),
+		"negative":       base64.RawURLEncoding.EncodeToString([]byte("s1:-1")),
+	} {
+		if _, err := decodeSyncToken(token); !errors.Is(err, ErrInvalidSyncToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidSyncToken", name, err)
+		}
+	}
+}
+
+func TestSyncTokenExpired(t *testing.T) {
+	tests := []struct {
+		name                    string
+		afterID, oldest, latest int64
+		want                    bool
+	}{
+		{name: "up to date", afterID: 100, oldest: 50, latest: 100},
+		{name: "behind within the log", afterID: 60, oldest: 50, latest: 100},
+		{name: "right before the oldest event", afterID: 49, oldest: 50, latest: 100},
+		{name: "events trimmed", afterID: 48, oldest: 50, latest: 100, want: true},
+		{name: "log emptied", afterID: 48, oldest: 0, latest: 0, want: true},
+		{name: "ahead of the log", afterID: 101, oldest: 50, latest: 100, want: true},
+		{name: "from the start of an empty log", afterID: 0, oldest: 0, latest: 0},
+	}
+	for _, tt := range tests {
+		if got := syncTokenExpired(tt.afterID, tt.oldest, tt.latest); got != tt.want {
+			t.Errorf("%s: syncTokenExpired(%d, %d, %d) = %v, want %v", tt.name, tt.afterID, tt.oldest, tt.latest, got, tt.want)
+		}
+	}
+}
+
+func TestNextSyncPosition(t *testing.T) {
+	tests := []struct {
+		name                           string
+		afterID, lastReturned, settled int64
+		hasMore                        bool
+		want                           int64
+	}{
+		{name: "nothing new, nothing settled", afterID: 10, want: 10},
+		{name: "skips other users' settled events", afterID: 10, settled: 40, want: 40},
+		{name: "unsettled events are replayed", afterID: 10, lastReturned: 25, settled: 20, want: 20},
+		{name: "more pages: stays at the last returned event", afterID: 10, lastReturned: 25, settled: 40, hasMore: true, want: 25},
+		{name: "more pages: stops at the settled point", afterID: 10, lastReturned: 25, settled: 20, hasMore: true, want: 20},
+		{name: "a full unsettled page still moves on", afterID: 10, lastReturned: 25, settled: 5, hasMore: true, want: 25},
+		{name: "never moves back", afterID: 50, settled: 40, want: 50},
+	}
+	for _, tt := range tests {
+		if got := nextSyncPosition(tt.afterID, tt.lastReturned, tt.settled, tt.hasMore); got != tt.want {
+			t.Errorf("%s: nextSyncPosition = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
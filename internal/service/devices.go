@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"notify-service/internal/fcm"
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RegisterFCMToken upserts the token for (user_id, device_id), refreshes
+// last_seen_at/app_version and revives a previously revoked device.
+func (s *NotifyService) RegisterFCMToken(ctx context.Context, userID uuid.UUID, deviceID, token, platform, appVersion, locale string) (*models.FCMToken, error) {
+	if platform == "" {
+		platform = "unknown"
+	}
+	var previous models.FCMToken
+	hadPrevious := s.db.WithContext(ctx).Unscoped().
+		Where("user_id = ? AND device_id = ?", userID, deviceID).
+		First(&previous).Error == nil
+
+	now := time.Now()
+	record := models.FCMToken{
+		UserID:     userID,
+		DeviceID:   deviceID,
+		Token:      token,
+		Platform:   platform,
+		AppVersion: appVersion,
+		Locale:     locale,
+		LastSeenAt: &now,
+	}
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "device_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"token":          token,
+			"platform":       platform,
+			"app_version":    appVersion,
+			"locale":         locale,
+			"last_seen_at":   now,
+			"revoked_reason": nil,
+			"deleted_at":     nil,
+			"updated_at":     now,
+			// A rotated token starts with no subscriptions; either way reconcile topics
+			"topics":              gorm.Expr("CASE WHEN fcm_tokens.token = EXCLUDED.token THEN fcm_tokens.topics ELSE '' END"),
+			"topics_synced_at":    nil,
+			"topic_sync_attempts": 0,
+			"topic_sync_next_at":  nil,
+		}),
+	}).Create(&record).Error
+	if err != nil {
+		return nil, fmt.Errorf("register FCM token: %w", err)
+	}
+	if hadPrevious && previous.Token != token && previous.Topics != "" {
+		go s.unsubscribeAll(previous.Token, splitTopics(previous.Topics))
+	}
+	go s.syncTopicsNow()
+
+	// The same FCM token can't belong to two devices/users — the old row is stale.
+	// Its subscriptions now belong to the new row, so nothing is unsubscribed.
+	if err := s.revokeTokens(ctx, models.TokenRevokedReassigned,
+		"token = ? AND NOT (user_id = ? AND device_id = ?)", token, userID, deviceID); err != nil {
+		log.Printf("⚠️ [FCM] Failed to clear stale duplicates of token for user %s: %v", userID, err)
+	}
+	return &record, nil
+}
+
+// UnregisterFCMToken revokes a device's token at the user's request.
+func (s *NotifyService) UnregisterFCMToken(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	return s.revokeTokens(ctx, models.TokenRevokedByUser, "user_id = ? AND device_id = ?", userID, deviceID)
+}
+
+// pruneTokens soft-deletes tokens FCM reported as permanently invalid.
+func (s *NotifyService) pruneTokens(failures []fcm.TokenFailure) {
+	if len(failures) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	byReason := make(map[string][]string)
+	for _, f := range failures {
+		byReason[f.Reason] = append(byReason[f.Reason], f.Token)
+	}
+	for reason, tokens := range byReason {
+		if err := s.revokeTokens(ctx, reason, "token IN ?", tokens); err != nil {
+			log.Printf("⚠️ [FCM] Failed to prune %d %s token(s): %v", len(tokens), reason, err)
+			continue
+		}
+		log.Printf("🧹 [FCM] Pruned %d token(s): %s", len(tokens), reason)
+	}
+}
+
+// ExpireInactiveTokens revokes tokens not refreshed within the configured
+// inactivity window. Tokens that have never reported last_seen_at are left
+// alone (legacy rows are backfilled at startup).
+func (s *NotifyService) ExpireInactiveTokens(ctx context.Context) error {
+	if s.cfg.FCMTokenInactivityDays <= 0 {
+		return nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -s.cfg.FCMTokenInactivityDays)
+	return s.revokeTokens(ctx, models.TokenRevokedExpired, "last_seen_at < ?", cutoff)
+}
+
+// revokeTokens soft-deletes active tokens matching the condition, recording why.
+// Tokens FCM rejected have no subscriptions left; tokens revoked by us are
+// still valid, so they are queued for unsubscription from broadcast topics.
+func (s *NotifyService) revokeTokens(ctx context.Context, reason string, query string, args ...interface{}) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"revoked_reason":      reason,
+		"deleted_at":          now,
+		"topics_synced_at":    nil,
+		"topic_sync_attempts": 0,
+		"topic_sync_next_at":  nil,
+	}
+	stillValid := reason == models.TokenRevokedByUser || reason == models.TokenRevokedExpired
+	if !stillValid {
+		updates["topics"] = ""
+		updates["topics_synced_at"] = now
+	}
+	result := s.db.WithContext(ctx).Model(&models.FCMToken{}).
+		Where(query, args...).
+		Updates(updates)
+	if result.Error == nil && result.RowsAffected > 0 && reason == models.TokenRevokedExpired {
+		log.Printf("🧹 [FCM] Expired %d inactive token(s)", result.RowsAffected)
+	}
+	if result.Error == nil && result.RowsAffected > 0 && stillValid {
+		go s.syncTopicsNow()
+	}
+	return result.Error
+}
+
+// ListUserDevices returns a user's registered devices, optionally including revoked ones.
+func (s *NotifyService) ListUserDevices(ctx context.Context, userID uuid.UUID, includeRevoked bool) ([]*models.DeviceView, error) {
+	query := s.db.WithContext(ctx)
+	if includeRevoked {
+		query = query.Unscoped()
+	}
+	var tokens []models.FCMToken
+	if err := query.Where("user_id = ?", userID).
+		Order("last_seen_at DESC NULLS LAST, updated_at DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	views := make([]*models.DeviceView, 0, len(tokens))
+	for _, t := range tokens {
+		v := &models.DeviceView{
+			ID:            t.ID,
+			DeviceID:      t.DeviceID,
+			Platform:      t.Platform,
+			AppVersion:    t.AppVersion,
+			Locale:        t.Locale,
+			Topics:        splitTopics(t.Topics),
+			TokenSuffix:   tokenSuffix(t.Token),
+			Active:        !t.DeletedAt.Valid,
+			LastSeenAt:    t.LastSeenAt,
+			RevokedReason: t.RevokedReason,
+			CreatedAt:     t.CreatedAt,
+			UpdatedAt:     t.UpdatedAt,
+		}
+		if t.DeletedAt.Valid {
+			revokedAt := t.DeletedAt.Time
+			v.RevokedAt = &revokedAt
+		}
+		views = append(views, v)
+	}
+	return views, nil
+}
+
+func tokenSuffix(token string) string {
+	if len(token) <= 6 {
+		return token
+	}
+	return "..." + token[len(token)-6:]
+}
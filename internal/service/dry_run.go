@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"notify-service/internal/fcm"
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PublishTarget is who a publish goes to: explicit users, a saved segment,
+// or (neither) everyone, with Audience narrowing a broadcast's push.
+type PublishTarget struct {
+	UserIDs   []uuid.UUID
+	SegmentID *uuid.UUID
+	Audience  *fcm.Audience
+}
+
+// EstimatePublish resolves a publish the way PublishNotification,
+// PublishToSegment and PublishBroadcast would and reports its reach, without
+// writing anything. Push reach applies the same rules as delivery: active
+// tokens, topic subscriptions (or the unsynced-device fallback) for
+// broadcasts, and presence suppression for users in the app right now.
+// Preferences, frequency caps and quiet hours are NOT applied: they are
+// enforced outside this service, so PushReachable is an upper bound and those
+// rules are only named in NotModelled.
+func (s *NotifyService) EstimatePublish(ctx context.Context, id uuid.UUID, target PublishTarget) (*models.PublishEstimate, error) {
+	var template models.Notification
+	if err := s.db.WithContext(ctx).Where("id = ? AND is_draft = true", id).First(&template).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("notification %s %w", id, ErrNotDraft)
+		}
+		return nil, err
+	}
+	if notificationExpired(&template) {
+		return nil, fmt.Errorf("notification %s %w at %s", id, ErrNotificationExpired, template.ExpiresAt.Format(time.RFC3339))
+	}
+	// Same fallback as PublishNotification: a scheduled segment, else everyone
+	if len(target.UserIDs) == 0 && target.SegmentID == nil && target.Audience == nil {
+		target.SegmentID = template.SegmentID
+	}
+
+	estimate := &models.PublishEstimate{
+		NotificationID: id,
+		NotModelled:    []string{models.SuppressedPreferences, models.SuppressedFrequencyCap, models.SuppressedQuietHours},
+		EstimatedAt:    time.Now(),
+	}
+	presence := s.presenceWindow() > 0 && !s.presenceExempt(&template)
+	deviceCond, deviceArgs := "TRUE", []interface{}{}
+	var targets *gorm.DB
+	switch {
+	case len(target.UserIDs) > 0:
+		estimate.Target = models.PublishTargetUsers
+		ids := make([]string, len(target.UserIDs))
+		for i, userID := range target.UserIDs {
+			ids[i] = userID.String()
+		}
+		targets = s.db.Raw("SELECT DISTINCT user_id FROM unnest(?::uuid[]) AS user_id", "{"+strings.Join(ids, ",")+"}")
+	case target.SegmentID != nil:
+		estimate.Target = models.PublishTargetSegment
+		estimate.SegmentID = target.SegmentID
+		_, def, err := s.loadSegment(ctx, *target.SegmentID)
+		if err != nil {
+			return nil, err
+		}
+		query, err := s.segmentQuery(ctx, def)
+		if err != nil {
+			return nil, err
+		}
+		targets = query.Select(segmentUserID + " AS user_id")
+	default:
+		estimate.Target = models.PublishTargetBroadcast
+		audience := fcm.Audience{}
+		if target.Audience != nil {
+			audience = *target.Audience
+		}
+		if _, _, err := audience.Target(); err != nil {
+			return nil, err
+		}
+		targets = s.db.Table("users u").
+			Select(segmentUserID + " AS user_id").
+			Where("u.deleted_at IS NULL AND " + segmentUserID + " IS NOT NULL")
+		// Devices the topic push misses get the fallback, if in the audience
+		topicCond, topicArgs := topicCondition(audience)
+		fallbackCond, fallbackArgs := fallbackCondition(audience)
+		deviceCond = "((" + topicCond + ") OR (" + fallbackCond + "))"
+		deviceArgs = append(topicArgs, fallbackArgs...)
+		// The push goes to a topic, so presence doesn't apply
+		presence = false
+	}
+
+	activeCond, activeArgs := "FALSE", []interface{}{}
+	if presence {
+		activeCond = "EXISTS (SELECT 1 FROM device_presence p WHERE p.user_id = tg.user_id AND p.state = ? AND p.last_seen_at > ?)"
+		activeArgs = []interface{}{models.PresenceActive, time.Now().Add(-s.presenceWindow())}
+	}
+	args := append([]interface{}{targets}, deviceArgs...)
+	args = append(args, activeArgs...)
+
+	var counts struct {
+		Total          int64
+		WithDevice     int64
+		TargetedDevice int64
+		ActiveInApp    int64
+		WithEmail      int64
+	}
+	if err := s.db.WithContext(ctx).Raw(`WITH targets AS (?)
+		SELECT COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE d.has_device) AS with_device,
+			COUNT(*) FILTER (WHERE d.targeted_device) AS targeted_device,
+			COUNT(*) FILTER (WHERE d.targeted_device AND d.active) AS active_in_app,
+			COUNT(*) FILTER (WHERE d.has_email) AS with_email
+		FROM (
+			SELECT
+				EXISTS (SELECT 1 FROM fcm_tokens t WHERE t.user_id = tg.user_id AND t.deleted_at IS NULL) AS has_device,
+				EXISTS (SELECT 1 FROM fcm_tokens t WHERE t.user_id = tg.user_id AND t.deleted_at IS NULL AND `+deviceCond+`) AS targeted_device,
+				`+activeCond+` AS active,
+				EXISTS (SELECT 1 FROM users u WHERE u.id = tg.user_id::text AND u.deleted_at IS NULL AND u.email <> '') AS has_email
+			FROM targets tg
+		) d`, args...).Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	estimate.Total = counts.Total
+	estimate.InApp = counts.Total
+	estimate.EmailReachable = counts.WithEmail
+	estimate.Suppressed = map[string]int64{
+		models.SuppressedPushDisabled: 0,
+		models.SuppressedNoDevice:     counts.Total - counts.WithDevice,
+		models.SuppressedAudience:     counts.WithDevice - counts.TargetedDevice,
+		models.SuppressedActiveInApp:  counts.ActiveInApp,
+	}
+	estimate.PushReachable = counts.TargetedDevice - counts.ActiveInApp
+	if s.push == nil {
+		estimate.Suppressed = map[string]int64{
+			models.SuppressedPushDisabled: counts.Total,
+			models.SuppressedNoDevice:     0,
+			models.SuppressedAudience:     0,
+			models.SuppressedActiveInApp:  0,
+		}
+		estimate.PushReachable = 0
+	}
+	return estimate, nil
+}
+
+// topicCondition is a condition on fcm_tokens t matching devices subscribed
+// to the topics a broadcast to audience is sent to (see fcm.Audience.Target).
+func topicCondition(audience fcm.Audience) (string, []interface{}) {
+	var platforms, locales []string
+	for _, p := range audience.Platforms {
+		platforms = append(platforms, fcm.PlatformTopic(p))
+	}
+	for _, l := range audience.Locales {
+		locales = append(locales, fcm.LocaleTopic(l))
+	}
+	groups := [][]string{platforms, locales}
+	if len(platforms) == 0 && len(locales) == 0 {
+		groups = [][]string{{fcm.TopicAll}}
+	}
+	var clauses []string
+	var args []interface{}
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		terms := make([]string, len(group))
+		for i, topic := range group {
+			terms[i] = "(',' || t.topics || ',') LIKE ?"
+			args = append(args, "%,"+escapeLike(topic)+",%")
+		}
+		clauses = append(clauses, "("+strings.Join(terms, " OR ")+")")
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// fallbackCondition is a condition on fcm_tokens t matching devices in the
+// audience by platform and locale (see fcm.Audience.Includes): the ones
+// sendBroadcastFallback multicasts to when the topic push misses them.
+func fallbackCondition(audience fcm.Audience) (string, []interface{}) {
+	conds := []string{"TRUE"}
+	var args []interface{}
+	if len(audience.Platforms) > 0 {
+		platforms := make([]string, len(audience.Platforms))
+		for i, p := range audience.Platforms {
+			platforms[i] = strings.TrimPrefix(fcm.PlatformTopic(p), "platform-")
+		}
+		conds = append(conds, "lower(t.platform) IN ?")
+		args = append(args, platforms)
+	}
+	if len(audience.Locales) > 0 {
+		langs := make([]string, len(audience.Locales))
+		for i, l := range audience.Locales {
+			langs[i] = strings.TrimPrefix(fcm.LocaleTopic(l), "locale-")
+		}
+		conds = append(conds, "lower(split_part(replace(trim(t.locale), '_', '-'), '-', 1)) IN ?")
+		args = append(args, langs)
+	}
+	if len(conds) > 1 {
+		conds = conds[1:]
+	}
+	return strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"notify-service/internal/fcm"
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+func TestTopicCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		audience fcm.Audience
+		wantCond string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "everyone",
+			wantCond: "((',' || t.topics || ',') LIKE ?)",
+			wantArgs: []interface{}{"%,all,%"},
+		},
+		{
+			name:     "platforms",
+			audience: fcm.Audience{Platforms: []string{"iOS", "android"}},
+			wantCond: "((',' || t.topics || ',') LIKE ? OR (',' || t.topics || ',') LIKE ?)",
+			wantArgs: []interface{}{"%,platform-ios,%", "%,platform-android,%"},
+		},
+		{
+			name:     "platform and locale",
+			audience: fcm.Audience{Platforms: []string{"ios"}, Locales: []string{"fr-CA"}},
+			wantCond: "((',' || t.topics || ',') LIKE ?) AND ((',' || t.topics || ',') LIKE ?)",
+			wantArgs: []interface{}{"%,platform-ios,%", "%,locale-fr,%"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, args := topicCondition(tt.audience)
+			if cond != tt.wantCond {
+				t.Errorf("condition = %q, want %q", cond, tt.wantCond)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestFallbackCondition(t *testing.T) {
+	cond, args := fallbackCondition(fcm.Audience{})
+	if cond != "TRUE" || len(args) != 0 {
+		t.Errorf("everyone: condition = %q, args = %v", cond, args)
+	}
+
+	cond, args = fallbackCondition(fcm.Audience{Platforms: []string{"iOS"}, Locales: []string{"fr_FR", "de"}})
+	wantCond := "lower(t.platform) IN ? AND lower(split_part(replace(trim(t.locale), '_', '-'), '-', 1)) IN ?"
+	if cond != wantCond {
+		t.Errorf("condition = %q, want %q", cond, wantCond)
+	}
+	wantArgs := []interface{}{[]string{"ios"}, []string{"fr", "de"}}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestEstimatePublishCounts(t *testing.T) {
+	s := newTestService(t, fcm.NewRecorder(fcm.DefaultBrands()))
+	s.cfg.PresenceWindowSeconds = 60
+	ctx := context.Background()
+
+	// active: device, email, in the app; quiet: device, no email; offline: email only
+	active, quiet, offline := uuid.New(), uuid.New(), uuid.New()
+	users := []*models.User{
+		{ID: active.String(), Username: "active", Email: "active@example.com"},
+		{ID: quiet.String(), Username: "quiet"},
+		{ID: offline.String(), Username: "offline", Email: "offline@example.com"},
+	}
+	tokens := []*models.FCMToken{
+		{UserID: active, DeviceID: "phone-" + active.String(), Token: "tok-" + active.String(), Platform: "ios"},
+		{UserID: quiet, DeviceID: "phone-" + quiet.String(), Token: "tok-" + quiet.String(), Platform: "android"},
+	}
+	presence := &models.DevicePresence{UserID: active, DeviceID: "phone-" + active.String(), State: models.PresenceActive, LastSeenAt: time.Now()}
+	if err := s.db.Create(&users).Error; err != nil {
+		t.Fatalf("create users: %v", err)
+	}
+	if err := s.db.Create(&tokens).Error; err != nil {
+		t.Fatalf("create tokens: %v", err)
+	}
+	if err := s.db.Create(presence).Error; err != nil {
+		t.Fatalf("create presence: %v", err)
+	}
+	creatorID := uuid.New()
+	notif, err := s.CreateNotification(ctx, &models.NotificationRequest{Heading: "Test", Title: "Dry run", Message: "hello", CreatorID: &creatorID})
+	if err != nil {
+		t.Fatalf("CreateNotification: %v", err)
+	}
+	ids := []uuid.UUID{active, quiet, offline}
+	t.Cleanup(func() {
+		s.db.Unscoped().Where("user_id IN ?", ids).Delete(&models.DevicePresence{})
+		s.db.Unscoped().Where("user_id IN ?", ids).Delete(&models.FCMToken{})
+		s.db.Unscoped().Delete(&models.User{}, "id IN ?", []string{active.String(), quiet.String(), offline.String()})
+		s.db.Unscoped().Delete(&models.Notification{}, "id = ?", notif.ID)
+	})
+
+	target := PublishTarget{UserIDs: append(ids, active)} // duplicates count once
+	estimate, err := s.EstimatePublish(ctx, notif.ID, target)
+	if err != nil {
+		t.Fatalf("EstimatePublish: %v", err)
+	}
+	if estimate.Total != 3 || estimate.InApp != 3 || estimate.PushReachable != 1 || estimate.EmailReachable != 2 {
+		t.Errorf("total/in_app/push/email = %d/%d/%d/%d, want 3/3/1/2",
+			estimate.Total, estimate.InApp, estimate.PushReachable, estimate.EmailReachable)
+	}
+	wantSuppressed := map[string]int64{
+		models.SuppressedPushDisabled: 0,
+		models.SuppressedNoDevice:     1,
+		models.SuppressedAudience:     0,
+		models.SuppressedActiveInApp:  1,
+	}
+	if !reflect.DeepEqual(estimate.Suppressed, wantSuppressed) {
+		t.Errorf("suppressed = %v, want %v", estimate.Suppressed, wantSuppressed)
+	}
+	wantNotModelled := []string{models.SuppressedPreferences, models.SuppressedFrequencyCap, models.SuppressedQuietHours}
+	if !reflect.DeepEqual(estimate.NotModelled, wantNotModelled) {
+		t.Errorf("not_modelled = %v, want %v", estimate.NotModelled, wantNotModelled)
+	}
+
+	// Without a push sender nobody is reachable by push
+	s.push = nil
+	estimate, err = s.EstimatePublish(ctx, notif.ID, target)
+	if err != nil {
+		t.Fatalf("EstimatePublish without push: %v", err)
+	}
+	if estimate.PushReachable != 0 || estimate.Suppressed[models.SuppressedPushDisabled] != 3 {
+		t.Errorf("without push: reachable %d, push_disabled %d; want 0 and 3",
+			estimate.PushReachable, estimate.Suppressed[models.SuppressedPushDisabled])
+	}
+}
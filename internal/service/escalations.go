@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"notify-service/internal/email/templates"
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errNoContact marks an escalation step skipped because the channel can't
+// reach the user (no address on file, or the channel is disabled).
+var errNoContact = errors.New("no contact")
+
+const (
+	// escalationBatch bounds how many steps one SendEscalations run takes.
+	escalationBatch = 500
+	// smsMaxLength keeps escalation texts within two SMS segments.
+	smsMaxLength = 300
+)
+
+// marshalEscalationPolicy validates a per-notification escalation policy for
+// storage. A policy without steps is stored as none.
+func marshalEscalationPolicy(policy *models.EscalationPolicy) (datatypes.JSON, error) {
+	if policy == nil || len(policy.Steps) == 0 {
+		return nil, nil
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid escalation_policy: %w", err)
+	}
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid escalation_policy: %w", err)
+	}
+	return datatypes.JSON(b), nil
+}
+
+// SendEscalations takes the next escalation step for recipients who still
+// haven't read a notification once that step is due (measured from
+// delivery). Reading or archiving the item stops escalation: the user has
+// dealt with it. A snoozed item isn't escalated until it wakes, and waking
+// restarts the schedule since it resets delivered_at. Rows are claimed with SKIP LOCKED so replicas don't send twice;
+// each step's outcome is appended to the recipient's escalation log.
+func (s *NotifyService) SendEscalations(ctx context.Context) error {
+	now := time.Now()
+	var due []models.NotificationRecipient
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("notification_recipients nr").
+			Select("nr.*").
+			Joins("INNER JOIN notifications n ON n.id = nr.notification_id AND n.deleted_at IS NULL AND n.retracted_at IS NULL").
+			Where("n.escalation_policy IS NOT NULL AND nr.delivered_at IS NOT NULL AND nr.expired_at IS NULL AND nr.archived_at IS NULL").
+			Where(awakeSQL("nr.")).
+			Where("nr.escalations < jsonb_array_length(n.escalation_policy->'steps')").
+			Where(unreadSQL("nr.")).
+			Where(`nr.delivered_at <= ?::timestamptz -
+				make_interval(mins => (n.escalation_policy->'steps'->nr.escalations->>'after_minutes')::int)`, now).
+			Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "nr"}, Options: "SKIP LOCKED"}).
+			Limit(escalationBatch).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(due))
+		for i, r := range due {
+			ids[i] = r.ID
+		}
+		return tx.Model(&models.NotificationRecipient{}).
+			Where("id IN ?", ids).
+			UpdateColumns(map[string]interface{}{
+				"escalations":  gorm.Expr("escalations + 1"),
+				"escalated_at": now,
+			}).Error
+	})
+	if err != nil || len(due) == 0 {
+		return err
+	}
+
+	byNotification := make(map[uuid.UUID][]models.NotificationRecipient)
+	for _, r := range due {
+		byNotification[r.NotificationID] = append(byNotification[r.NotificationID], r)
+	}
+	for notifID, recipients := range byNotification {
+		var notif models.Notification
+		if err := s.db.WithContext(ctx).First(&notif, "id = ?", notifID).Error; err != nil {
+			log.Printf("⚠️ [ESCALATE] Can't escalate %s: %v", notifID, err)
+			continue
+		}
+		policy, err := models.ParseEscalationPolicy(notif.EscalationPolicy)
+		if err != nil {
+			log.Printf("⚠️ [ESCALATE] Ignoring bad escalation_policy on notification %s: %v", notifID, err)
+			continue
+		}
+		for _, r := range recipients {
+			// r.Escalations is the step count before this run claimed the row
+			if r.Escalations < len(policy.Steps) {
+				go s.escalate(r, &notif, r.Escalations, policy.Steps[r.Escalations])
+			}
+		}
+	}
+	log.Printf("📣 [ESCALATE] Escalating %d unread recipient(s) of %d notification(s)", len(due), len(byNotification))
+	return nil
+}
+
+// escalate sends one escalation step and records its outcome on the recipient.
+func (s *NotifyService) escalate(recipient models.NotificationRecipient, notif *models.Notification, index int, step models.EscalationStep) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	record := models.EscalationRecord{Step: index + 1, Channel: step.Channel, Result: models.EscalationSent}
+	err := s.sendEscalation(ctx, recipient.UserID, notif, step.Channel)
+	record.At = time.Now()
+	switch {
+	case errors.Is(err, errNoContact):
+		record.Result = models.EscalationSkipped
+		record.Error = err.Error()
+		log.Printf("⚠️ [ESCALATE] Skipped %s step %d for user %s: %v", step.Channel, index+1, recipient.UserID, err)
+	case err != nil:
+		record.Result = models.EscalationFailed
+		record.Error = err.Error()
+		log.Printf("❌ [ESCALATE] %s step %d failed for user %s: %v", step.Channel, index+1, recipient.UserID, err)
+	default:
+		log.Printf("✅ [ESCALATE] Sent %s step %d for %s to user %s", step.Channel, index+1, notif.ID, recipient.UserID)
+	}
+
+	entry, _ := json.Marshal([]models.EscalationRecord{record})
+	if err := s.db.WithContext(ctx).Model(&models.NotificationRecipient{}).
+		Where("id = ?", recipient.ID).
+		UpdateColumn("escalation_log", gorm.Expr("COALESCE(escalation_log, '[]'::jsonb) || ?::jsonb", string(entry))).Error; err != nil {
+		log.Printf("⚠️ [ESCALATE] Failed to record step for recipient %s: %v", recipient.ID, err)
+	}
+}
+
+// sendEscalation delivers the notification to the user over channel.
+func (s *NotifyService) sendEscalation(ctx context.Context, userID uuid.UUID, notif *models.Notification, channel string) error {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ?", userID.String()).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: user not synced", errNoContact)
+		}
+		return err
+	}
+	actionURL := ""
+	if notif.ContentLink != nil {
+		actionURL = *notif.ContentLink
+	}
+
+	switch channel {
+	case models.EscalationChannelEmail:
+		if user.Email == "" {
+			return fmt.Errorf("%w: no email on file", errNoContact)
+		}
+		body, err := templates.RenderEscalationEmail(templates.EscalationData{
+			UserName:  user.Username,
+			Heading:   notif.Heading,
+			Title:     notif.Title,
+			Message:   notif.Message,
+			ActionURL: actionURL,
+		})
+		if err != nil {
+			return fmt.Errorf("render failed: %w", err)
+		}
+		return s.emailSender.Send(ctx, user.Email, notif.Title, body)
+	case models.EscalationChannelSMS:
+		if s.sms == nil {
+			return fmt.Errorf("%w: SMS disabled", errNoContact)
+		}
+		if user.Phone == nil || *user.Phone == "" {
+			return fmt.Errorf("%w: no phone on file", errNoContact)
+		}
+		text := notif.Title + ": " + notif.Message
+		if actionURL != "" {
+			text += " " + actionURL
+		}
+		if runes := []rune(text); len(runes) > smsMaxLength {
+			text = string(runes[:smsMaxLength-1]) + "…"
+		}
+		return s.sms.Send(ctx, *user.Phone, text)
+	default:
+		return fmt.Errorf("unknown channel %q", channel)
+	}
+}
+
+// GetNotificationEscalations pages a notification's recipients that have
+// been escalated, with each step taken, latest delivery first.
+func (s *NotifyService) GetNotificationEscalations(ctx context.Context, notifID uuid.UUID, page PageRequest) (Page[*models.EscalationView], error) {
+	query := s.db.WithContext(ctx).
+		Table("notification_recipients nr").
+		Select(`nr.id AS recipient_id, nr.user_id, COALESCE(u.username, 'unknown') AS username,
+			CASE WHEN `+readSQL("nr.")+` THEN 'read' ELSE nr.status END AS status,
+			nr.delivered_at, nr.read_at, nr.escalations AS steps_taken, nr.escalated_at,
+			nr.escalation_log AS steps`).
+		Joins("LEFT JOIN users u ON u.id = nr.user_id::text").
+		Where("nr.notification_id = ? AND nr.delivered_at IS NOT NULL AND nr.escalations > 0", notifID)
+	var rows []*escalationRow
+	if err := keyset(query, "nr.delivered_at", "nr.id", page).Scan(&rows).Error; err != nil {
+		return Page[*models.EscalationView]{}, err
+	}
+	result := paginate(rows, page, func(r *escalationRow) Cursor {
+		return Cursor{At: *r.DeliveredAt, ID: r.RecipientID}
+	})
+	views := make([]*models.EscalationView, len(result.Items))
+	for i, r := range result.Items {
+		views[i] = &r.EscalationView
+	}
+	return Page[*models.EscalationView]{Items: views, NextCursor: result.NextCursor, PrevCursor: result.PrevCursor}, nil
+}
+
+// escalationRow is an EscalationView plus the recipient ID it pages on.
+type escalationRow struct {
+	models.EscalationView `gorm:"embedded"`
+	RecipientID           uuid.UUID
+}
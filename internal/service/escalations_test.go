@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+func TestEscalationStopsOnceDealtWith(t *testing.T) {
+	s := newTestService(t, nil)
+	ctx := context.Background()
+
+	unread, read, snoozed, archived := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	policy := &models.EscalationPolicy{Steps: []models.EscalationStep{{Channel: models.EscalationChannelSMS, AfterMinutes: 1}}}
+	notif := publishTestNotification(t, s, models.NotificationRequest{EscalationPolicy: policy}, unread, read, snoozed, archived)
+	ids := []uuid.UUID{notif.ID}
+
+	if err := s.MarkNotificationsRead(ctx, read, "", ids); err != nil {
+		t.Fatalf("mark read: %v", err)
+	}
+	if _, err := s.ApplyInboxAction(ctx, snoozed, "", InboxSnooze, ids, SnoozeOptions{Until: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("snooze: %v", err)
+	}
+	if _, err := s.ApplyInboxAction(ctx, archived, "", InboxArchive, ids, SnoozeOptions{}); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	// Delivered ten minutes ago, so the first step is due
+	if err := s.db.Model(&models.NotificationRecipient{}).
+		Where("notification_id = ?", notif.ID).
+		UpdateColumn("delivered_at", time.Now().Add(-10*time.Minute)).Error; err != nil {
+		t.Fatalf("backdate delivery: %v", err)
+	}
+
+	if err := s.SendEscalations(ctx); err != nil {
+		t.Fatalf("SendEscalations: %v", err)
+	}
+	want := map[uuid.UUID]int{unread: 1, read: 0, snoozed: 0, archived: 0}
+	for userID, steps := range want {
+		if r := testRecipient(t, s, notif.ID, userID); r.Escalations != steps {
+			t.Errorf("user %s: %d step(s) taken, want %d", userID, r.Escalations, steps)
+		}
+	}
+
+	// The policy's only step is taken once
+	if err := s.SendEscalations(ctx); err != nil {
+		t.Fatalf("SendEscalations: %v", err)
+	}
+	if r := testRecipient(t, s, notif.ID, unread); r.Escalations != 1 {
+		t.Errorf("after a second run: %d step(s) taken, want 1", r.Escalations)
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// expirySweepBatch bounds how many notifications one ExpireNotifications run
+// sweeps.
+const expirySweepBatch = 100
+
+// ExpireNotifications marks the recipients of notifications past their
+// expires_at as expired, which hides the items from feeds and unread counts,
+// and announces the change so badges, ETags and delta sync follow. Each
+// notification is swept once; SKIP LOCKED keeps replicas from sweeping the
+// same one.
+func (s *NotifyService) ExpireNotifications(ctx context.Context) error {
+	now := time.Now()
+	expired := make(map[uuid.UUID][]uuid.UUID) // notification -> users
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var due []models.Notification
+		if err := tx.Select("id", "expires_at").
+			Where("expires_at IS NOT NULL AND expires_at <= ? AND expiry_swept_at IS NULL", now).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Limit(expirySweepBatch).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		for _, n := range due {
+			var recipients []models.NotificationRecipient
+			if err := tx.Model(&recipients).
+				Clauses(clause.Returning{Columns: []clause.Column{{Name: "user_id"}}}).
+				Where("notification_id = ? AND expired_at IS NULL", n.ID).
+				UpdateColumn("expired_at", *n.ExpiresAt).Error; err != nil {
+				return err
+			}
+			if len(recipients) > 0 {
+				expired[n.ID] = recipientUserIDs(recipients)
+				if err := recordRecipientsChange(tx, expired[n.ID], SyncInboxExpired, []uuid.UUID{n.ID}); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&models.Notification{}).
+				Where("id = ?", n.ID).
+				UpdateColumn("expiry_swept_at", now).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || len(expired) == 0 {
+		return err
+	}
+
+	total := 0
+	for notifID, userIDs := range expired {
+		total += len(userIDs)
+		s.requestSyncForRecipients(userIDs, SyncInboxExpired, []uuid.UUID{notifID})
+	}
+	log.Printf("⌛ [EXPIRY] Expired %d inbox item(s) across %d notification(s)", total, len(expired))
+	return nil
+}
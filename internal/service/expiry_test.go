@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+func TestExpirySweepHidesItems(t *testing.T) {
+	s := newTestService(t, nil)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	expiresAt := time.Now().Add(time.Hour)
+	notif := publishTestNotification(t, s, models.NotificationRequest{ExpiresAt: &expiresAt}, userID)
+	if unread := testUnread(t, s, userID); unread != 1 {
+		t.Fatalf("before expiry: unread = %d, want 1", unread)
+	}
+
+	// Let it expire now rather than in an hour
+	past := time.Now().Add(-time.Minute)
+	if err := s.db.Model(&models.Notification{}).Where("id = ?", notif.ID).UpdateColumn("expires_at", past).Error; err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if err := s.ExpireNotifications(ctx); err != nil {
+		t.Fatalf("ExpireNotifications: %v", err)
+	}
+
+	if r := testRecipient(t, s, notif.ID, userID); r.ExpiredAt == nil {
+		t.Error("recipient not marked expired")
+	}
+	if unread := testUnread(t, s, userID); unread != 0 {
+		t.Errorf("after expiry: unread = %d, want 0", unread)
+	}
+	var swept models.Notification
+	if err := s.db.First(&swept, "id = ?", notif.ID).Error; err != nil {
+		t.Fatalf("load notification: %v", err)
+	}
+	if swept.ExpirySweptAt == nil {
+		t.Error("notification not marked swept")
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// inboxRow is a notification joined with one recipient's state.
+type inboxRow struct {
+	models.Notification   `gorm:"embedded"`
+	RecipientID           uuid.UUID
+	RecipientStatus       models.NotificationRecipientStatus
+	RecipientDeliveredAt  *time.Time
+	RecipientReadAt       *time.Time
+	RecipientArchivedAt   *time.Time
+	RecipientPinnedAt     *time.Time
+	RecipientSnoozedUntil *time.Time
+	RecipientAckedAt      *time.Time
+	RecipientExpiredAt    *time.Time
+	SearchRank            float32 // set for full-text searches only
+}
+
+func (r *inboxRow) item() *models.InboxItem {
+	notif := r.Notification
+	if r.RecipientExpiredAt != nil {
+		// Stale offers and invites must not be acted on
+		notif.ActionLinks = nil
+	}
+	return &models.InboxItem{
+		Notification: &notif,
+		Delivery: models.DeliveryInfo{
+			Status:       r.RecipientStatus,
+			DeliveredAt:  r.RecipientDeliveredAt,
+			ReadAt:       r.RecipientReadAt,
+			Archived:     r.RecipientArchivedAt != nil,
+			ArchivedAt:   r.RecipientArchivedAt,
+			Pinned:       r.RecipientPinnedAt != nil,
+			PinnedAt:     r.RecipientPinnedAt,
+			Snoozed:      r.RecipientSnoozedUntil != nil && r.RecipientSnoozedUntil.After(time.Now()),
+			SnoozedUntil: r.RecipientSnoozedUntil,
+			Acknowledged: r.RecipientAckedAt != nil,
+			AckedAt:      r.RecipientAckedAt,
+			Expired:      r.RecipientExpiredAt != nil,
+			ExpiredAt:    r.RecipientExpiredAt,
+		},
+	}
+}
+
+// inboxColumns reports items covered by the read watermark as read, read at
+// the time the watermark was set.
+var inboxColumns = `notifications.*,
+	nr.id AS recipient_id,
+	CASE WHEN ` + unreadStatusSQL("nr.") + ` AND ` + coveredByWatermark("nr.") + ` THEN 'read' ELSE nr.status END AS recipient_status,
+	COALESCE(nr.read_at, (SELECT wm.updated_at FROM inbox_read_watermarks wm
+		WHERE wm.user_id = nr.user_id AND ` + unreadStatusSQL("nr.") + ` AND nr.delivered_at <= wm.read_up_to
+		AND (nr.marked_unread_at IS NULL OR nr.marked_unread_at < wm.updated_at))) AS recipient_read_at,
+	nr.archived_at AS recipient_archived_at,
+	nr.pinned_at AS recipient_pinned_at,
+	nr.snoozed_until AS recipient_snoozed_until,
+	nr.acknowledged_at AS recipient_acked_at,
+	nr.expired_at AS recipient_expired_at`
+
+// searchRank scores a row against the search_q joined in by InboxFilter.
+// Heading matches weigh most, then title, then message.
+const searchRank = "ts_rank(" + models.NotificationSearchVector + ", search_q)"
+
+// inboxQuery selects a user's notifications with their recipient state.
+// Recalled items are gone from every view (delta sync reports them deleted).
+func (s *NotifyService) inboxQuery(ctx context.Context, userID uuid.UUID) *gorm.DB {
+	return s.db.WithContext(ctx).
+		Table("notifications").
+		Select(inboxColumns).
+		Joins("INNER JOIN notification_recipients nr ON notifications.id = nr.notification_id").
+		Where("nr.user_id = ? AND nr.retracted_at IS NULL AND notifications.deleted_at IS NULL", userID)
+}
+
+func (s *NotifyService) findInbox(query *gorm.DB) ([]*models.InboxItem, error) {
+	var rows []*inboxRow
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	items := make([]*models.InboxItem, len(rows))
+	for i, row := range rows {
+		items[i] = row.item()
+	}
+	return items, nil
+}
+
+// GetInboxPage returns one newest-first page of the user's delivered items,
+// keyed on (delivered_at, recipient id). since limits it to items delivered
+// after that time. With a search query, best matches come first.
+func (s *NotifyService) GetInboxPage(ctx context.Context, userID uuid.UUID, since *time.Time, filter InboxFilter, page PageRequest) (Page[*models.InboxItem], error) {
+	query := filter.apply(s.inboxQuery(ctx, userID).Where("nr.delivered_at IS NOT NULL"))
+	if since != nil {
+		query = query.Where("nr.delivered_at > ?", *since)
+	}
+	if filter.Query != "" {
+		query = rankedKeyset(query.Select(inboxColumns+", "+searchRank+" AS search_rank"),
+			searchRank, "nr.delivered_at", "nr.id", page)
+	} else {
+		query = keyset(query, "nr.delivered_at", "nr.id", page)
+	}
+	var rows []*inboxRow
+	if err := query.Find(&rows).Error; err != nil {
+		return Page[*models.InboxItem]{}, err
+	}
+	result := paginate(rows, page, func(r *inboxRow) Cursor {
+		return Cursor{At: *r.RecipientDeliveredAt, ID: r.RecipientID, Rank: r.SearchRank}
+	})
+	items := make([]*models.InboxItem, len(result.Items))
+	for i, row := range result.Items {
+		items[i] = row.item()
+	}
+	return Page[*models.InboxItem]{Items: items, NextCursor: result.NextCursor, PrevCursor: result.PrevCursor}, nil
+}
+
+// GetUnreadInbox is GetUnreadNotifications with per-recipient state.
+func (s *NotifyService) GetUnreadInbox(ctx context.Context, userID uuid.UUID) ([]*models.InboxItem, error) {
+	return s.findInbox(s.inboxQuery(ctx, userID).
+		Where(unreadSQL("nr.")).
+		Scopes(DefaultInboxFilter().apply).
+		Order("nr.delivered_at DESC"))
+}
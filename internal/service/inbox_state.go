@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InboxAction is a per-recipient state change a user can apply to items.
+type InboxAction string
+
+const (
+	InboxArchive    InboxAction = "archive"
+	InboxUnarchive  InboxAction = "unarchive"
+	InboxPin        InboxAction = "pin"
+	InboxUnpin      InboxAction = "unpin"
+	InboxSnooze     InboxAction = "snooze"
+	InboxUnsnooze   InboxAction = "unsnooze"
+	InboxMarkUnread InboxAction = "mark-unread"
+)
+
+// InboxActions lists every action, e.g. for route registration.
+var InboxActions = []InboxAction{
+	InboxArchive, InboxUnarchive, InboxPin, InboxUnpin, InboxSnooze, InboxUnsnooze, InboxMarkUnread,
+}
+
+// maxSnooze is the furthest ahead an item can be snoozed.
+const maxSnooze = 30 * 24 * time.Hour
+
+var ErrInvalidSnooze = errors.New("snooze 'until' must be in the future and within 30 days")
+
+// SnoozeOptions applies to InboxSnooze only.
+type SnoozeOptions struct {
+	Until  time.Time
+	Repush bool // push the item again when the snooze ends
+}
+
+// InboxFilter narrows the feed; nil and empty fields don't filter.
+type InboxFilter struct {
+	Archived *bool
+	Pinned   *bool
+	Snoozed  *bool
+	Read     *bool
+	Expired  *bool
+
+	Types      []models.NotificationType
+	Categories []models.NotificationCategory
+	From       *time.Time // delivered at or after
+	To         *time.Time // delivered before
+	EventKey   string     // metadata event_key; a trailing "*" matches a prefix
+	Query      string     // full-text search over heading, title and message
+}
+
+// DefaultInboxFilter is the plain inbox: not archived, not snoozed, not
+// expired. Clients that predate archiving and snoozing only ever see this view.
+func DefaultInboxFilter() InboxFilter {
+	no := false
+	return InboxFilter{Archived: &no, Snoozed: &no, Expired: &no}
+}
+
+func (f InboxFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.Archived != nil {
+		if *f.Archived {
+			query = query.Where("nr.archived_at IS NOT NULL")
+		} else {
+			query = query.Where("nr.archived_at IS NULL")
+		}
+	}
+	if f.Pinned != nil {
+		if *f.Pinned {
+			query = query.Where("nr.pinned_at IS NOT NULL")
+		} else {
+			query = query.Where("nr.pinned_at IS NULL")
+		}
+	}
+	if f.Snoozed != nil {
+		if *f.Snoozed {
+			query = query.Where("nr.snoozed_until > NOW()")
+		} else {
+			query = query.Where("(nr.snoozed_until IS NULL OR nr.snoozed_until <= NOW())")
+		}
+	}
+	if f.Read != nil {
+		if *f.Read {
+			query = query.Where(readSQL("nr."))
+		} else {
+			query = query.Where(unreadSQL("nr."))
+		}
+	}
+	if f.Expired != nil {
+		if *f.Expired {
+			query = query.Where("nr.expired_at IS NOT NULL")
+		} else {
+			query = query.Where("nr.expired_at IS NULL")
+		}
+	}
+	if len(f.Types) > 0 {
+		query = query.Where("notifications.type IN ?", f.Types)
+	}
+	if len(f.Categories) > 0 {
+		query = query.Where("notifications.category IN ?", f.Categories)
+	}
+	if f.From != nil {
+		query = query.Where("nr.delivered_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("nr.delivered_at < ?", *f.To)
+	}
+	if f.EventKey != "" {
+		if prefix, ok := strings.CutSuffix(f.EventKey, "*"); ok {
+			query = query.Where("notifications.metadata->>'event_key' LIKE ?", escapeLike(prefix)+"%")
+		} else {
+			query = query.Where("notifications.metadata->>'event_key' = ?", f.EventKey)
+		}
+	}
+	if f.Query != "" {
+		query = query.Joins("CROSS JOIN websearch_to_tsquery('english', ?) AS search_q", f.Query).
+			Where(models.NotificationSearchVector + " @@ search_q")
+	}
+	return query
+}
+
+// escapeLike escapes LIKE wildcards in user input.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// visibleUnread is the SQL condition for recipient rows that count towards the
+// badge: unread, not archived, not snoozed, not expired and not recalled, on
+// a notification that isn't deleted (its rows may await
+// PurgeDeletedRecipients). alias qualifies the columns ("nr." or "notification_recipients."); the read
+// watermark check needs it.
+func visibleUnread(alias string) string {
+	return unreadSQL(alias) + " AND " + alias + "archived_at IS NULL AND " + alias + "expired_at IS NULL AND " +
+		alias + "retracted_at IS NULL AND " + awakeSQL(alias) + " AND " +
+		"NOT EXISTS (SELECT 1 FROM notifications del_n WHERE del_n.id = " + alias + "notification_id AND del_n.deleted_at IS NOT NULL)"
+}
+
+// awakeSQL is the SQL condition for recipient rows that aren't snoozed.
+func awakeSQL(alias string) string {
+	return "(" + alias + "snoozed_until IS NULL OR " + alias + "snoozed_until <= NOW())"
+}
+
+// ApplyInboxAction changes the user's state for the given items and tells
+// their other devices. Returns how many items changed.
+func (s *NotifyService) ApplyInboxAction(ctx context.Context, userID uuid.UUID, originDeviceID string, action InboxAction, notificationIDs []uuid.UUID, snooze SnoozeOptions) (int, error) {
+	if len(notificationIDs) == 0 {
+		return 0, fmt.Errorf("notification_ids required")
+	}
+	now := time.Now()
+	updates := map[string]interface{}{"updated_at": now}
+	query := s.db.Where("user_id = ? AND notification_id IN ?", userID, notificationIDs)
+	reason := SyncInboxState
+
+	switch action {
+	case InboxArchive:
+		updates["archived_at"] = now
+		query = query.Where("archived_at IS NULL")
+	case InboxUnarchive:
+		updates["archived_at"] = nil
+		query = query.Where("archived_at IS NOT NULL")
+	case InboxPin:
+		updates["pinned_at"] = now
+		query = query.Where("pinned_at IS NULL")
+	case InboxUnpin:
+		updates["pinned_at"] = nil
+		query = query.Where("pinned_at IS NOT NULL")
+	case InboxSnooze:
+		if !snooze.Until.After(now) || snooze.Until.Sub(now) > maxSnooze {
+			return 0, ErrInvalidSnooze
+		}
+		updates["snoozed_until"] = snooze.Until
+		updates["snooze_repush"] = snooze.Repush
+	case InboxUnsnooze:
+		updates["snoozed_until"] = nil
+		updates["snooze_repush"] = false
+		query = query.Where("snoozed_until IS NOT NULL")
+	case InboxMarkUnread:
+		updates["status"] = models.RecipientStatusDelivered
+		updates["read_at"] = nil
+		updates["marked_unread_at"] = now
+		query = query.Where(readSQL("notification_recipients."))
+		reason = SyncReadState
+	default:
+		return 0, fmt.Errorf("unknown inbox action %q", action)
+	}
+
+	var changed []models.NotificationRecipient
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&changed).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "notification_id"}}}).
+			Where(query).
+			Updates(updates).Error; err != nil || len(changed) == 0 {
+			return err
+		}
+		return recordInboxChange(tx, &userID, originDeviceID, reason, recipientNotificationIDs(changed))
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(changed) > 0 {
+		s.RequestSync(userID, originDeviceID, reason, recipientNotificationIDs(changed))
+	}
+	return len(changed), nil
+}
+
+// snoozeBatch bounds how many items one WakeSnoozed run brings back.
+const snoozeBatch = 1000
+
+// WakeSnoozed brings back items whose snooze has ended: they move to the top
+// of the feed (delivered_at is reset to now) and are pushed again if asked.
+// Rows are claimed with SKIP LOCKED so replicas don't wake an item twice.
+func (s *NotifyService) WakeSnoozed(ctx context.Context) error {
+	now := time.Now()
+	var due []models.NotificationRecipient
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("snoozed_until IS NOT NULL AND snoozed_until <= ?", now).
+			Limit(snoozeBatch).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(due))
+		for i, r := range due {
+			ids[i] = r.ID
+		}
+		if err := tx.Model(&models.NotificationRecipient{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"snoozed_until": nil,
+				"snooze_repush": false,
+				"delivered_at":  now,
+				"updated_at":    now,
+			}).Error; err != nil {
+			return err
+		}
+		for userID, ids := range recipientsByUser(due) {
+			if err := recordInboxChange(tx, &userID, "", SyncInboxState, ids); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || len(due) == 0 {
+		return err
+	}
+
+	repush := make(map[uuid.UUID][]uuid.UUID) // notification -> users
+	for _, r := range due {
+		if r.SnoozeRepush {
+			repush[r.NotificationID] = append(repush[r.NotificationID], r.UserID)
+		}
+	}
+	byUser := recipientsByUser(due)
+	for userID, ids := range byUser {
+		s.RequestSync(userID, "", SyncInboxState, ids)
+	}
+	for notifID, userIDs := range repush {
+		var notif models.Notification
+		if err := s.db.WithContext(ctx).First(&notif, "id = ?", notifID).Error; err != nil {
+			log.Printf("⚠️ [SNOOZE] Can't re-push %s: %v", notifID, err)
+			continue
+		}
+		for _, userID := range userIDs {
+			go s.sendPushNotificationToUser(userID, &notif)
+		}
+	}
+	log.Printf("⏰ [SNOOZE] Woke %d item(s) for %d user(s)", len(due), len(byUser))
+	return nil
+}
+
+// recipientsByUser groups recipient rows' notification IDs by user.
+func recipientsByUser(recipients []models.NotificationRecipient) map[uuid.UUID][]uuid.UUID {
+	byUser := make(map[uuid.UUID][]uuid.UUID)
+	for _, r := range recipients {
+		byUser[r.UserID] = append(byUser[r.UserID], r.NotificationID)
+	}
+	return byUser
+}
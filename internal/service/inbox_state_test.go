@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"notify-service/internal/fcm"
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+func TestSnoozeHidesThenWakesAndRepushes(t *testing.T) {
+	recorder := fcm.NewRecorder(fcm.DefaultBrands())
+	s := newTestService(t, recorder)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	token := addTestDevice(t, s, userID)
+	notif := publishTestNotification(t, s, models.NotificationRequest{}, userID)
+	if n := visiblePushes(recorder, token, 1); n != 1 {
+		t.Fatalf("publish: %d push(es), want 1", n)
+	}
+
+	changed, err := s.ApplyInboxAction(ctx, userID, "", InboxSnooze, []uuid.UUID{notif.ID},
+		SnoozeOptions{Until: time.Now().Add(time.Hour), Repush: true})
+	if err != nil || changed != 1 {
+		t.Fatalf("snooze: changed %d, err %v", changed, err)
+	}
+	if unread := testUnread(t, s, userID); unread != 0 {
+		t.Errorf("while snoozed: unread = %d, want 0", unread)
+	}
+
+	// End the snooze now rather than in an hour
+	if err := s.db.Model(&models.NotificationRecipient{}).
+		Where("notification_id = ? AND user_id = ?", notif.ID, userID).
+		UpdateColumn("snoozed_until", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatalf("end snooze: %v", err)
+	}
+	before := time.Now()
+	if err := s.WakeSnoozed(ctx); err != nil {
+		t.Fatalf("WakeSnoozed: %v", err)
+	}
+
+	r := testRecipient(t, s, notif.ID, userID)
+	if r.SnoozedUntil != nil || r.SnoozeRepush {
+		t.Errorf("after wake: snoozed_until = %v, repush = %v", r.SnoozedUntil, r.SnoozeRepush)
+	}
+	if r.DeliveredAt == nil || r.DeliveredAt.Before(before.Add(-time.Second)) {
+		t.Errorf("after wake: delivered_at = %v, want moved to the top of the feed", r.DeliveredAt)
+	}
+	if unread := testUnread(t, s, userID); unread != 1 {
+		t.Errorf("after wake: unread = %d, want 1", unread)
+	}
+	if n := visiblePushes(recorder, token, 2); n != 2 {
+		t.Errorf("after wake: %d push(es), want the item pushed again", n)
+	}
+}
+
+func TestUnsnoozeWithoutRepush(t *testing.T) {
+	recorder := fcm.NewRecorder(fcm.DefaultBrands())
+	s := newTestService(t, recorder)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	token := addTestDevice(t, s, userID)
+	notif := publishTestNotification(t, s, models.NotificationRequest{}, userID)
+
+	if _, err := s.ApplyInboxAction(ctx, userID, "", InboxSnooze, []uuid.UUID{notif.ID},
+		SnoozeOptions{Until: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("snooze: %v", err)
+	}
+	if changed, err := s.ApplyInboxAction(ctx, userID, "", InboxUnsnooze, []uuid.UUID{notif.ID}, SnoozeOptions{}); err != nil || changed != 1 {
+		t.Fatalf("unsnooze: changed %d, err %v", changed, err)
+	}
+	if unread := testUnread(t, s, userID); unread != 1 {
+		t.Errorf("unread = %d, want 1", unread)
+	}
+	if n := visiblePushes(recorder, token, 2); n != 1 {
+		t.Errorf("%d push(es), want only the original", n)
+	}
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidInteraction = errors.New("invalid interaction")
+
+// interactionClockSkew is how far ahead of the server a client's occurred_at
+// may be before it is clamped to now.
+const interactionClockSkew = 5 * time.Minute
+
+// RecordInteractions stores client-reported interactions and rolls them up
+// onto the user's recipient rows. Opening an item or clicking one of its
+// actions also marks it read. Events for notifications not in the user's
+// inbox, or clicks on actions they don't have (or no longer honor, once
+// expired), are skipped; retried events (same event_id) are stored once. An
+// occurred_at before the item reached the user is moved up to its delivery.
+// Returns how many events were recorded.
+func (s *NotifyService) RecordInteractions(ctx context.Context, userID uuid.UUID, deviceID string, events []models.InteractionEvent) (int, error) {
+	now := time.Now()
+	ids := make([]uuid.UUID, 0, len(events))
+	for i := range events {
+		ev := &events[i]
+		if ev.NotificationID == uuid.Nil && ev.PushData != nil {
+			// Push opens report the push's data payload as received
+			ev.NotificationID, _ = uuid.Parse(ev.PushData["notification_id"])
+			if ev.Source == "" {
+				ev.Source = models.InteractionSourcePush
+			}
+		}
+		if ev.NotificationID == uuid.Nil {
+			return 0, fmt.Errorf("%w: event %d has no notification_id", ErrInvalidInteraction, i)
+		}
+		if !ev.Type.Valid() {
+			return 0, fmt.Errorf("%w: event %d has unknown type %q", ErrInvalidInteraction, i, ev.Type)
+		}
+		if ev.Type == models.InteractionActionClick && (ev.ActionIndex == nil || *ev.ActionIndex < 0) {
+			return 0, fmt.Errorf("%w: event %d needs action_index", ErrInvalidInteraction, i)
+		}
+		switch ev.Source {
+		case "":
+			ev.Source = models.InteractionSourceInbox
+		case models.InteractionSourceInbox, models.InteractionSourcePush:
+		default:
+			return 0, fmt.Errorf("%w: event %d source must be inbox or push", ErrInvalidInteraction, i)
+		}
+		ids = append(ids, ev.NotificationID)
+	}
+
+	// Only items the user actually received
+	var notifs []struct {
+		models.Notification
+		RecipientDeliveredAt *time.Time
+	}
+	if err := s.inboxQuery(ctx, userID).
+		Select("notifications.id, notifications.action_links, notifications.expires_at, nr.delivered_at AS recipient_delivered_at").
+		Where("notifications.id IN ?", ids).
+		Find(&notifs).Error; err != nil {
+		return 0, err
+	}
+	actionCounts := make(map[uuid.UUID]int, len(notifs))
+	deliveredAt := make(map[uuid.UUID]*time.Time, len(notifs))
+	for _, n := range notifs {
+		var links []models.ActionLink
+		if !notificationExpired(&n.Notification) {
+			_ = json.Unmarshal(n.ActionLinks, &links)
+		}
+		actionCounts[n.ID] = len(links)
+		deliveredAt[n.ID] = n.RecipientDeliveredAt
+	}
+
+	rows := make([]models.NotificationInteraction, 0, len(events))
+	for _, ev := range events {
+		actions, ok := actionCounts[ev.NotificationID]
+		if !ok {
+			continue
+		}
+		if ev.Type == models.InteractionActionClick && *ev.ActionIndex >= actions {
+			continue
+		}
+		row := models.NotificationInteraction{
+			NotificationID: ev.NotificationID,
+			UserID:         userID,
+			Type:           ev.Type,
+			Source:         ev.Source,
+			OccurredAt:     now,
+		}
+		if ev.Type == models.InteractionActionClick {
+			row.ActionIndex = ev.ActionIndex
+		}
+		if ev.OccurredAt != nil && !ev.OccurredAt.IsZero() && ev.OccurredAt.Before(now.Add(interactionClockSkew)) {
+			row.OccurredAt = *ev.OccurredAt
+		}
+		// Nothing happens to an item before it reached the user
+		if delivered := deliveredAt[ev.NotificationID]; delivered != nil && row.OccurredAt.Before(*delivered) {
+			row.OccurredAt = *delivered
+		}
+		if deviceID != "" {
+			row.DeviceID = &deviceID
+		}
+		if ev.EventID != "" {
+			eventID := ev.EventID
+			row.ClientEventID = &eventID
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	var recorded int64
+	var read []models.NotificationRecipient
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "user_id"}, {Name: "client_event_id"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "client_event_id IS NOT NULL"}}},
+			DoNothing:   true,
+		}).Create(&rows)
+		if result.Error != nil {
+			return result.Error
+		}
+		recorded = result.RowsAffected
+		// Rollups keep the earliest time, so replaying a retried event is harmless
+		for _, r := range rows {
+			if err := rollUpInteraction(tx, r); err != nil {
+				return err
+			}
+		}
+		var opened []uuid.UUID
+		for _, r := range rows {
+			if r.Type == models.InteractionOpen || r.Type == models.InteractionActionClick {
+				opened = append(opened, r.NotificationID)
+			}
+		}
+		if len(opened) == 0 {
+			return nil
+		}
+		if err := tx.Model(&read).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "notification_id"}}}).
+			Where("user_id = ? AND notification_id IN ?", userID, opened).
+			Where(unreadSQL("notification_recipients.")).
+			Updates(map[string]interface{}{
+				"status":     models.RecipientStatusRead,
+				"read_at":    now,
+				"updated_at": now,
+			}).Error; err != nil || len(read) == 0 {
+			return err
+		}
+		return recordInboxChange(tx, &userID, deviceID, SyncReadState, recipientNotificationIDs(read))
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(read) > 0 {
+		s.RequestSync(userID, deviceID, SyncReadState, recipientNotificationIDs(read))
+	}
+	return int(recorded), nil
+}
+
+// rollUpInteraction records an interaction on the recipient row: the first
+// time it happened, and for clicks which action was clicked last. Anything
+// opened or clicked was also seen.
+func rollUpInteraction(tx *gorm.DB, r models.NotificationInteraction) error {
+	first := func(col string) clause.Expr {
+		return gorm.Expr("LEAST(COALESCE("+col+", ?), ?)", r.OccurredAt, r.OccurredAt)
+	}
+	updates := map[string]interface{}{"seen_at": first("seen_at")}
+	switch r.Type {
+	case models.InteractionOpen:
+		updates["opened_at"] = first("opened_at")
+	case models.InteractionActionClick:
+		updates["opened_at"] = first("opened_at")
+		updates["clicked_at"] = first("clicked_at")
+		updates["clicked_action"] = *r.ActionIndex
+	case models.InteractionDismiss:
+		updates["dismissed_at"] = first("dismissed_at")
+	}
+	return tx.Model(&models.NotificationRecipient{}).
+		Where("user_id = ? AND notification_id = ?", r.UserID, r.NotificationID).
+		UpdateColumns(updates).Error
+}
+
+// GetInteractionSummary aggregates a notification's interactions from the
+// recipient rollups (unique users) and the event history (raw counts).
+func (s *NotifyService) GetInteractionSummary(ctx context.Context, notifID uuid.UUID) (*models.InteractionSummary, error) {
+	summary := &models.InteractionSummary{
+		NotificationID: notifID,
+		ActionClicks:   make(map[int]int),
+		Events:         make(map[models.InteractionType]int),
+	}
+	var totals struct {
+		Recipients int
+		Seen       int
+		Opened     int
+		Clicked    int
+		Dismissed  int
+	}
+	if err := s.db.WithContext(ctx).
+		Model(&models.NotificationRecipient{}).
+		Select(`COUNT(*) AS recipients,
+			COUNT(seen_at) AS seen,
+			COUNT(opened_at) AS opened,
+			COUNT(clicked_at) AS clicked,
+			COUNT(dismissed_at) AS dismissed`).
+		Where("notification_id = ?", notifID).
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	summary.Recipients = totals.Recipients
+	summary.Seen = totals.Seen
+	summary.Opened = totals.Opened
+	summary.Clicked = totals.Clicked
+	summary.Dismissed = totals.Dismissed
+
+	var byType []struct {
+		Type  models.InteractionType
+		Count int
+	}
+	if err := s.db.WithContext(ctx).
+		Model(&models.NotificationInteraction{}).
+		Select("type, COUNT(*) AS count").
+		Where("notification_id = ?", notifID).
+		Group("type").
+		Scan(&byType).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range byType {
+		summary.Events[t.Type] = t.Count
+	}
+
+	var byAction []struct {
+		ActionIndex int
+		Users       int
+	}
+	if err := s.db.WithContext(ctx).
+		Model(&models.NotificationInteraction{}).
+		Select("action_index, COUNT(DISTINCT user_id) AS users").
+		Where("notification_id = ? AND type = ?", notifID, models.InteractionActionClick).
+		Group("action_index").
+		Scan(&byAction).Error; err != nil {
+		return nil, err
+	}
+	for _, a := range byAction {
+		summary.ActionClicks[a.ActionIndex] = a.Users
+	}
+	return summary, nil
+}
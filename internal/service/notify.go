@@ -3,12 +3,17 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"notify-service/internal/config"
 	"notify-service/internal/email"
 	"notify-service/internal/email/templates"
 	"notify-service/internal/fcm"
 	"notify-service/internal/notification"
+	"notify-service/internal/realtime"
+	"notify-service/internal/schema"
+	"notify-service/internal/sms"
 	"notify-service/internal/sync"
 	"notify-service/pkg/models"
 	"notify-service/utils"
@@ -19,23 +24,32 @@ import (
 	"github.com/google/uuid"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type NotifyService struct {
+	cfg             *config.Config
 	emailSender     *email.Sender
 	db              *gorm.DB
 	r2Client        *utils.NotificationR2Client
 	userSyncService *sync.UserSyncService
-	fcmClient       *fcm.FCMClient
+	push            fcm.PushSender // nil = pushes disabled
+	sms             sms.Sender     // nil = SMS disabled
+	syncLimiter     *syncLimiter
+	hub             *realtime.Hub
 }
 
-func NewNotifyService(emailSender *email.Sender, r2Client *utils.NotificationR2Client, userSyncService *sync.UserSyncService, fcmClient *fcm.FCMClient) *NotifyService {
+func NewNotifyService(cfg *config.Config, emailSender *email.Sender, r2Client *utils.NotificationR2Client, userSyncService *sync.UserSyncService, push fcm.PushSender, smsSender sms.Sender) *NotifyService {
 	return &NotifyService{
+		cfg:             cfg,
 		emailSender:     emailSender,
 		db:              notification.GetDB(),
 		r2Client:        r2Client,
 		userSyncService: userSyncService,
-		fcmClient:       fcmClient,
+		push:            push,
+		sms:             smsSender,
+		syncLimiter:     newSyncLimiter(time.Duration(cfg.SyncPushMinIntervalSeconds) * time.Second),
+		hub:             realtime.NewHub(cfg.StreamMaxConnectionsPerUser, cfg.StreamMaxConnections),
 	}
 }
 
@@ -62,6 +76,11 @@ func (s *NotifyService) SendEmail(ctx context.Context, req *models.EmailRequest)
 	emailType := strings.ToLower(strings.TrimSpace(req.Type))
 	log.Printf("📧 [DEBUG] Processing email type: '%s' for user %s", emailType, req.UserID)
 
+	if violations := s.ValidateEmailRequest(req); len(violations) > 0 {
+		log.Printf("❌ [ERROR] %s: %d context violation(s) for user %s", emailType, len(violations), req.UserID)
+		return &schema.Error{Violations: violations}
+	}
+
 	switch emailType {
 	case "email_verification":
 		log.Printf("📧 [DEBUG] Processing email_verification for user %s", req.UserID)
@@ -357,6 +376,7 @@ func (s *NotifyService) SendEmail(ctx context.Context, req *models.EmailRequest)
 		notif := &models.Notification{
 			CreatorID:       req.UserID,
 			Type:            models.NotificationTypeInfo,
+			Category:        models.CategoryFor("", models.NotificationTypeInfo, emailType),
 			Heading:         getNotificationHeading(emailType),
 			Title:           subject,
 			Message:         "We've sent an email to your inbox. Please check your spam folder if you don't see it.",
@@ -380,7 +400,13 @@ func (s *NotifyService) SendEmail(ctx context.Context, req *models.EmailRequest)
 			DeliveredAt:    &deliveredAt,
 		}
 
-		if err := s.db.Create(recipient).Error; err != nil {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(recipient).Error; err != nil {
+				return err
+			}
+			return recordNewItem(tx, req.UserID, notif)
+		})
+		if err != nil {
 			log.Printf("⚠️ Failed to save recipient for email notification %s: %v", notif.ID, err)
 		} else {
 			log.Printf("✅ Email notification & recipient created: %s → user %s", notif.ID, req.UserID)
@@ -393,11 +419,37 @@ func (s *NotifyService) SendEmail(ctx context.Context, req *models.EmailRequest)
 }
 
 func (s *NotifyService) sendPushNotificationToUser(userID uuid.UUID, notif *models.Notification) {
-	if s.fcmClient == nil {
-		log.Printf("⚠️ [FCM] Skip push: FCM client not configured")
+	if s.push == nil {
+		log.Printf("⚠️ [FCM] Skip push: push sender not configured (PUSH_DRIVER=none)")
+		return
+	}
+	if notificationExpired(notif) {
+		log.Printf("⌛ [FCM] Skip push: notification %s has expired", notif.ID)
+		return
+	}
+	if notif.RetractedAt != nil {
+		log.Printf("↩️ [FCM] Skip push: notification %s was recalled", notif.ID)
 		return
 	}
 
+	// User is looking at the app: in-app only, or a silent sync instead of an alert
+	if !s.presenceExempt(notif) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		active, err := s.IsUserActive(ctx, userID)
+		cancel()
+		if err != nil {
+			log.Printf("⚠️ [PRESENCE] Lookup failed for user %s, pushing anyway: %v", userID, err)
+		} else if active {
+			if s.cfg.PresenceMode == PresenceModeSkip {
+				log.Printf("👀 [PRESENCE] User %s active in-app, push skipped for %s", userID, notif.ID)
+				return
+			}
+			log.Printf("👀 [PRESENCE] User %s active in-app, push downgraded to sync for %s", userID, notif.ID)
+			s.RequestSync(userID, "", SyncInboxCreated, []uuid.UUID{notif.ID})
+			return
+		}
+	}
+
 	// Fetch active FCM tokens for this user
 	var tokens []models.FCMToken
 	err := s.db.Where("user_id = ? AND deleted_at IS NULL", userID).
@@ -422,19 +474,141 @@ func (s *NotifyService) sendPushNotificationToUser(userID uuid.UUID, notif *mode
 		"notification_id": notif.ID.String(),
 		"type":            string(notif.Type),
 		"click_action":    "OPEN_NOTIFICATION", // or deep link
+		"sync":            string(SyncInboxCreated),
+	}
+	if notif.RequiresAck {
+		data["requires_ack"] = "true"
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err = s.fcmClient.SendToMultipleTokens(ctx, tokenStrs, notif.Title, notif.Message, data)
+	payload := pushPayload(notif, data)
+	// The visible push doubles as the inbox sync signal: wake the app to fetch
+	payload.ContentAvailable = true
+	if unread, err := s.UnreadCount(ctx, userID); err == nil {
+		payload.Badge = &unread
+		data["unread_count"] = unread
+	} else {
+		log.Printf("⚠️ [FCM] Unread count failed for user %s, sending without badge: %v", userID, err)
+	}
+
+	failures, err := s.push.SendToMultipleTokens(ctx, tokenStrs, payload)
+	s.pruneTokens(failures)
 	if err != nil {
 		log.Printf("❌ [FCM] Push failed for user %s: %v", userID, err)
 	} else {
-		log.Printf("✅ [FCM] Push sent to %d device(s) for user %s", len(tokenStrs), userID)
+		log.Printf("✅ [FCM] Push sent to %d device(s) for user %s (%d pruned)", len(tokenStrs), userID, len(failures))
 	}
 }
 
+// pushPayload builds the rich push for a notification: image (external content
+// image, else uploaded thumbnail), deep link, action buttons and brand.
+func pushPayload(notif *models.Notification, data map[string]interface{}) fcm.Payload {
+	payload := fcm.Payload{Title: notif.Title, Body: notif.Message, Data: data}
+
+	if notif.ContentImageURL != nil && *notif.ContentImageURL != "" {
+		payload.ImageURL = *notif.ContentImageURL
+	} else if notif.ThumbnailURL != nil && *notif.ThumbnailURL != "" {
+		payload.ImageURL = *notif.ThumbnailURL
+	}
+	if notif.ContentLink != nil {
+		payload.Link = *notif.ContentLink
+	}
+
+	if len(notif.ActionLinks) > 0 {
+		var links []models.ActionLink
+		if err := json.Unmarshal(notif.ActionLinks, &links); err != nil {
+			log.Printf("⚠️ [FCM] Ignoring malformed action_links on notification %s: %v", notif.ID, err)
+		}
+		for i, l := range links {
+			if l.Label == "" || l.URL == "" {
+				continue
+			}
+			payload.Actions = append(payload.Actions, fcm.Action{
+				ID:    fmt.Sprintf("action_%d", i),
+				Title: l.Label,
+				URL:   l.URL,
+			})
+		}
+	}
+
+	payload.Brand = metadataString(notif, "brand")
+
+	opts := models.PushOptionsForType(notif.Type)
+	if override, err := models.ParsePushOptions(notif.PushOptions); err != nil {
+		log.Printf("⚠️ [FCM] Ignoring bad push_options on notification %s: %v", notif.ID, err)
+	} else {
+		opts = opts.Merge(override)
+	}
+	payload.Options = fcm.Options{
+		ChannelID:         opts.ChannelID,
+		Priority:          opts.Priority,
+		CollapseKey:       opts.CollapseKey,
+		Sound:             opts.Sound,
+		InterruptionLevel: opts.InterruptionLevel,
+	}
+	if opts.TTLSeconds != nil {
+		ttl := time.Duration(*opts.TTLSeconds) * time.Second
+		payload.Options.TTL = &ttl
+	}
+	// Don't deliver a push after the notification itself has gone stale
+	if notif.ExpiresAt != nil {
+		remaining := time.Until(*notif.ExpiresAt).Truncate(time.Second)
+		if remaining < 0 {
+			remaining = 0
+		}
+		if payload.Options.TTL == nil || *payload.Options.TTL > remaining {
+			payload.Options.TTL = &remaining
+		}
+	}
+	return payload
+}
+
+// notificationExpired reports whether notif is past its expires_at.
+func notificationExpired(notif *models.Notification) bool {
+	return notif.ExpiresAt != nil && !notif.ExpiresAt.After(time.Now())
+}
+
+// marshalPushOptions validates per-notification push overrides for storage.
+func marshalPushOptions(opts *models.PushOptions) (datatypes.JSON, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid push_options: %w", err)
+	}
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push_options: %w", err)
+	}
+	return datatypes.JSON(b), nil
+}
+
+// UnreadCount is the number of unread inbox items, pending or delivered (the
+// app badge), leaving out archived, snoozed, expired and recalled ones and
+// those covered by the read watermark.
+func (s *NotifyService) UnreadCount(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.NotificationRecipient{}).
+		Where("user_id = ?", userID).
+		Where(visibleUnread("notification_recipients.")).
+		Count(&count).Error
+	return int(count), err
+}
+
+// HasUnread is a cheap EXISTS check on the same items UnreadCount counts.
+func (s *NotifyService) HasUnread(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var hasUnread bool
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT EXISTS(
+			SELECT 1
+			FROM notification_recipients
+			WHERE user_id = ? AND `+visibleUnread("notification_recipients.")+`
+		)`, userID).Scan(&hasUnread).Error
+	return hasUnread, err
+}
+
 func getNotificationHeading(emailType string) string {
 	switch emailType {
 	case "email_verification":
@@ -470,6 +644,18 @@ func getString(v interface{}) string {
 	return fmt.Sprintf("%v", v)
 }
 
+// metadataString reads a string field from a notification's metadata.
+func metadataString(notif *models.Notification, key string) string {
+	if len(notif.Metadata) == 0 {
+		return ""
+	}
+	var meta map[string]interface{}
+	if err := json.Unmarshal(notif.Metadata, &meta); err != nil {
+		return ""
+	}
+	return getString(meta[key])
+}
+
 func truncate(s string, max int) string {
 	if len(s) <= max {
 		return s
@@ -504,59 +690,150 @@ func (s *NotifyService) GetUnreadNotifications(ctx context.Context, userID uuid.
 	err := s.db.WithContext(ctx).
 		Table("notifications").
 		Joins("INNER JOIN notification_recipients nr ON notifications.id = nr.notification_id").
-		Where("nr.user_id = ? AND nr.status = ?", userID, models.RecipientStatusDelivered).
+		Where("nr.user_id = ? AND nr.retracted_at IS NULL", userID).
+		Where(unreadSQL("nr.")).
+		Scopes(DefaultInboxFilter().apply).
 		Order("nr.delivered_at DESC").
 		Find(&notifs).Error
 	return notifs, err
 }
 
-// Update GetAllNotifications to accept since parameter
-func (s *NotifyService) GetAllNotifications(ctx context.Context, userID uuid.UUID, limit, offset int, since *time.Time) ([]*models.Notification, error) {
+// GetNotificationsSince is the unpaged v2 /since feed: every item delivered
+// after since (all items, including ones never marked delivered, without it),
+// newest first. Old clients advance since to the newest item they got, so it
+// must never be truncated.
+func (s *NotifyService) GetNotificationsSince(ctx context.Context, userID uuid.UUID, since *time.Time) ([]*models.Notification, error) {
 	var notifs []*models.Notification
-
 	query := s.db.WithContext(ctx).
 		Table("notifications").
+		Select("notifications.*").
 		Joins("INNER JOIN notification_recipients nr ON notifications.id = nr.notification_id").
-		Where("nr.user_id = ?", userID)
-
-	// Add timestamp filter if provided
+		Where("nr.user_id = ? AND nr.retracted_at IS NULL", userID).
+		Scopes(DefaultInboxFilter().apply)
 	if since != nil {
 		query = query.Where("nr.delivered_at > ?", *since)
 	}
-
-	err := query.
-		Order("nr.delivered_at DESC NULLS LAST, notifications.created_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&notifs).Error
-
+	err := query.Order("nr.delivered_at DESC NULLS FIRST, nr.id DESC").Find(&notifs).Error
 	return notifs, err
 }
 
-func (s *NotifyService) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, notificationIDs []uuid.UUID) error {
+// GetNotificationsPage is GetInboxPage without recipient state (v2 API).
+func (s *NotifyService) GetNotificationsPage(ctx context.Context, userID uuid.UUID, since *time.Time, page PageRequest) (Page[*models.Notification], error) {
+	inbox, err := s.GetInboxPage(ctx, userID, since, DefaultInboxFilter(), page)
+	if err != nil {
+		return Page[*models.Notification]{}, err
+	}
+	notifs := make([]*models.Notification, len(inbox.Items))
+	for i, item := range inbox.Items {
+		notifs[i] = item.Notification
+	}
+	return Page[*models.Notification]{Items: notifs, NextCursor: inbox.NextCursor, PrevCursor: inbox.PrevCursor}, nil
+}
+
+// MarkNotificationsRead marks items read and syncs the other devices of the
+// user (originDeviceID is the device that read them).
+func (s *NotifyService) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, originDeviceID string, notificationIDs []uuid.UUID) error {
 	now := time.Now()
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		return tx.Model(&models.NotificationRecipient{}).
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.NotificationRecipient{}).
 			Where("user_id = ? AND notification_id IN ?", userID, notificationIDs).
 			Updates(map[string]interface{}{
 				"status":     models.RecipientStatusRead,
 				"read_at":    now,
 				"updated_at": now,
-			}).Error
+			}).Error; err != nil {
+			return err
+		}
+		return recordInboxChange(tx, &userID, originDeviceID, SyncReadState, notificationIDs)
 	})
+	if err == nil {
+		s.RequestSync(userID, originDeviceID, SyncReadState, notificationIDs)
+	}
+	return err
 }
 
-func (s *NotifyService) MarkAllRead(ctx context.Context, userID uuid.UUID) error {
+func (s *NotifyService) MarkAllRead(ctx context.Context, userID uuid.UUID, originDeviceID string) error {
 	now := time.Now()
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		return tx.Model(&models.NotificationRecipient{}).
-			Where("user_id = ? AND status = ?", userID, models.RecipientStatusDelivered).
+	var updated []models.NotificationRecipient
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&updated).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "notification_id"}}}).
+			Where("user_id = ?", userID).
+			Where(unreadSQL("notification_recipients.")).
 			Updates(map[string]interface{}{
 				"status":     models.RecipientStatusRead,
 				"read_at":    now,
 				"updated_at": now,
-			}).Error
+			}).Error; err != nil || len(updated) == 0 {
+			return err
+		}
+		// The log gets the IDs for delta sync (up to a cap); the push stays small
+		var ids []uuid.UUID
+		if len(updated) <= watermarkEventIDLimit {
+			ids = recipientNotificationIDs(updated)
+		}
+		return recordInboxChange(tx, &userID, originDeviceID, SyncReadState, ids)
+	})
+	if err == nil && len(updated) > 0 {
+		s.RequestSync(userID, originDeviceID, SyncReadState, nil)
+	}
+	return err
+}
+
+// DeleteForUser removes items from the user's inbox (every item when
+// notificationIDs is empty) and returns how many were removed. Items still
+// awaiting acknowledgement stay: clearing everything skips them, and naming
+// one explicitly fails with ErrAckPending.
+func (s *NotifyService) DeleteForUser(ctx context.Context, userID uuid.UUID, originDeviceID string, notificationIDs []uuid.UUID) (int, error) {
+	if len(notificationIDs) > 0 {
+		var pending int64
+		if err := s.db.WithContext(ctx).Model(&models.NotificationRecipient{}).
+			Where("user_id = ? AND notification_id IN ?", userID, notificationIDs).
+			Where(ackPendingSQL("notification_recipients.")).
+			Count(&pending).Error; err != nil {
+			return 0, err
+		}
+		if pending > 0 {
+			return 0, ErrAckPending
+		}
+	}
+
+	var deleted []models.NotificationRecipient
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Returning{Columns: []clause.Column{{Name: "notification_id"}}}).
+			Where("user_id = ?", userID).
+			Where("NOT (" + ackPendingSQL("notification_recipients.") + ")")
+		if len(notificationIDs) > 0 {
+			query = query.Where("notification_id IN ?", notificationIDs)
+		}
+		if err := query.Delete(&deleted).Error; err != nil || len(deleted) == 0 {
+			return err
+		}
+		return recordInboxChange(tx, &userID, originDeviceID, SyncInboxDeleted, recipientNotificationIDs(deleted))
 	})
+	if err != nil {
+		return 0, err
+	}
+	if len(deleted) > 0 {
+		s.RequestSync(userID, originDeviceID, SyncInboxDeleted, recipientNotificationIDs(deleted))
+	}
+	return len(deleted), nil
+}
+
+func recipientNotificationIDs(recipients []models.NotificationRecipient) []uuid.UUID {
+	ids := make([]uuid.UUID, len(recipients))
+	for i, r := range recipients {
+		ids[i] = r.NotificationID
+	}
+	return ids
+}
+
+func recipientUserIDs(recipients []models.NotificationRecipient) []uuid.UUID {
+	ids := make([]uuid.UUID, len(recipients))
+	for i, r := range recipients {
+		ids[i] = r.UserID
+	}
+	return ids
 }
 
 // --- Admin: CRUD on user-created notifications (drafts/templates) ---
@@ -577,20 +854,38 @@ func (s *NotifyService) CreateNotification(ctx context.Context, req *models.Noti
 	if err != nil {
 		return nil, fmt.Errorf("invalid media_urls: %w", err)
 	}
+	pushOptionsJSON, err := marshalPushOptions(req.PushOptions)
+	if err != nil {
+		return nil, err
+	}
+	ackPolicyJSON, err := marshalAckPolicy(req.AckPolicy)
+	if err != nil {
+		return nil, err
+	}
+	escalationJSON, err := marshalEscalationPolicy(req.EscalationPolicy)
+	if err != nil {
+		return nil, err
+	}
 	notif := &models.Notification{
-		CreatorID:       *req.CreatorID,
-		Type:            models.NotificationType(req.Type),
-		Heading:         req.Heading,
-		Title:           req.Title,
-		Message:         req.Message,
-		ContentImageURL: req.ContentImageURL,
-		ThumbnailURL:    req.ThumbnailURL,
-		ContentLink:     req.ContentLink,
-		ActionLinks:     datatypes.JSON(actionsJSON),
-		Metadata:        metadataJSON,
-		MediaURLs:       datatypes.JSON(mediaURLsJSON),
-		ScheduledAt:     req.ScheduledAt,
-		IsDraft:         true,
+		CreatorID:        *req.CreatorID,
+		Type:             models.NotificationType(req.Type),
+		Category:         models.CategoryFor(req.Category, models.NotificationType(req.Type), ""),
+		Heading:          req.Heading,
+		Title:            req.Title,
+		Message:          req.Message,
+		ContentImageURL:  req.ContentImageURL,
+		ThumbnailURL:     req.ThumbnailURL,
+		ContentLink:      req.ContentLink,
+		ActionLinks:      datatypes.JSON(actionsJSON),
+		Metadata:         metadataJSON,
+		MediaURLs:        datatypes.JSON(mediaURLsJSON),
+		PushOptions:      pushOptionsJSON,
+		RequiresAck:      req.RequiresAck,
+		AckPolicy:        ackPolicyJSON,
+		EscalationPolicy: escalationJSON,
+		ScheduledAt:      req.ScheduledAt,
+		ExpiresAt:        req.ExpiresAt,
+		IsDraft:          true,
 	}
 	if err := s.db.WithContext(ctx).Create(notif).Error; err != nil {
 		return nil, fmt.Errorf("DB create failed: %w", err)
@@ -620,36 +915,141 @@ func (s *NotifyService) UpdateNotification(ctx context.Context, id uuid.UUID, re
 	if err != nil {
 		return nil, fmt.Errorf("invalid media_urls: %w", err)
 	}
+	pushOptionsJSON, err := marshalPushOptions(req.PushOptions)
+	if err != nil {
+		return nil, err
+	}
+	ackPolicyJSON, err := marshalAckPolicy(req.AckPolicy)
+	if err != nil {
+		return nil, err
+	}
+	escalationJSON, err := marshalEscalationPolicy(req.EscalationPolicy)
+	if err != nil {
+		return nil, err
+	}
 	updates := map[string]interface{}{
 		"heading":           req.Heading,
 		"title":             req.Title,
 		"message":           req.Message,
 		"type":              models.NotificationType(req.Type),
+		"category":          models.CategoryFor(req.Category, models.NotificationType(req.Type), ""),
 		"content_image_url": req.ContentImageURL,
 		"thumbnail_url":     req.ThumbnailURL,
 		"content_link":      req.ContentLink,
 		"action_links":      datatypes.JSON(actionsJSON),
 		"metadata":          metadataJSON,
 		"media_urls":        datatypes.JSON(mediaURLsJSON),
+		"push_options":      pushOptionsJSON,
+		"requires_ack":      req.RequiresAck,
+		"ack_policy":        ackPolicyJSON,
+		"escalation_policy": escalationJSON,
 		"scheduled_at":      req.ScheduledAt,
+		"expires_at":        req.ExpiresAt,
+		"expiry_swept_at":   nil,
 	}
-	if err := s.db.WithContext(ctx).Model(&existing).Updates(updates).Error; err != nil {
+	// An expiry moved into the future (or removed) brings already-expired
+	// items back; the sweeper marks them again if it passes
+	var restored []models.NotificationRecipient
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existing).Updates(updates).Error; err != nil {
+			return err
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			return nil
+		}
+		if err := tx.Model(&restored).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "user_id"}}}).
+			Where("notification_id = ? AND expired_at IS NOT NULL", id).
+			UpdateColumn("expired_at", nil).Error; err != nil {
+			return err
+		}
+		return recordRecipientsChange(tx, recipientUserIDs(restored), SyncInboxState, []uuid.UUID{id})
+	})
+	if err != nil {
 		return nil, err
 	}
+	s.requestSyncForRecipients(recipientUserIDs(restored), SyncInboxState, []uuid.UUID{id})
 	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&existing).Error; err != nil {
 		return nil, err
 	}
 	return &existing, nil
 }
 
+// Small audiences lose their recipient rows at once; above syncFanoutLimit
+// only the template is deleted here, which hides the item everywhere, and
+// PurgeDeletedRecipients removes the rows in batches.
 func (s *NotifyService) DeleteNotification(ctx context.Context, id uuid.UUID) error {
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// Delete template + all recipients
-		if err := tx.Where("notification_id = ?", id).Delete(&models.NotificationRecipient{}).Error; err != nil {
+	var userIDs []uuid.UUID
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.NotificationRecipient{}).
+			Where("notification_id = ?", id).
+			Limit(syncFanoutLimit+1).
+			Pluck("user_id", &userIDs).Error; err != nil {
+			return err
+		}
+		if len(userIDs) <= syncFanoutLimit {
+			if err := tx.Where("notification_id = ?", id).Delete(&models.NotificationRecipient{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&models.Notification{}, id).Error; err != nil {
 			return err
 		}
-		return tx.Delete(&models.Notification{}, id).Error
+		return recordRecipientsChange(tx, userIDs, SyncInboxDeleted, []uuid.UUID{id})
 	})
+	if err != nil {
+		return err
+	}
+	if len(userIDs) <= syncFanoutLimit {
+		s.requestSyncForRecipients(userIDs, SyncInboxDeleted, []uuid.UUID{id})
+		return nil
+	}
+	go func() {
+		if err := s.PurgeDeletedRecipients(context.Background()); err != nil {
+			log.Printf("⚠️ [INBOX] Purging recipients of deleted notification %s failed: %v", id, err)
+		}
+	}()
+	return nil
+}
+
+// purgeBatch bounds how many recipient rows one purge statement removes.
+const purgeBatch = 1000
+
+// PurgeDeletedRecipients removes the recipient rows of deleted notifications
+// a batch at a time and sends each batch's users a sync push. SKIP LOCKED lets
+// replicas purge side by side.
+func (s *NotifyService) PurgeDeletedRecipients(ctx context.Context) error {
+	total := 0
+	for {
+		var purged []models.NotificationRecipient
+		if err := s.db.WithContext(ctx).Raw(`
+			DELETE FROM notification_recipients
+			WHERE id IN (
+				SELECT nr.id FROM notification_recipients nr
+				INNER JOIN notifications n ON n.id = nr.notification_id
+				WHERE n.deleted_at IS NOT NULL
+				LIMIT ? FOR UPDATE OF nr SKIP LOCKED
+			)
+			RETURNING notification_id, user_id`, purgeBatch).
+			Scan(&purged).Error; err != nil {
+			return err
+		}
+		if len(purged) == 0 {
+			break
+		}
+		total += len(purged)
+		byNotification := make(map[uuid.UUID][]uuid.UUID)
+		for _, r := range purged {
+			byNotification[r.NotificationID] = append(byNotification[r.NotificationID], r.UserID)
+		}
+		for notifID, userIDs := range byNotification {
+			s.requestSyncInBatches(ctx, userIDs, SyncInboxDeleted, []uuid.UUID{notifID})
+		}
+	}
+	if total > 0 {
+		log.Printf("🗑️ [INBOX] Purged %d recipient row(s) of deleted notifications", total)
+	}
+	return nil
 }
 
 // ✅ Publish: creates *only* recipients — no copies in notifications table
@@ -657,21 +1057,21 @@ func (s *NotifyService) PublishNotification(ctx context.Context, id uuid.UUID, t
 	var template models.Notification
 	if err := s.db.WithContext(ctx).Where("id = ? AND is_draft = true", id).First(&template).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return fmt.Errorf("notification %s not found or not a draft", id)
+			return fmt.Errorf("notification %s %w", id, ErrNotDraft)
 		}
 		return err
 	}
-	// If no targets, send to all
+	if notificationExpired(&template) {
+		return fmt.Errorf("notification %s %w at %s", id, ErrNotificationExpired, template.ExpiresAt.Format(time.RFC3339))
+	}
+	// Scheduled for a segment: resolve its members now
+	if len(targetUserIDs) == 0 && template.SegmentID != nil {
+		_, err := s.PublishToSegment(ctx, id, *template.SegmentID)
+		return err
+	}
+	// If no targets, broadcast to all (bulk insert + topic push)
 	if len(targetUserIDs) == 0 {
-		var users []*models.User
-		if err := s.db.WithContext(ctx).Find(&users).Error; err != nil {
-			return fmt.Errorf("failed to fetch all users: %w", err)
-		}
-		for _, u := range users {
-			if uid, err := uuid.Parse(u.ID); err == nil {
-				targetUserIDs = append(targetUserIDs, uid)
-			}
-		}
+		return s.PublishBroadcast(ctx, id, fcm.Audience{})
 	}
 	now := time.Now()
 	recipients := make([]*models.NotificationRecipient, 0, len(targetUserIDs))
@@ -680,11 +1080,12 @@ func (s *NotifyService) PublishNotification(ctx context.Context, id uuid.UUID, t
 			NotificationID: id,
 			UserID:         userID,
 			Status:         models.RecipientStatusPending,
+			DeliveredAt:    &now, // in the inbox from now on; pending still counts as unread
 			CreatedAt:      now,
 			UpdatedAt:      now,
 		})
 	}
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Bulk insert recipients
 		if err := tx.CreateInBatches(recipients, 50).Error; err != nil {
 			return fmt.Errorf("failed to create recipients: %w", err)
@@ -695,38 +1096,134 @@ func (s *NotifyService) PublishNotification(ctx context.Context, id uuid.UUID, t
 			Updates(map[string]interface{}{
 				"is_draft":     false, // Ensure this is set to false
 				"delivered_at": &now,
+				"segment_id":   nil, // set again by PublishToSegment
 			}).Error; err != nil {
 			return fmt.Errorf("failed to update template: %w", err)
 		}
+		return recordNewItems(tx, targetUserIDs, &template)
+	})
+	if err != nil {
+		return err
+	}
 
-		// 🔥 SEND PUSH VIA FCM FOR EACH USER
-		for _, userID := range targetUserIDs {
-			s.sendPushNotificationToUser(userID, &template)
-		}
+	// 🔥 SEND PUSH VIA FCM FOR EACH USER (after commit, so badges include this item)
+	s.pushNewItems(targetUserIDs, &template)
 
-		log.Printf("✅ Published notification %s to %d users", id, len(targetUserIDs))
-		return nil
+	log.Printf("✅ Published notification %s to %d users", id, len(targetUserIDs))
+	return nil
+}
+
+// Errors a publish (or its dry run) fails with when the draft can't be sent.
+var (
+	ErrNotDraft            = errors.New("not found or not a draft")
+	ErrNotificationExpired = errors.New("expired")
+)
+
+// errNoRecipients aborts a publish whose selection matched nobody, which
+// leaves the notification a draft.
+var errNoRecipients = errors.New("no recipients selected")
+
+// publishToSelection publishes a draft to the users selected by selectSQL (a
+// query returning a uuid user_id column; "?" with a *gorm.DB argument works
+// too) with one INSERT ... SELECT, so the audience never round-trips through
+// the service, and returns who got it.
+func (s *NotifyService) publishToSelection(ctx context.Context, template *models.Notification, selectSQL string, args ...interface{}) ([]uuid.UUID, error) {
+	userIDs, err := s.insertSelection(ctx, template, selectSQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	s.pushNewItems(userIDs, template)
+	return userIDs, nil
+}
+
+// insertSelection is publishToSelection without the pushes, for callers that
+// deliver them another way.
+func (s *NotifyService) insertSelection(ctx context.Context, template *models.Notification, selectSQL string, args ...interface{}) ([]uuid.UUID, error) {
+	if notificationExpired(template) {
+		return nil, fmt.Errorf("notification %s %w at %s", template.ID, ErrNotificationExpired, template.ExpiresAt.Format(time.RFC3339))
+	}
+	now := time.Now()
+	var userIDs []uuid.UUID
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		insertArgs := append([]interface{}{template.ID, models.RecipientStatusPending, now, now, now}, args...)
+		if err := tx.Raw(`
+			INSERT INTO notification_recipients (id, notification_id, user_id, status, delivered_at, created_at, updated_at)
+			SELECT gen_random_uuid(), ?, sel.user_id, ?, ?, ?, ?
+			FROM (SELECT DISTINCT user_id FROM (`+selectSQL+`) sel) sel
+			RETURNING user_id`, insertArgs...).
+			Scan(&userIDs).Error; err != nil {
+			return fmt.Errorf("failed to create recipients: %w", err)
+		}
+		if len(userIDs) == 0 {
+			return errNoRecipients
+		}
+		result := tx.Model(template).
+			Where("id = ? AND is_draft = true", template.ID).
+			Updates(map[string]interface{}{
+				"is_draft":     false,
+				"delivered_at": &now,
+				"segment_id":   nil, // set again by PublishToSegment
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update template: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("notification %s %w", template.ID, ErrNotDraft)
+		}
+		return recordNewItems(tx, userIDs, template)
 	})
+	if err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+// recordNewItems is recordNewItem for many recipients. Above syncFanoutLimit
+// one stream event (IDs only, so no content reaches other users' streams)
+// covers everyone.
+func recordNewItems(tx *gorm.DB, userIDs []uuid.UUID, notif *models.Notification) error {
+	if len(userIDs) > syncFanoutLimit {
+		return recordInboxChange(tx, nil, "", SyncInboxCreated, []uuid.UUID{notif.ID})
+	}
+	for _, userID := range userIDs {
+		if err := recordNewItem(tx, userID, notif); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushNewItems pushes a published notification to its recipients once the
+// publish has committed. Above syncFanoutLimit the pushes are sent in the
+// background, so publishing doesn't wait on a push per user.
+func (s *NotifyService) pushNewItems(userIDs []uuid.UUID, notif *models.Notification) {
+	if len(userIDs) <= syncFanoutLimit {
+		for _, userID := range userIDs {
+			s.sendPushNotificationToUser(userID, notif)
+		}
+		return
+	}
+	go func() {
+		for _, userID := range userIDs {
+			s.sendPushNotificationToUser(userID, notif)
+		}
+		log.Printf("📨 [FCM] Background push fan-out for %s done (%d users)", notif.ID, len(userIDs))
+	}()
 }
 
 // ✅ GetAllDrafts — only drafts (is_draft = true AND scheduled_at IS NULL)
-func (s *NotifyService) GetAllDrafts(ctx context.Context, limit, offset int, creatorID *uuid.UUID) ([]*models.Notification, error) {
+func (s *NotifyService) GetAllDrafts(ctx context.Context, page PageRequest, creatorID *uuid.UUID) (Page[*models.Notification], error) {
 	query := s.db.WithContext(ctx).
-		Where("is_draft = true AND scheduled_at IS NULL").
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset)
+		Where("is_draft = true AND scheduled_at IS NULL")
 	if creatorID != nil {
 		query = query.Where("creator_id = ?", *creatorID)
 	}
-	var drafts []*models.Notification
-	err := query.Find(&drafts).Error
-	return drafts, err
+	return s.pageByCreated(query, page)
 }
 
 // ✅ GetAllNotificationsAdmin — supports filtering; returns templates only
-func (s *NotifyService) GetAllNotificationsAdmin(ctx context.Context, limit, offset int, creatorID *uuid.UUID, status string) ([]*models.Notification, error) {
-	query := s.db.WithContext(ctx).Order("created_at DESC").Limit(limit).Offset(offset)
+func (s *NotifyService) GetAllNotificationsAdmin(ctx context.Context, page PageRequest, creatorID *uuid.UUID, status string) (Page[*models.Notification], error) {
+	query := s.db.WithContext(ctx)
 	if creatorID != nil {
 		query = query.Where("creator_id = ?", *creatorID)
 	}
@@ -739,25 +1236,33 @@ func (s *NotifyService) GetAllNotificationsAdmin(ctx context.Context, limit, off
 		query = query.Where("delivered_at IS NOT NULL AND is_draft = false")
 	case "pending": // same as draft
 		query = query.Where("is_draft = true AND scheduled_at IS NULL")
+	case "retracted":
+		query = query.Where("retracted_at IS NOT NULL")
 	}
+	return s.pageByCreated(query, page)
+}
+
+// pageByCreated pages admin lists newest-first on (created_at, id).
+func (s *NotifyService) pageByCreated(query *gorm.DB, page PageRequest) (Page[*models.Notification], error) {
 	var notifs []*models.Notification
-	err := query.Find(&notifs).Error
-	return notifs, err
+	if err := keyset(query, "created_at", "id", page).Find(&notifs).Error; err != nil {
+		return Page[*models.Notification]{}, err
+	}
+	return paginate(notifs, page, func(n *models.Notification) Cursor {
+		return Cursor{At: n.CreatedAt, ID: n.ID}
+	}), nil
 }
 
 // ✅ GetNotificationHistory — templates that were delivered
 func (s *NotifyService) GetNotificationHistory(
 	ctx context.Context,
-	limit, offset int,
+	page PageRequest,
 	creatorID *uuid.UUID,
 	status string,
 	startDate, endDate *time.Time,
-) ([]*models.Notification, error) {
+) (Page[*models.Notification], error) {
 	query := s.db.WithContext(ctx).
-		Where("delivered_at IS NOT NULL AND is_draft = false").
-		Order("delivered_at DESC").
-		Limit(limit).
-		Offset(offset)
+		Where("delivered_at IS NOT NULL AND is_draft = false")
 	if creatorID != nil {
 		query = query.Where("creator_id = ?", *creatorID)
 	}
@@ -768,8 +1273,12 @@ func (s *NotifyService) GetNotificationHistory(
 		query = query.Where("delivered_at <= ?", *endDate)
 	}
 	var notifs []*models.Notification
-	err := query.Find(&notifs).Error
-	return notifs, err
+	if err := keyset(query, "delivered_at", "id", page).Find(&notifs).Error; err != nil {
+		return Page[*models.Notification]{}, err
+	}
+	return paginate(notifs, page, func(n *models.Notification) Cursor {
+		return Cursor{At: *n.DeliveredAt, ID: n.ID}
+	}), nil
 }
 
 // ✅ GetNotificationReceipts — returns ReceiptView with user info
@@ -813,6 +1322,11 @@ func (s *NotifyService) GetNotificationReceipts(ctx context.Context, notifID uui
 			Status:      string(r.Status),
 			DeliveredAt: r.DeliveredAt,
 			ReadAt:      r.ReadAt,
+			SeenAt:      r.SeenAt,
+			OpenedAt:    r.OpenedAt,
+			ClickedAt:   r.ClickedAt,
+			DismissedAt: r.DismissedAt,
+			RetractedAt: r.RetractedAt,
 		})
 	}
 	return result, nil
@@ -825,9 +1339,11 @@ func (s *NotifyService) ConvertToDraft(ctx context.Context, id uuid.UUID) error
 		if err := tx.Model(&models.Notification{}).
 			Where("id = ?", id).
 			Updates(map[string]interface{}{
-				"is_draft":     true,
-				"scheduled_at": nil,
-				"delivered_at": nil,
+				"is_draft":      true,
+				"scheduled_at":  nil,
+				"delivered_at":  nil,
+				"segment_id":    nil,
+				"audience_size": nil,
 			}).Error; err != nil {
 			return err
 		}
@@ -856,7 +1372,7 @@ func (s *NotifyService) GetSystemNotificationTemplateByEventKey(ctx context.Cont
 
 func (s *NotifyService) UpdateSystemNotificationTemplate(ctx context.Context, eventKey string, updates map[string]interface{}) error {
 	allowedUpdates := make(map[string]interface{})
-	for _, field := range []string{"heading", "title", "message", "type", "icon", "enabled"} {
+	for _, field := range []string{"heading", "title", "message", "type", "icon", "enabled", "context_schema", "push_options"} {
 		if val, ok := updates[field]; ok {
 			allowedUpdates[field] = val
 		}
@@ -911,6 +1427,7 @@ func (s *NotifyService) CreateAndDeliverSystemNotification(
 		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 	notification.Metadata = datatypes.JSON(metaBytes)
+	notification.Category = models.CategoryFor(req.Category, notification.Type, getString(meta["event_key"]))
 
 	// Media & Actions
 	mediaURLsJSON, _ := json.Marshal(req.MediaURLs)
@@ -919,6 +1436,25 @@ func (s *NotifyService) CreateAndDeliverSystemNotification(
 	actionsJSON, _ := json.Marshal(req.ActionLinks)
 	notification.ActionLinks = datatypes.JSON(actionsJSON)
 
+	pushOptionsJSON, err := marshalPushOptions(req.PushOptions)
+	if err != nil {
+		return nil, err
+	}
+	notification.PushOptions = pushOptionsJSON
+
+	ackPolicyJSON, err := marshalAckPolicy(req.AckPolicy)
+	if err != nil {
+		return nil, err
+	}
+	escalationJSON, err := marshalEscalationPolicy(req.EscalationPolicy)
+	if err != nil {
+		return nil, err
+	}
+	notification.RequiresAck = req.RequiresAck
+	notification.AckPolicy = ackPolicyJSON
+	notification.EscalationPolicy = escalationJSON
+	notification.ExpiresAt = req.ExpiresAt
+
 	// Save notification
 	if err := s.db.WithContext(ctx).Create(notification).Error; err != nil {
 		return nil, fmt.Errorf("DB create notification failed: %w", err)
@@ -933,7 +1469,12 @@ func (s *NotifyService) CreateAndDeliverSystemNotification(
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}
-	if err := s.db.WithContext(ctx).Create(recipient).Error; err != nil {
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(recipient).Error; err != nil {
+			return err
+		}
+		return recordNewItem(tx, userID, notification)
+	}); err != nil {
 		log.Printf("⚠️ Failed to create recipient for system notification %s: %v", notification.ID, err)
 		// Do not fail — notification exists; delivery is async anyway
 	}
@@ -956,7 +1497,8 @@ func (s *NotifyService) GetPublicURL(key string) string {
 }
 
 // ScheduleNotificationWithTargets — extends ScheduleNotification to accept target_user_ids
-func (s *NotifyService) ScheduleNotificationWithTargets(ctx context.Context, id uuid.UUID, scheduledAt time.Time, targetUserIDs []uuid.UUID) error {
+// or a saved segment, whose members are resolved when the notification is sent
+func (s *NotifyService) ScheduleNotificationWithTargets(ctx context.Context, id uuid.UUID, scheduledAt time.Time, targetUserIDs []uuid.UUID, segmentID *uuid.UUID) error {
 	var existing models.Notification
 	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&existing).Error; err != nil {
 		return err
@@ -974,11 +1516,27 @@ func (s *NotifyService) ScheduleNotificationWithTargets(ctx context.Context, id
 		if metaJSON, err := json.Marshal(existingMeta); err == nil {
 			updates["metadata"] = datatypes.JSON(metaJSON)
 		}
+		updates["segment_id"] = nil
+	}
+	if segmentID != nil {
+		if _, err := s.GetSegment(ctx, *segmentID); err != nil {
+			return err
+		}
+		updates["segment_id"] = *segmentID
+		if len(existing.Metadata) > 0 {
+			var existingMeta map[string]interface{}
+			if err := json.Unmarshal(existing.Metadata, &existingMeta); err == nil {
+				delete(existingMeta, "target_user_ids")
+				if metaJSON, err := json.Marshal(existingMeta); err == nil {
+					updates["metadata"] = datatypes.JSON(metaJSON)
+				}
+			}
+		}
 	}
 	return s.db.WithContext(ctx).Model(&existing).Updates(updates).Error
 }
 
-// UnscheduleNotificationWithCleanup — removes target_user_ids from metadata
+// UnscheduleNotificationWithCleanup — removes target_user_ids from metadata and the scheduled segment
 func (s *NotifyService) UnscheduleNotificationWithCleanup(ctx context.Context, id uuid.UUID) error {
 	var existing models.Notification
 	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&existing).Error; err != nil {
@@ -986,6 +1544,7 @@ func (s *NotifyService) UnscheduleNotificationWithCleanup(ctx context.Context, i
 	}
 	updates := map[string]interface{}{
 		"scheduled_at": nil,
+		"segment_id":   nil,
 	}
 	if len(existing.Metadata) > 0 {
 		var existingMeta map[string]interface{}
@@ -998,28 +1557,3 @@ func (s *NotifyService) UnscheduleNotificationWithCleanup(ctx context.Context, i
 	}
 	return s.db.WithContext(ctx).Model(&existing).Updates(updates).Error
 }
-
-// GetNotificationsSince - Get notifications for a user since a specific timestamp
-func (s *NotifyService) GetNotificationsSince(ctx context.Context, userID uuid.UUID, since *time.Time) ([]*models.Notification, error) {
-	var notifs []*models.Notification
-
-	// Base query - join with recipients table
-	query := s.db.WithContext(ctx).
-		Table("notifications").
-		Select("notifications.*").
-		Joins("INNER JOIN notification_recipients nr ON notifications.id = nr.notification_id").
-		Where("nr.user_id = ?", userID)
-
-	// Add timestamp filter if provided
-	if since != nil {
-		query = query.Where("nr.delivered_at > ?", *since)
-	}
-
-	// Order by most recent first
-	err := query.Order("nr.delivered_at DESC").Find(&notifs).Error
-	if err != nil {
-		return nil, err
-	}
-
-	return notifs, nil
-}
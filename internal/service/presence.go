@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// Presence modes for pushes while the user is active in-app.
+const (
+	PresenceModeSkip      = "skip"      // in-app only
+	PresenceModeDowngrade = "downgrade" // silent sync push instead of an alert
+)
+
+// presenceRetention is how long stale heartbeats are kept before cleanup.
+const presenceRetention = 24 * time.Hour
+
+// RecordPresence stores a heartbeat from a client device.
+func (s *NotifyService) RecordPresence(ctx context.Context, userID uuid.UUID, deviceID, state string) error {
+	if state != models.PresenceActive && state != models.PresenceBackground {
+		return fmt.Errorf("state must be %q or %q", models.PresenceActive, models.PresenceBackground)
+	}
+	now := time.Now()
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "device_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"state", "last_seen_at"}),
+	}).Create(&models.DevicePresence{
+		UserID:     userID,
+		DeviceID:   deviceID,
+		State:      state,
+		LastSeenAt: now,
+	}).Error
+}
+
+// IsUserActive reports whether any of the user's devices sent a foreground
+// heartbeat within the presence window.
+func (s *NotifyService) IsUserActive(ctx context.Context, userID uuid.UUID) (bool, error) {
+	window := s.presenceWindow()
+	if window <= 0 {
+		return false, nil
+	}
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.DevicePresence{}).
+		Where("user_id = ? AND state = ? AND last_seen_at > ?", userID, models.PresenceActive, time.Now().Add(-window)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ExpirePresence deletes heartbeats too old to matter.
+func (s *NotifyService) ExpirePresence(ctx context.Context) error {
+	return s.db.WithContext(ctx).
+		Where("last_seen_at < ?", time.Now().Add(-presenceRetention)).
+		Delete(&models.DevicePresence{}).Error
+}
+
+// PresenceWindowSeconds tells clients how often to heartbeat.
+func (s *NotifyService) PresenceWindowSeconds() int {
+	return s.cfg.PresenceWindowSeconds
+}
+
+func (s *NotifyService) presenceWindow() time.Duration {
+	return time.Duration(s.cfg.PresenceWindowSeconds) * time.Second
+}
+
+// presenceExempt reports whether a notification is pushed even while the
+// user is active (configured types and system event keys).
+func (s *NotifyService) presenceExempt(notif *models.Notification) bool {
+	if inList(s.cfg.PresenceAlwaysPushTypes, string(notif.Type)) {
+		return true
+	}
+	eventKey := metadataString(notif, "event_key")
+	return eventKey != "" && inList(s.cfg.PresenceAlwaysPushEvents, eventKey)
+}
+
+// inList checks a comma-separated config list.
+func inList(list, value string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"notify-service/internal/config"
+	"notify-service/internal/fcm"
+	"notify-service/internal/notification"
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// newTestService connects to the database named by NOTIFY_TEST_DB (other
+// connection settings come from DB_HOST, DB_PORT, ... as in production) and
+// returns a service pushing through push. Skipped when NOTIFY_TEST_DB is unset.
+func newTestService(t *testing.T, push fcm.PushSender) *NotifyService {
+	t.Helper()
+	dbName := os.Getenv("NOTIFY_TEST_DB")
+	if dbName == "" {
+		t.Skip("NOTIFY_TEST_DB not set; skipping database test")
+	}
+	cfg := &config.Config{
+		DBHost:    envOr("DB_HOST", "localhost"),
+		DBPort:    envOr("DB_PORT", "5432"),
+		DBUser:    envOr("DB_USER", "postgres"),
+		DBPass:    envOr("DB_PASS", "postgres"),
+		DBName:    dbName,
+		DBSSLMode: envOr("DB_SSLMODE", "disable"),
+	}
+	notification.InitDB(cfg)
+	return NewNotifyService(cfg, nil, nil, nil, push, nil)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func TestPublishPushesAndPrunesInvalidTokens(t *testing.T) {
+	recorder := fcm.NewRecorder(fcm.DefaultBrands())
+	s := newTestService(t, recorder)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	suffix := userID.String()
+	tokens := []*models.FCMToken{
+		{UserID: userID, DeviceID: "phone-" + suffix, Token: "good-" + suffix, Platform: "android"},
+		{UserID: userID, DeviceID: "tablet-" + suffix, Token: "gone-" + suffix, Platform: "android"},
+		{UserID: userID, DeviceID: "old-" + suffix, Token: "bad-" + suffix, Platform: "ios"},
+	}
+	if err := s.db.Create(&tokens).Error; err != nil {
+		t.Fatalf("create tokens: %v", err)
+	}
+	recorder.FailToken("gone-"+suffix, fcm.ReasonUnregistered)
+	recorder.FailToken("bad-"+suffix, fcm.ReasonInvalidToken)
+
+	creatorID := uuid.New()
+	notif, err := s.CreateNotification(ctx, &models.NotificationRequest{
+		Heading:   "Test",
+		Title:     "Publish → push → prune",
+		Message:   "hello",
+		CreatorID: &creatorID,
+	})
+	if err != nil {
+		t.Fatalf("CreateNotification: %v", err)
+	}
+	t.Cleanup(func() {
+		s.db.Unscoped().Where("user_id = ?", userID).Delete(&models.FCMToken{})
+		s.db.Unscoped().Where("notification_id = ?", notif.ID).Delete(&models.NotificationRecipient{})
+		s.db.Unscoped().Where("user_id = ?", userID).Delete(&models.InboxEvent{})
+		s.db.Unscoped().Delete(&models.Notification{}, "id = ?", notif.ID)
+	})
+
+	if err := s.PublishNotification(ctx, notif.ID, []uuid.UUID{userID}); err != nil {
+		t.Fatalf("PublishNotification: %v", err)
+	}
+
+	if pushes := recorder.SentTo("good-" + suffix); len(pushes) != 1 {
+		t.Fatalf("good token got %d push(es), want 1", len(pushes))
+	} else if pushes[0].Payload.Title != "Publish → push → prune" {
+		t.Errorf("pushed title = %q", pushes[0].Payload.Title)
+	}
+
+	want := map[string]*string{
+		"good-" + suffix: nil,
+		"gone-" + suffix: strPtr(fcm.ReasonUnregistered),
+		"bad-" + suffix:  strPtr(fcm.ReasonInvalidToken),
+	}
+	var stored []models.FCMToken
+	if err := s.db.Unscoped().Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		t.Fatalf("load tokens: %v", err)
+	}
+	for _, tok := range stored {
+		wantReason := want[tok.Token]
+		switch {
+		case wantReason == nil && tok.DeletedAt.Valid:
+			t.Errorf("%s was pruned", tok.Token)
+		case wantReason != nil && (!tok.DeletedAt.Valid || tok.RevokedReason == nil || *tok.RevokedReason != *wantReason):
+			t.Errorf("%s was not pruned as %s", tok.Token, *wantReason)
+		}
+	}
+}
+
+func strPtr(s string) *string { return &s }
+
+// publishTestNotification creates a notification from req and publishes it to
+// userIDs; it and everything it left behind are removed after the test.
+func publishTestNotification(t *testing.T, s *NotifyService, req models.NotificationRequest, userIDs ...uuid.UUID) *models.Notification {
+	t.Helper()
+	ctx := context.Background()
+	creatorID := uuid.New()
+	req.CreatorID = &creatorID
+	if req.Heading == "" {
+		req.Heading = "Test"
+	}
+	if req.Title == "" {
+		req.Title = t.Name()
+	}
+	if req.Message == "" {
+		req.Message = "hello"
+	}
+	notif, err := s.CreateNotification(ctx, &req)
+	if err != nil {
+		t.Fatalf("CreateNotification: %v", err)
+	}
+	t.Cleanup(func() {
+		s.db.Unscoped().Where("notification_id = ?", notif.ID).Delete(&models.NotificationRecipient{})
+		s.db.Unscoped().Where("user_id IN ?", userIDs).Delete(&models.InboxEvent{})
+		s.db.Unscoped().Where("user_id IN ?", userIDs).Delete(&models.ReadWatermark{})
+		s.db.Unscoped().Delete(&models.Notification{}, "id = ?", notif.ID)
+	})
+	if err := s.PublishNotification(ctx, notif.ID, userIDs); err != nil {
+		t.Fatalf("PublishNotification: %v", err)
+	}
+	return notif
+}
+
+// addTestDevice registers a push token for userID, removed after the test.
+func addTestDevice(t *testing.T, s *NotifyService, userID uuid.UUID) string {
+	t.Helper()
+	token := "tok-" + uuid.NewString()
+	if err := s.db.Create(&models.FCMToken{UserID: userID, DeviceID: "dev-" + token, Token: token, Platform: "android"}).Error; err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	t.Cleanup(func() { s.db.Unscoped().Where("token = ?", token).Delete(&models.FCMToken{}) })
+	return token
+}
+
+// testRecipient loads userID's recipient row for notifID.
+func testRecipient(t *testing.T, s *NotifyService, notifID, userID uuid.UUID) models.NotificationRecipient {
+	t.Helper()
+	var r models.NotificationRecipient
+	if err := s.db.Where("notification_id = ? AND user_id = ?", notifID, userID).First(&r).Error; err != nil {
+		t.Fatalf("load recipient: %v", err)
+	}
+	return r
+}
+
+// testUnread is UnreadCount, failing the test on error.
+func testUnread(t *testing.T, s *NotifyService, userID uuid.UUID) int {
+	t.Helper()
+	n, err := s.UnreadCount(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("UnreadCount: %v", err)
+	}
+	return n
+}
+
+// visiblePushes counts the non-silent pushes token got, waiting up to two
+// seconds for want of them since some pushes are sent in the background.
+func visiblePushes(recorder *fcm.Recorder, token string, want int) int {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		n := 0
+		for _, p := range recorder.SentTo(token) {
+			if !p.Payload.Silent {
+				n++
+			}
+		}
+		if n >= want || time.Now().After(deadline) {
+			return n
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrWatermarkTarget = errors.New("item not found in inbox")
+
+// watermarkEventIDLimit bounds how many newly read items a bulk read event
+// (watermark move, mark all read) lists. Past it the event carries no IDs
+// and delta sync clients reload.
+const watermarkEventIDLimit = 1000
+
+// coveredByWatermark is the SQL condition for a recipient row (alias is the
+// qualified prefix, e.g. "nr.") that the user's read watermark marks read.
+func coveredByWatermark(alias string) string {
+	return `EXISTS (SELECT 1 FROM inbox_read_watermarks wm
+		WHERE wm.user_id = ` + alias + `user_id
+		AND ` + alias + `delivered_at <= wm.read_up_to
+		AND (` + alias + `marked_unread_at IS NULL OR ` + alias + `marked_unread_at < wm.updated_at))`
+}
+
+// unreadStatusSQL is the SQL condition for recipient rows in an unread status:
+// published (pending) or delivered, but not yet read.
+func unreadStatusSQL(alias string) string {
+	return alias + "status IN ('pending', 'delivered')"
+}
+
+// unreadSQL is the SQL condition for unread recipient rows: in an unread
+// status and not covered by the read watermark.
+func unreadSQL(alias string) string {
+	return "(" + unreadStatusSQL(alias) + " AND NOT " + coveredByWatermark(alias) + ")"
+}
+
+// readSQL is the SQL condition for read recipient rows, explicitly or through
+// the read watermark.
+func readSQL(alias string) string {
+	return "(" + alias + "status = 'read' OR (" + unreadStatusSQL(alias) + " AND " + coveredByWatermark(alias) + "))"
+}
+
+// GetReadWatermark returns the user's watermark, or nil if none is set.
+func (s *NotifyService) GetReadWatermark(ctx context.Context, userID uuid.UUID) (*models.ReadWatermark, error) {
+	var wm models.ReadWatermark
+	err := s.db.WithContext(ctx).First(&wm, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wm, nil
+}
+
+// WatermarkAt is the delivery time of an item in the user's inbox, for
+// "read up to this item".
+func (s *NotifyService) WatermarkAt(ctx context.Context, userID, notificationID uuid.UUID) (time.Time, error) {
+	var recipient models.NotificationRecipient
+	err := s.db.WithContext(ctx).
+		Select("delivered_at").
+		Where("user_id = ? AND notification_id = ? AND delivered_at IS NOT NULL", userID, notificationID).
+		First(&recipient).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, ErrWatermarkTarget
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return *recipient.DeliveredAt, nil
+}
+
+// SetReadWatermark marks everything delivered to the user at or before
+// readUpTo as read. The watermark only moves forward and never past now;
+// recipient rows are left as they are. Returns the watermark in effect.
+func (s *NotifyService) SetReadWatermark(ctx context.Context, userID uuid.UUID, originDeviceID string, readUpTo time.Time) (*models.ReadWatermark, error) {
+	now := time.Now()
+	if readUpTo.After(now) {
+		readUpTo = now
+	}
+
+	var newlyRead []uuid.UUID
+	var moved bool
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Items this watermark marks read, for other devices and delta sync
+		if err := tx.Model(&models.NotificationRecipient{}).
+			Where("user_id = ? AND delivered_at <= ?", userID, readUpTo).
+			Where(unreadSQL("notification_recipients.")).
+			Limit(watermarkEventIDLimit+1).
+			Pluck("notification_id", &newlyRead).Error; err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"read_up_to", "updated_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: "inbox_read_watermarks.read_up_to < EXCLUDED.read_up_to"},
+			}},
+		}).Create(&models.ReadWatermark{UserID: userID, ReadUpTo: readUpTo, UpdatedAt: now})
+		if result.Error != nil {
+			return result.Error
+		}
+		moved = result.RowsAffected > 0
+		if !moved || len(newlyRead) == 0 {
+			return nil
+		}
+		data := map[string]interface{}{"read_up_to": readUpTo}
+		if len(newlyRead) <= watermarkEventIDLimit {
+			data["notification_ids"] = newlyRead
+		}
+		return recordInboxEvent(tx, &userID, originDeviceID, string(SyncReadState), data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if moved && len(newlyRead) > 0 {
+		s.RequestSync(userID, originDeviceID, SyncReadState, nil)
+	}
+	return s.GetReadWatermark(ctx, userID)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+func TestReadWatermarkAndMarkUnread(t *testing.T) {
+	s := newTestService(t, nil)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	first := publishTestNotification(t, s, models.NotificationRequest{}, userID)
+	second := publishTestNotification(t, s, models.NotificationRequest{}, userID)
+	if unread := testUnread(t, s, userID); unread != 2 {
+		t.Fatalf("unread = %d, want 2", unread)
+	}
+
+	upTo, err := s.WatermarkAt(ctx, userID, second.ID)
+	if err != nil {
+		t.Fatalf("WatermarkAt: %v", err)
+	}
+	wm, err := s.SetReadWatermark(ctx, userID, "", upTo)
+	if err != nil {
+		t.Fatalf("SetReadWatermark: %v", err)
+	}
+	if unread := testUnread(t, s, userID); unread != 0 {
+		t.Errorf("after watermark: unread = %d, want 0", unread)
+	}
+	// Recipient rows are left as they are
+	if r := testRecipient(t, s, first.ID, userID); r.Status == models.RecipientStatusRead {
+		t.Errorf("watermark rewrote the recipient row to %q", r.Status)
+	}
+
+	// It never moves back
+	moved, err := s.SetReadWatermark(ctx, userID, "", upTo.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("SetReadWatermark: %v", err)
+	}
+	if !moved.ReadUpTo.Equal(wm.ReadUpTo) {
+		t.Errorf("watermark moved back to %v, want %v", moved.ReadUpTo, wm.ReadUpTo)
+	}
+
+	// Items delivered later aren't covered
+	publishTestNotification(t, s, models.NotificationRequest{}, userID)
+	if unread := testUnread(t, s, userID); unread != 1 {
+		t.Errorf("after a new item: unread = %d, want 1", unread)
+	}
+
+	// Marking a covered item unread overrides the watermark...
+	if changed, err := s.ApplyInboxAction(ctx, userID, "", InboxMarkUnread, []uuid.UUID{first.ID}, SnoozeOptions{}); err != nil || changed != 1 {
+		t.Fatalf("mark unread: changed %d, err %v", changed, err)
+	}
+	if unread := testUnread(t, s, userID); unread != 2 {
+		t.Errorf("after mark unread: unread = %d, want 2", unread)
+	}
+
+	// ...until the watermark moves past it again
+	if _, err := s.SetReadWatermark(ctx, userID, "", time.Now()); err != nil {
+		t.Fatalf("SetReadWatermark: %v", err)
+	}
+	if unread := testUnread(t, s, userID); unread != 0 {
+		t.Errorf("after moving the watermark: unread = %d, want 0", unread)
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"notify-service/internal/notification"
+	"notify-service/internal/realtime"
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Hub returns the local stream registry used by the SSE endpoint.
+func (s *NotifyService) Hub() *realtime.Hub {
+	return s.hub
+}
+
+// StreamHeartbeat is how often an idle stream gets a keep-alive comment.
+func (s *NotifyService) StreamHeartbeat() time.Duration {
+	if s.cfg.StreamHeartbeatSeconds <= 0 {
+		return 25 * time.Second
+	}
+	return time.Duration(s.cfg.StreamHeartbeatSeconds) * time.Second
+}
+
+// listenInboxEvents feeds the hub from Postgres NOTIFY until ctx is done.
+func (s *NotifyService) listenInboxEvents(ctx context.Context) {
+	realtime.Listen(ctx, notification.DSN(s.cfg), s.hub, s)
+}
+
+// recordInboxEvent appends to the event log inside tx, the transaction that
+// makes the change it describes, and NOTIFYs every replica when tx commits. A
+// nil userID addresses every user. Concurrent writers may commit IDs out of
+// order; readers allow for that (see inboxEventSettleTime).
+func recordInboxEvent(tx *gorm.DB, userID *uuid.UUID, originDeviceID string, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+	row := &models.InboxEvent{
+		UserID:         userID,
+		Type:           eventType,
+		OriginDeviceID: originDeviceID,
+		Payload:        payload,
+	}
+	if err := tx.Create(row).Error; err != nil {
+		return err
+	}
+	msg, _ := json.Marshal(toRealtimeEvent(row))
+	if len(msg) > realtime.MaxNotifyPayload {
+		ev := toRealtimeEvent(row)
+		ev.Data, ev.Partial = nil, true
+		msg, _ = json.Marshal(ev)
+	}
+	// Delivered on commit
+	return tx.Exec("SELECT pg_notify(?, ?)", realtime.Channel, string(msg)).Error
+}
+
+// recordInboxChange mirrors a sync reason onto the stream.
+func recordInboxChange(tx *gorm.DB, userID *uuid.UUID, originDeviceID string, reason SyncReason, notificationIDs []uuid.UUID) error {
+	data := map[string]interface{}{}
+	if len(notificationIDs) > 0 {
+		data["notification_ids"] = notificationIDs
+	}
+	return recordInboxEvent(tx, userID, originDeviceID, string(reason), data)
+}
+
+// recordNewItem tells open streams about a freshly delivered inbox item; the
+// caller sends the push once tx commits.
+func recordNewItem(tx *gorm.DB, userID uuid.UUID, notif *models.Notification) error {
+	return recordInboxEvent(tx, &userID, "", string(SyncInboxCreated), map[string]interface{}{
+		"notification_ids": []uuid.UUID{notif.ID},
+		"notification":     notif,
+	})
+}
+
+// InboxEventByID implements realtime.Store.
+func (s *NotifyService) InboxEventByID(ctx context.Context, id int64) (*realtime.Event, error) {
+	var row models.InboxEvent
+	if err := s.db.WithContext(ctx).First(&row, id).Error; err != nil {
+		return nil, err
+	}
+	ev := toRealtimeEvent(&row)
+	return &ev, nil
+}
+
+// InboxEventsAfter implements realtime.Store.
+func (s *NotifyService) InboxEventsAfter(ctx context.Context, userID *uuid.UUID, afterID int64, limit int) ([]realtime.Event, error) {
+	query := s.db.WithContext(ctx).Where("id > ?", afterID)
+	if userID != nil {
+		query = query.Where("user_id = ? OR user_id IS NULL", *userID)
+	}
+	var rows []models.InboxEvent
+	if err := query.Order("id ASC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	events := make([]realtime.Event, len(rows))
+	for i := range rows {
+		events[i] = toRealtimeEvent(&rows[i])
+	}
+	return events, nil
+}
+
+// OldestInboxEventID is the earliest event a stream can still resume from
+// (0 if the log is empty).
+func (s *NotifyService) OldestInboxEventID(ctx context.Context) (int64, error) {
+	var id *int64
+	if err := s.db.WithContext(ctx).Model(&models.InboxEvent{}).Select("MIN(id)").Scan(&id).Error; err != nil || id == nil {
+		return 0, err
+	}
+	return *id, nil
+}
+
+// LatestInboxEventID is the newest event in the log (0 if empty).
+func (s *NotifyService) LatestInboxEventID(ctx context.Context) (int64, error) {
+	var id *int64
+	if err := s.db.WithContext(ctx).Model(&models.InboxEvent{}).Select("MAX(id)").Scan(&id).Error; err != nil || id == nil {
+		return 0, err
+	}
+	return *id, nil
+}
+
+// ExpireInboxEvents trims the event log to the resume window. Streams and
+// delta-sync tokens older than that must reload the inbox.
+func (s *NotifyService) ExpireInboxEvents(ctx context.Context) error {
+	retention := time.Duration(s.cfg.InboxEventRetentionHours) * time.Hour
+	if retention <= 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).
+		Where("created_at < ?", time.Now().Add(-retention)).
+		Delete(&models.InboxEvent{}).Error
+}
+
+func toRealtimeEvent(row *models.InboxEvent) realtime.Event {
+	return realtime.Event{
+		ID:             row.ID,
+		UserID:         row.UserID,
+		Type:           row.Type,
+		OriginDeviceID: row.OriginDeviceID,
+		Data:           json.RawMessage(row.Payload),
+		CreatedAt:      row.CreatedAt,
+	}
+}
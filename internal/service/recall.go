@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"notify-service/internal/fcm"
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotPublished    = errors.New("only published notifications can be recalled")
+	ErrAlreadyRecalled = errors.New("notification was already recalled")
+)
+
+// RecallOptions describes who recalls a notification, why, and what (if
+// anything) replaces it.
+type RecallOptions struct {
+	ActorID    *uuid.UUID
+	Reason     string
+	Correction *models.NotificationRequest // published to everyone who got the original
+}
+
+// RecallResult is the recalled notification and its correction, if any.
+type RecallResult struct {
+	Notification *models.Notification `json:"notification"`
+	Retracted    int                  `json:"retracted"` // recipients it was withdrawn from
+	Correction   *models.Notification `json:"correction,omitempty"`
+}
+
+// RecallNotification withdraws a published notification. Unlike
+// DeleteNotification nothing is removed: the notification and its receipts
+// stay for audit, recipients are marked retracted (which hides the item from
+// feeds, counts and delta sync) and their devices get a silent push to drop
+// it. A correction, if given, links back to the original and reaches the
+// same people: it is published to the recipients the original was withdrawn
+// from, and a broadcast's correction is pushed to the same topic audience.
+func (s *NotifyService) RecallNotification(ctx context.Context, id uuid.UUID, opts RecallOptions) (*RecallResult, error) {
+	now := time.Now()
+	var notif models.Notification
+	var retracted []models.NotificationRecipient
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&notif, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if notif.IsDraft {
+			return ErrNotPublished
+		}
+		if notif.RetractedAt != nil {
+			return ErrAlreadyRecalled
+		}
+		notif.RetractedAt = &now
+		notif.RetractedBy = opts.ActorID
+		if opts.Reason != "" {
+			notif.RetractionReason = &opts.Reason
+		}
+		if err := tx.Model(&notif).UpdateColumns(map[string]interface{}{
+			"retracted_at":      notif.RetractedAt,
+			"retracted_by":      notif.RetractedBy,
+			"retraction_reason": notif.RetractionReason,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&retracted).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "user_id"}}}).
+			Where("notification_id = ? AND retracted_at IS NULL", id).
+			UpdateColumn("retracted_at", now).Error; err != nil {
+			return err
+		}
+		return recordRecipientsChange(tx, recipientUserIDs(retracted), SyncInboxDeleted, []uuid.UUID{id})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := recipientUserIDs(retracted)
+	s.requestSyncForRecipients(userIDs, SyncInboxDeleted, []uuid.UUID{id})
+	log.Printf("↩️ [RECALL] Notification %s recalled from %d recipient(s)", id, len(userIDs))
+
+	result := &RecallResult{Notification: &notif, Retracted: len(userIDs)}
+	if opts.Correction == nil {
+		return result, nil
+	}
+	correction, err := s.CreateNotification(ctx, opts.Correction)
+	if err != nil {
+		return result, fmt.Errorf("notification recalled, but the correction could not be created: %w", err)
+	}
+	correction.CorrectionOf = &id
+	if err := s.db.WithContext(ctx).Model(correction).UpdateColumn("correction_of", id).Error; err != nil {
+		return result, fmt.Errorf("notification recalled, but the correction could not be linked: %w", err)
+	}
+	result.Correction = correction
+	if len(userIDs) == 0 {
+		// Nobody to correct; leave it as a draft rather than broadcast it
+		log.Printf("ℹ️ [RECALL] Correction %s for %s kept as draft: no recipients", correction.ID, id)
+		return result, nil
+	}
+	if err := s.publishCorrection(ctx, correction, &notif); err != nil {
+		return result, fmt.Errorf("notification recalled, but the correction could not be published: %w", err)
+	}
+	if err := s.db.WithContext(ctx).First(correction, "id = ?", correction.ID).Error; err != nil {
+		return result, err
+	}
+	log.Printf("✅ [RECALL] Correction %s published for %s", correction.ID, id)
+	return result, nil
+}
+
+// publishCorrection publishes a recall's correction to the recipients the
+// original was retracted from (a notification is recalled once, so that is
+// every retracted row), selected in the database rather than one user at a
+// time. Users who joined since the original went out don't get it. A
+// broadcast's correction is pushed like the original, to its topic audience.
+func (s *NotifyService) publishCorrection(ctx context.Context, correction, original *models.Notification) error {
+	const retractedFrom = "SELECT user_id FROM notification_recipients WHERE notification_id = ? AND retracted_at IS NOT NULL"
+	if original.BroadcastAudience == nil {
+		_, err := s.publishToSelection(ctx, correction, retractedFrom, original.ID)
+		return err
+	}
+
+	var audience fcm.Audience
+	if err := json.Unmarshal(original.BroadcastAudience, &audience); err != nil {
+		return fmt.Errorf("invalid broadcast audience on %s: %w", original.ID, err)
+	}
+	topic, condition, err := audience.Target()
+	if err != nil {
+		return err
+	}
+	if _, err := s.insertSelection(ctx, correction, retractedFrom, original.ID); err != nil {
+		return err
+	}
+	correction.BroadcastAudience = original.BroadcastAudience
+	if err := s.db.WithContext(ctx).Model(correction).
+		UpdateColumn("broadcast_audience", correction.BroadcastAudience).Error; err != nil {
+		return err
+	}
+	s.sendBroadcastPush(correction, audience, topic, condition)
+	return nil
+}
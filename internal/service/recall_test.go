@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+func TestRecallHidesItemAndPublishesCorrection(t *testing.T) {
+	s := newTestService(t, nil)
+	ctx := context.Background()
+
+	first, second := uuid.New(), uuid.New()
+	original := publishTestNotification(t, s, models.NotificationRequest{}, first, second)
+
+	creatorID := uuid.New()
+	result, err := s.RecallNotification(ctx, original.ID, RecallOptions{
+		ActorID: &creatorID,
+		Reason:  "wrong amount",
+		Correction: &models.NotificationRequest{
+			Heading:   "Correction",
+			Title:     "Corrected " + t.Name(),
+			Message:   "the right amount",
+			CreatorID: &creatorID,
+		},
+	})
+	if err != nil {
+		t.Fatalf("RecallNotification: %v", err)
+	}
+	if result.Correction == nil {
+		t.Fatal("no correction published")
+	}
+	correctionID := result.Correction.ID
+	t.Cleanup(func() {
+		s.db.Unscoped().Where("notification_id = ?", correctionID).Delete(&models.NotificationRecipient{})
+		s.db.Unscoped().Delete(&models.Notification{}, "id = ?", correctionID)
+	})
+	if result.Retracted != 2 {
+		t.Errorf("retracted from %d recipient(s), want 2", result.Retracted)
+	}
+	if result.Correction.IsDraft || result.Correction.CorrectionOf == nil || *result.Correction.CorrectionOf != original.ID {
+		t.Errorf("correction draft=%v correction_of=%v", result.Correction.IsDraft, result.Correction.CorrectionOf)
+	}
+
+	for _, userID := range []uuid.UUID{first, second} {
+		if r := testRecipient(t, s, original.ID, userID); r.RetractedAt == nil {
+			t.Errorf("user %s: recipient row not retracted", userID)
+		}
+		items, err := s.findInbox(s.inboxQuery(ctx, userID))
+		if err != nil {
+			t.Fatalf("inbox: %v", err)
+		}
+		if len(items) != 1 || items[0].Notification.ID != correctionID {
+			t.Errorf("user %s: inbox has %d item(s), want only the correction", userID, len(items))
+		}
+		if unread := testUnread(t, s, userID); unread != 1 {
+			t.Errorf("user %s: unread = %d, want 1 (the correction)", userID, unread)
+		}
+	}
+
+	if _, err := s.RecallNotification(ctx, original.ID, RecallOptions{}); !errors.Is(err, ErrAlreadyRecalled) {
+		t.Errorf("second recall: err = %v, want ErrAlreadyRecalled", err)
+	}
+}
@@ -0,0 +1,413 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrEmptySegment is returned when sending to a segment with no members.
+// Publishing with no targets would broadcast, so it is never done.
+var ErrEmptySegment = errors.New("segment has no members")
+
+// segmentSampleSize is how many members a preview lists.
+const segmentSampleSize = 20
+
+// segmentUserColumns maps user.* rule fields to columns of users u.
+var segmentUserColumns = map[string]string{
+	"user.username":   "u.username",
+	"user.email":      "u.email",
+	"user.first_name": "u.first_name",
+	"user.last_name":  "u.last_name",
+	"user.phone":      "u.phone",
+	"user.created_at": "u.created_at",
+}
+
+// segmentDeviceColumns maps device.* rule fields to columns of fcm_tokens t.
+var segmentDeviceColumns = map[string]string{
+	"device.platform":     "t.platform",
+	"device.locale":       "t.locale",
+	"device.last_seen_at": "t.last_seen_at",
+}
+
+// appVersionSQL is a token's app version as an int array ("2.14.1-beta" ->
+// {2,14,1}), or NULL when it doesn't start with a number.
+const appVersionSQL = `CASE WHEN t.app_version ~ '^[0-9]+(\.[0-9]+)*'
+	THEN string_to_array(regexp_replace(t.app_version, '^([0-9]+(\.[0-9]+)*).*$', '\1'), '.')::int[] END`
+
+// segmentUserID is u.id as a uuid, or NULL when it isn't one: users.id is
+// varchar, and Postgres may evaluate a bare u.id::uuid on any row before
+// other conditions have filtered it out.
+const segmentUserID = `(CASE WHEN u.id ~* '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$' THEN u.id::uuid END)`
+
+// lastActiveSQL is when the user was last seen on any device.
+const lastActiveSQL = `GREATEST(
+	(SELECT MAX(p.last_seen_at) FROM device_presence p WHERE p.user_id = ` + segmentUserID + `),
+	(SELECT MAX(t.last_seen_at) FROM fcm_tokens t WHERE t.user_id = ` + segmentUserID + ` AND t.deleted_at IS NULL))`
+
+var segmentComparisons = map[string]string{
+	models.SegmentOpEq:  "=",
+	models.SegmentOpGt:  ">",
+	models.SegmentOpGte: ">=",
+	models.SegmentOpLt:  "<",
+	models.SegmentOpLte: "<=",
+}
+
+// activeDevice wraps a condition on fcm_tokens t: the user has an active
+// token matching it.
+func activeDevice(cond string) string {
+	return "EXISTS (SELECT 1 FROM fcm_tokens t WHERE t.user_id = " + segmentUserID + " AND t.deleted_at IS NULL AND " + cond + ")"
+}
+
+// segmentCondition compiles one (validated) rule to a condition on users u.
+func segmentCondition(r models.SegmentRule) (string, []interface{}, error) {
+	now := time.Now()
+	switch {
+	case r.Field == "event":
+		key, err := r.Text()
+		if err != nil {
+			return "", nil, err
+		}
+		cond := `EXISTS (SELECT 1 FROM notification_recipients nr
+			INNER JOIN notifications n ON n.id = nr.notification_id
+			WHERE nr.user_id = ` + segmentUserID + ` AND n.metadata->>'event_key' = ?`
+		args := []interface{}{key}
+		if r.WithinDays > 0 {
+			cond += " AND nr.delivered_at >= ?"
+			args = append(args, now.AddDate(0, 0, -r.WithinDays))
+		}
+		cond += ")"
+		if r.Op == models.SegmentOpNotOccurred {
+			cond = "NOT " + cond
+		}
+		return cond, args, nil
+
+	case r.Field == "device.app_version":
+		version, err := r.Version()
+		if err != nil {
+			return "", nil, err
+		}
+		parts := make([]string, len(version))
+		for i, v := range version {
+			parts[i] = strconv.Itoa(v)
+		}
+		return activeDevice(appVersionSQL + " " + segmentComparisons[r.Op] + " ?::int[]"), []interface{}{"{" + strings.Join(parts, ",") + "}"}, nil
+
+	case r.Field == "engagement.read_rate" || r.Field == "engagement.received":
+		n, err := r.Number()
+		if err != nil {
+			return "", nil, err
+		}
+		metric := "COUNT(*)"
+		if r.Field == "engagement.read_rate" {
+			// NULL, so never matched, for users who received nothing
+			metric = "COUNT(*) FILTER (WHERE " + readSQL("nr.") + ")::float8 / NULLIF(COUNT(*), 0)"
+		}
+		return `(SELECT ` + metric + ` FROM notification_recipients nr
+			WHERE nr.user_id = ` + segmentUserID + ` AND nr.delivered_at >= ?) ` + segmentComparisons[r.Op] + ` ?`,
+			[]interface{}{now.AddDate(0, 0, -models.SegmentEngagementDays), n}, nil
+
+	case r.Op == models.SegmentOpWithinDays || r.Op == models.SegmentOpOlderThanDays:
+		days, err := r.Days()
+		if err != nil {
+			return "", nil, err
+		}
+		cutoff := now.AddDate(0, 0, -days)
+		within := r.Op == models.SegmentOpWithinDays
+		switch r.Field {
+		case "device.last_seen_at":
+			cond := activeDevice("t.last_seen_at >= ?")
+			if !within {
+				cond = "NOT " + cond
+			}
+			return cond, []interface{}{cutoff}, nil
+		case "engagement.last_active":
+			if within {
+				return lastActiveSQL + " >= ?", []interface{}{cutoff}, nil
+			}
+			return "(" + lastActiveSQL + " IS NULL OR " + lastActiveSQL + " < ?)", []interface{}{cutoff}, nil
+		default:
+			col := segmentUserColumns[r.Field]
+			if within {
+				return col + " >= ?", []interface{}{cutoff}, nil
+			}
+			return col + " < ?", []interface{}{cutoff}, nil
+		}
+	}
+
+	if col, ok := segmentDeviceColumns[r.Field]; ok {
+		cond, args, err := textCondition(col, r, true)
+		if err != nil {
+			return "", nil, err
+		}
+		if r.Op == models.SegmentOpNeq || r.Op == models.SegmentOpNotIn {
+			return "NOT " + activeDevice(cond), args, nil
+		}
+		return activeDevice(cond), args, nil
+	}
+	if col, ok := segmentUserColumns[r.Field]; ok {
+		return textCondition(col, r, false)
+	}
+	return "", nil, fmt.Errorf("unknown field %q", r.Field)
+}
+
+// textCondition compiles a string comparison on col. positive flips
+// neq/not_in to eq/in, for callers that negate the whole condition.
+func textCondition(col string, r models.SegmentRule, positive bool) (string, []interface{}, error) {
+	switch r.Op {
+	case models.SegmentOpExists:
+		return "(" + col + " IS NOT NULL AND " + col + " <> '')", nil, nil
+	case models.SegmentOpNotExists:
+		return "(" + col + " IS NULL OR " + col + " = '')", nil, nil
+	case models.SegmentOpIn, models.SegmentOpNotIn:
+		list, err := r.Strings()
+		if err != nil {
+			return "", nil, err
+		}
+		if r.Op == models.SegmentOpIn || positive {
+			return col + " IN ?", []interface{}{list}, nil
+		}
+		return "(" + col + " IS NULL OR " + col + " NOT IN ?)", []interface{}{list}, nil
+	}
+	value, err := r.Text()
+	if err != nil {
+		return "", nil, err
+	}
+	switch r.Op {
+	case models.SegmentOpContains:
+		return col + " ILIKE ?", []interface{}{"%" + escapeLike(value) + "%"}, nil
+	case models.SegmentOpStartsWith:
+		return col + " ILIKE ?", []interface{}{escapeLike(value) + "%"}, nil
+	case models.SegmentOpNeq:
+		if !positive {
+			return "(" + col + " IS NULL OR " + col + " <> ?)", []interface{}{value}, nil
+		}
+	}
+	return col + " = ?", []interface{}{value}, nil
+}
+
+// segmentQuery selects the synced users matching def from users u. Users
+// whose id isn't a UUID can't receive notifications and never match.
+func (s *NotifyService) segmentQuery(ctx context.Context, def models.SegmentDefinition) (*gorm.DB, error) {
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	conds := make([]string, len(def.Rules))
+	var args []interface{}
+	for i, rule := range def.Rules {
+		cond, condArgs, err := segmentCondition(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		conds[i] = "(" + cond + ")"
+		args = append(args, condArgs...)
+	}
+	join := " AND "
+	if def.Match == models.SegmentMatchAny {
+		join = " OR "
+	}
+	return s.db.WithContext(ctx).
+		Table("users u").
+		Where("u.deleted_at IS NULL AND "+segmentUserID+" IS NOT NULL").
+		Where("("+strings.Join(conds, join)+")", args...), nil
+}
+
+// PreviewSegment counts the users def matches right now, with a sample.
+func (s *NotifyService) PreviewSegment(ctx context.Context, def models.SegmentDefinition) (*models.SegmentPreview, error) {
+	query, err := s.segmentQuery(ctx, def)
+	if err != nil {
+		return nil, err
+	}
+	preview := &models.SegmentPreview{Sample: []models.SegmentUser{}}
+	if err := query.Session(&gorm.Session{}).Count(&preview.Count).Error; err != nil {
+		return nil, err
+	}
+	if err := query.Select("u.id, u.username, u.email").
+		Order("u.username, u.id").
+		Limit(segmentSampleSize).
+		Scan(&preview.Sample).Error; err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// ResolveSegment snapshots the users def matches right now.
+func (s *NotifyService) ResolveSegment(ctx context.Context, def models.SegmentDefinition) ([]uuid.UUID, error) {
+	query, err := s.segmentQuery(ctx, def)
+	if err != nil {
+		return nil, err
+	}
+	var userIDs []uuid.UUID
+	if err := query.Order("u.id").Pluck(segmentUserID, &userIDs).Error; err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+// --- Saved segments ---
+
+func (s *NotifyService) ListSegments(ctx context.Context) ([]*models.Segment, error) {
+	var segments []*models.Segment
+	err := s.db.WithContext(ctx).Order("name").Find(&segments).Error
+	return segments, err
+}
+
+func (s *NotifyService) GetSegment(ctx context.Context, id uuid.UUID) (*models.Segment, error) {
+	var segment models.Segment
+	if err := s.db.WithContext(ctx).First(&segment, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &segment, nil
+}
+
+func (s *NotifyService) CreateSegment(ctx context.Context, creatorID uuid.UUID, req *models.SegmentRequest) (*models.Segment, error) {
+	definition, err := marshalSegmentDefinition(req.Definition)
+	if err != nil {
+		return nil, err
+	}
+	segment := &models.Segment{
+		Name:        req.Name,
+		Description: req.Description,
+		Definition:  definition,
+		CreatorID:   creatorID,
+	}
+	if err := s.db.WithContext(ctx).Create(segment).Error; err != nil {
+		return nil, err
+	}
+	return segment, nil
+}
+
+// UpdateSegment replaces a segment's name, description and rules. Sends
+// already made keep the audience they were snapshotted with.
+func (s *NotifyService) UpdateSegment(ctx context.Context, id uuid.UUID, req *models.SegmentRequest) (*models.Segment, error) {
+	definition, err := marshalSegmentDefinition(req.Definition)
+	if err != nil {
+		return nil, err
+	}
+	segment, err := s.GetSegment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Model(segment).Updates(map[string]interface{}{
+		"name":            req.Name,
+		"description":     req.Description,
+		"definition":      definition,
+		"last_count":      nil,
+		"last_counted_at": nil,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return s.GetSegment(ctx, id)
+}
+
+func (s *NotifyService) DeleteSegment(ctx context.Context, id uuid.UUID) error {
+	result := s.db.WithContext(ctx).Delete(&models.Segment{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// PreviewSavedSegment previews a saved segment and records its size.
+func (s *NotifyService) PreviewSavedSegment(ctx context.Context, id uuid.UUID) (*models.SegmentPreview, error) {
+	segment, def, err := s.loadSegment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	preview, err := s.PreviewSegment(ctx, def)
+	if err != nil {
+		return nil, err
+	}
+	s.recordSegmentCount(ctx, segment.ID, int(preview.Count))
+	return preview, nil
+}
+
+// PublishToSegment publishes a draft to the segment's members as of now.
+// The resolved audience is fixed as the notification's recipients; later
+// changes to the segment or to users don't affect it. Recipients are written
+// straight from the segment query, like PublishBroadcast, and large audiences
+// get their pushes in the background.
+func (s *NotifyService) PublishToSegment(ctx context.Context, notifID, segmentID uuid.UUID) (int, error) {
+	segment, def, err := s.loadSegment(ctx, segmentID)
+	if err != nil {
+		return 0, err
+	}
+	var template models.Notification
+	if err := s.db.WithContext(ctx).Where("id = ? AND is_draft = true", notifID).First(&template).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, fmt.Errorf("notification %s %w", notifID, ErrNotDraft)
+		}
+		return 0, err
+	}
+	query, err := s.segmentQuery(ctx, def)
+	if err != nil {
+		return 0, err
+	}
+	userIDs, err := s.publishToSelection(ctx, &template, "?", query.Select(segmentUserID+" AS user_id"))
+	if errors.Is(err, errNoRecipients) {
+		s.recordSegmentCount(ctx, segment.ID, 0)
+		return 0, ErrEmptySegment
+	}
+	if err != nil {
+		return 0, err
+	}
+	s.recordSegmentCount(ctx, segment.ID, len(userIDs))
+	if err := s.db.WithContext(ctx).Model(&models.Notification{}).
+		Where("id = ?", notifID).
+		UpdateColumns(map[string]interface{}{
+			"segment_id":    segment.ID,
+			"audience_size": len(userIDs),
+		}).Error; err != nil {
+		log.Printf("⚠️ [SEGMENT] Failed to record audience of %s: %v", notifID, err)
+	}
+	log.Printf("🎯 [SEGMENT] Published %s to segment %q (%d users)", notifID, segment.Name, len(userIDs))
+	return len(userIDs), nil
+}
+
+func (s *NotifyService) loadSegment(ctx context.Context, id uuid.UUID) (*models.Segment, models.SegmentDefinition, error) {
+	segment, err := s.GetSegment(ctx, id)
+	if err != nil {
+		return nil, models.SegmentDefinition{}, err
+	}
+	def, err := models.ParseSegmentDefinition(segment.Definition)
+	if err != nil {
+		return nil, def, err
+	}
+	return segment, def, nil
+}
+
+func (s *NotifyService) recordSegmentCount(ctx context.Context, id uuid.UUID, count int) {
+	if err := s.db.WithContext(ctx).Model(&models.Segment{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"last_count":      count,
+			"last_counted_at": time.Now(),
+		}).Error; err != nil {
+		log.Printf("⚠️ [SEGMENT] Failed to record size of %s: %v", id, err)
+	}
+}
+
+func marshalSegmentDefinition(def models.SegmentDefinition) (datatypes.JSON, error) {
+	if err := def.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid definition: %w", err)
+	}
+	b, err := json.Marshal(def)
+	if err != nil {
+		return nil, fmt.Errorf("invalid definition: %w", err)
+	}
+	return datatypes.JSON(b), nil
+}
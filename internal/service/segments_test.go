@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"notify-service/pkg/models"
+)
+
+func rule(field, op string, value interface{}) models.SegmentRule {
+	r := models.SegmentRule{Field: field, Op: op}
+	if value != nil {
+		r.Value, _ = json.Marshal(value)
+	}
+	return r
+}
+
+func TestSegmentDefinitionValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     models.SegmentDefinition
+		wantErr bool
+	}{
+		{name: "single rule", def: models.SegmentDefinition{Rules: []models.SegmentRule{rule("device.platform", "eq", "ios")}}},
+		{name: "match any", def: models.SegmentDefinition{Match: "any", Rules: []models.SegmentRule{rule("user.email", "exists", nil)}}},
+		{name: "bad match", def: models.SegmentDefinition{Match: "some", Rules: []models.SegmentRule{rule("user.email", "exists", nil)}}, wantErr: true},
+		{name: "no rules", def: models.SegmentDefinition{}, wantErr: true},
+		{name: "unknown field", def: models.SegmentDefinition{Rules: []models.SegmentRule{rule("user.password", "eq", "x")}}, wantErr: true},
+		{name: "op not allowed for field", def: models.SegmentDefinition{Rules: []models.SegmentRule{rule("device.platform", "contains", "io")}}, wantErr: true},
+		{name: "empty list", def: models.SegmentDefinition{Rules: []models.SegmentRule{rule("device.locale", "in", []string{})}}, wantErr: true},
+		{name: "read rate above 1", def: models.SegmentDefinition{Rules: []models.SegmentRule{rule("engagement.read_rate", "gt", 1.5)}}, wantErr: true},
+		{name: "days out of range", def: models.SegmentDefinition{Rules: []models.SegmentRule{rule("user.created_at", "within_days", 0)}}, wantErr: true},
+		{name: "bad version", def: models.SegmentDefinition{Rules: []models.SegmentRule{rule("device.app_version", "gte", "beta")}}, wantErr: true},
+		{
+			name:    "within_days on a non-event rule",
+			def:     models.SegmentDefinition{Rules: []models.SegmentRule{{Field: "user.username", Op: "eq", Value: json.RawMessage(`"a"`), WithinDays: 7}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.def.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSegmentCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     models.SegmentRule
+		contains []string
+		args     int
+	}{
+		{name: "user text", rule: rule("user.username", "eq", "ana"), contains: []string{"u.username = ?"}, args: 1},
+		{name: "user neq keeps nulls", rule: rule("user.email", "neq", "a@b.c"), contains: []string{"u.email IS NULL OR u.email <> ?"}, args: 1},
+		{name: "contains", rule: rule("user.first_name", "contains", "50%"), contains: []string{"u.first_name ILIKE ?"}, args: 1},
+		{name: "device eq", rule: rule("device.platform", "eq", "ios"), contains: []string{"EXISTS (SELECT 1 FROM fcm_tokens t", "t.platform = ?"}, args: 1},
+		{name: "device not_in negates", rule: rule("device.locale", "not_in", []string{"fr"}), contains: []string{"NOT EXISTS", "t.locale IN ?"}, args: 1},
+		{name: "app version", rule: rule("device.app_version", "gte", "2.14"), contains: []string{">= ?::int[]"}, args: 1},
+		{name: "read rate", rule: rule("engagement.read_rate", "lt", 0.2), contains: []string{"NULLIF(COUNT(*), 0)", "< ?"}, args: 2},
+		{name: "last active older", rule: rule("engagement.last_active", "older_than_days", 30), contains: []string{"IS NULL OR"}, args: 1},
+		{name: "event not occurred", rule: models.SegmentRule{Field: "event", Op: "not_occurred", Value: json.RawMessage(`"kyc.approved"`), WithinDays: 7}, contains: []string{"NOT EXISTS", "nr.delivered_at >= ?"}, args: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); err != nil {
+				t.Fatalf("rule invalid: %v", err)
+			}
+			cond, args, err := segmentCondition(tt.rule)
+			if err != nil {
+				t.Fatalf("segmentCondition: %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(cond, want) {
+					t.Errorf("condition %q does not contain %q", cond, want)
+				}
+			}
+			if len(args) != tt.args {
+				t.Errorf("got %d args, want %d", len(args), tt.args)
+			}
+			if strings.Count(cond, "?") != len(args) {
+				t.Errorf("%d placeholders for %d args in %q", strings.Count(cond, "?"), len(args), cond)
+			}
+			// users.id is varchar: it may only be cast behind the UUID guard
+			if strings.Count(cond, "u.id::uuid") != strings.Count(cond, segmentUserID) {
+				t.Errorf("unguarded u.id::uuid cast in %q", cond)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"notify-service/internal/fcm"
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SyncReason says why a client should re-fetch; several may be coalesced into one push.
+type SyncReason string
+
+const (
+	SyncInboxCreated SyncReason = "inbox.created"
+	SyncInboxDeleted SyncReason = "inbox.deleted"
+	SyncReadState    SyncReason = "read_state"
+	SyncInboxState   SyncReason = "inbox.state" // archived, pinned, snoozed, woken or unexpired
+	SyncInboxExpired SyncReason = "inbox.expired"
+	SyncPreferences  SyncReason = "preferences"
+)
+
+// syncFanoutLimit is the recipient count above which a change is announced
+// with one event for everyone and sync pushes sent in background batches.
+const syncFanoutLimit = 500
+
+// syncFanoutBatch is how many recipients one background sync batch covers.
+const syncFanoutBatch = 500
+
+// RequestSync sends a silent, data-only push telling the user's devices
+// (except the one that made the change) to fetch new data. Each device gets at
+// most one sync push per SYNC_PUSH_MIN_INTERVAL_SECONDS; requests inside the
+// window are coalesced into a single trailing push. Runs asynchronously.
+func (s *NotifyService) RequestSync(userID uuid.UUID, originDeviceID string, reason SyncReason, notificationIDs []uuid.UUID) {
+	if s.push == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var tokens []string
+		query := s.db.WithContext(ctx).Model(&models.FCMToken{}).Where("user_id = ?", userID)
+		if originDeviceID != "" {
+			query = query.Where("device_id <> ?", originDeviceID)
+		}
+		if err := query.Pluck("token", &tokens).Error; err != nil {
+			log.Printf("⚠️ [FCM] Sync: failed to fetch tokens for user %s: %v", userID, err)
+			return
+		}
+
+		var now []string
+		for _, token := range tokens {
+			if s.syncLimiter.admit(token, reason, func(reasons []SyncReason) {
+				s.sendSyncPush(userID, []string{token}, reasons, nil)
+			}) {
+				now = append(now, token)
+			}
+		}
+		if len(now) > 0 {
+			s.sendSyncPush(userID, now, []SyncReason{reason}, notificationIDs)
+		}
+	}()
+}
+
+// PreferencesChanged tells the user's devices to re-fetch notification
+// preferences. Preferences live in the profile service, which reports changes.
+func (s *NotifyService) PreferencesChanged(ctx context.Context, userID uuid.UUID, originDeviceID string) error {
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return recordInboxChange(tx, &userID, originDeviceID, SyncPreferences, nil)
+	}); err != nil {
+		return err
+	}
+	s.RequestSync(userID, originDeviceID, SyncPreferences, nil)
+	return nil
+}
+
+// recordRecipientsChange records a change for everyone who received a
+// notification, inside the transaction making it; large audiences get one
+// event addressed to every user.
+func recordRecipientsChange(tx *gorm.DB, userIDs []uuid.UUID, reason SyncReason, notificationIDs []uuid.UUID) error {
+	if len(userIDs) > syncFanoutLimit {
+		return recordInboxChange(tx, nil, "", reason, notificationIDs)
+	}
+	for _, userID := range userIDs {
+		if err := recordInboxChange(tx, &userID, "", reason, notificationIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestSyncForRecipients sends the sync pushes for a change recorded by
+// recordRecipientsChange once it has committed; large audiences are pushed in
+// batches by requestSyncInBatches.
+func (s *NotifyService) requestSyncForRecipients(userIDs []uuid.UUID, reason SyncReason, notificationIDs []uuid.UUID) {
+	if len(userIDs) <= syncFanoutLimit {
+		for _, userID := range userIDs {
+			s.RequestSync(userID, "", reason, notificationIDs)
+		}
+		return
+	}
+	if s.push == nil {
+		return
+	}
+	go s.requestSyncInBatches(context.Background(), userIDs, reason, notificationIDs)
+}
+
+// requestSyncInBatches sends a sync push to the devices of many users, one
+// batch of users at a time and through the per-device limiter. The pushes
+// carry no badge: it would take an unread count per user.
+func (s *NotifyService) requestSyncInBatches(ctx context.Context, userIDs []uuid.UUID, reason SyncReason, notificationIDs []uuid.UUID) {
+	if s.push == nil {
+		return
+	}
+	sent := 0
+	for start := 0; start < len(userIDs); start += syncFanoutBatch {
+		batch := userIDs[start:min(start+syncFanoutBatch, len(userIDs))]
+		var tokens []string
+		if err := s.db.WithContext(ctx).Model(&models.FCMToken{}).
+			Where("user_id IN ?", batch).
+			Pluck("token", &tokens).Error; err != nil {
+			log.Printf("⚠️ [FCM] Sync: failed to fetch tokens for %d user(s): %v", len(batch), err)
+			continue
+		}
+		var now []string
+		for _, token := range tokens {
+			if s.syncLimiter.admit(token, reason, func(reasons []SyncReason) {
+				s.sendBatchSyncPush([]string{token}, reasons, nil)
+			}) {
+				now = append(now, token)
+			}
+		}
+		sent += s.sendBatchSyncPush(now, []SyncReason{reason}, notificationIDs)
+	}
+	log.Printf("🔄 [FCM] Sync (%s) sent to %d device(s) across %d user(s)", reason, sent, len(userIDs))
+}
+
+// sendBatchSyncPush multicasts a badge-less sync push and returns how many
+// devices it went to.
+func (s *NotifyService) sendBatchSyncPush(tokens []string, reasons []SyncReason, notificationIDs []uuid.UUID) int {
+	if len(tokens) == 0 {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	failures, err := s.push.SendToMultipleTokens(ctx, tokens, syncPayload(reasons, notificationIDs, nil))
+	s.pruneTokens(failures)
+	if err != nil {
+		log.Printf("❌ [FCM] Sync push (%s) to %d device(s) failed: %v", joinReasons(reasons), len(tokens), err)
+		return 0
+	}
+	return len(tokens)
+}
+
+func (s *NotifyService) sendSyncPush(userID uuid.UUID, tokens []string, reasons []SyncReason, notificationIDs []uuid.UUID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var badge *int
+	if unread, err := s.UnreadCount(ctx, userID); err == nil {
+		badge = &unread
+	} else {
+		log.Printf("⚠️ [FCM] Sync: unread count failed for user %s: %v", userID, err)
+	}
+	failures, err := s.push.SendToMultipleTokens(ctx, tokens, syncPayload(reasons, notificationIDs, badge))
+	s.pruneTokens(failures)
+	if err != nil {
+		log.Printf("❌ [FCM] Sync push failed for user %s: %v", userID, err)
+		return
+	}
+	log.Printf("🔄 [FCM] Sync (%s) sent to %d device(s) for user %s", joinReasons(reasons), len(tokens), userID)
+}
+
+// syncPayload is the silent push clients treat as "fetch /since now".
+// notification_ids is omitted for coalesced pushes, meaning "sync everything".
+func syncPayload(reasons []SyncReason, notificationIDs []uuid.UUID, badge *int) fcm.Payload {
+	data := map[string]interface{}{
+		"type":    "sync",
+		"reasons": joinReasons(reasons),
+	}
+	if len(notificationIDs) > 0 {
+		b, _ := json.Marshal(notificationIDs)
+		data["notification_ids"] = string(b)
+	}
+	if badge != nil {
+		data["unread_count"] = *badge
+	}
+	return fcm.Payload{Silent: true, Badge: badge, Data: data}
+}
+
+func joinReasons(reasons []SyncReason) string {
+	parts := make([]string, len(reasons))
+	for i, r := range reasons {
+		parts[i] = string(r)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// syncLimiter allows one sync push per device per interval and coalesces the
+// rest into a trailing push when the window closes.
+type syncLimiter struct {
+	mu        sync.Mutex
+	interval  time.Duration
+	devices   map[string]*deviceSyncState // by token
+	lastSweep time.Time
+}
+
+type deviceSyncState struct {
+	last    time.Time
+	pending map[SyncReason]bool
+	timer   *time.Timer
+}
+
+func newSyncLimiter(interval time.Duration) *syncLimiter {
+	return &syncLimiter{interval: interval, devices: make(map[string]*deviceSyncState)}
+}
+
+// admit reports whether a push may go to token now. If not, reason is queued
+// and flush is called with all queued reasons once the window closes.
+func (l *syncLimiter) admit(token string, reason SyncReason, flush func([]SyncReason)) bool {
+	if l.interval <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+	st, ok := l.devices[token]
+	if !ok || now.Sub(st.last) >= l.interval {
+		if !ok {
+			st = &deviceSyncState{}
+			l.devices[token] = st
+		}
+		st.last = now
+		return true
+	}
+
+	if st.pending == nil {
+		st.pending = make(map[SyncReason]bool)
+	}
+	st.pending[reason] = true
+	if st.timer == nil {
+		st.timer = time.AfterFunc(st.last.Add(l.interval).Sub(now), func() {
+			l.mu.Lock()
+			reasons := make([]SyncReason, 0, len(st.pending))
+			for r := range st.pending {
+				reasons = append(reasons, r)
+			}
+			st.pending = nil
+			st.timer = nil
+			st.last = time.Now()
+			l.mu.Unlock()
+			flush(reasons)
+		})
+	}
+	return false
+}
+
+// sweep drops idle devices so the map doesn't grow without bound.
+func (l *syncLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < 10*l.interval {
+		return
+	}
+	l.lastSweep = now
+	for token, st := range l.devices {
+		if st.timer == nil && now.Sub(st.last) >= l.interval {
+			delete(l.devices, token)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSyncLimiterCoalescesWithinWindow(t *testing.T) {
+	l := newSyncLimiter(200 * time.Millisecond)
+	flushed := make(chan []SyncReason, 2)
+	flush := func(reasons []SyncReason) { flushed <- reasons }
+
+	if !l.admit("tok", SyncInboxCreated, flush) {
+		t.Fatal("first push should be admitted")
+	}
+	if l.admit("tok", SyncReadState, flush) {
+		t.Fatal("second push inside the window should be held back")
+	}
+	if l.admit("tok", SyncInboxDeleted, flush) {
+		t.Fatal("third push inside the window should be held back")
+	}
+	if l.admit("tok", SyncReadState, flush) {
+		t.Fatal("repeated reason inside the window should be held back")
+	}
+	if !l.admit("other", SyncReadState, flush) {
+		t.Fatal("devices are limited independently")
+	}
+
+	select {
+	case reasons := <-flushed:
+		got := make([]string, len(reasons))
+		for i, r := range reasons {
+			got[i] = string(r)
+		}
+		sort.Strings(got)
+		want := []string{string(SyncInboxDeleted), string(SyncReadState)}
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("flushed reasons = %v, want %v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("held-back reasons were never flushed")
+	}
+	select {
+	case reasons := <-flushed:
+		t.Errorf("unexpected second flush %v", reasons)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The trailing push opens a new window
+	if l.admit("tok", SyncInboxCreated, flush) {
+		t.Error("push right after the trailing push should be held back")
+	}
+}
+
+func TestSyncLimiterDisabled(t *testing.T) {
+	l := newSyncLimiter(0)
+	for i := 0; i < 3; i++ {
+		if !l.admit("tok", SyncInboxCreated, func([]SyncReason) { t.Error("flush called with limiter disabled") }) {
+			t.Fatalf("push %d held back with limiter disabled", i)
+		}
+	}
+}
+
+func TestJoinReasonsIsSorted(t *testing.T) {
+	got := joinReasons([]SyncReason{SyncReadState, SyncInboxCreated, SyncPreferences})
+	if want := "inbox.created,preferences,read_state"; got != want {
+		t.Errorf("joinReasons = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"notify-service/internal/fcm"
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// topicSyncBatch bounds how many devices one reconciliation pass handles.
+const topicSyncBatch = 500
+
+// broadcastFallbackBatch is how many unsubscribed devices one multicast of a
+// broadcast fallback covers (FCM's multicast limit).
+const broadcastFallbackBatch = 500
+
+// maxTopicSyncAttempts is how often a revoked device's unsubscribe is tried
+// before it is given up on; active devices keep retrying at the maximum
+// backoff.
+const maxTopicSyncAttempts = 5
+
+// SyncTopicSubscriptions reconciles FCM topic subscriptions (all, platform-*,
+// locale-*) for devices marked dirty at registration or revocation. Active
+// devices get TopicsFor(platform, locale); revoked ones get none. Devices are
+// claimed with SKIP LOCKED and their next attempt pushed back (1 min doubling
+// to 1 h) before any IID call, so overlapping passes don't repeat calls and
+// devices that keep failing don't hold up newer ones.
+func (s *NotifyService) SyncTopicSubscriptions(ctx context.Context) error {
+	if s.push == nil {
+		return nil
+	}
+	now := time.Now()
+	// Revoked devices that can't be unsubscribed only cost topic fan-out
+	if result := s.db.WithContext(ctx).Unscoped().Model(&models.FCMToken{}).
+		Where("topics_synced_at IS NULL AND deleted_at IS NOT NULL AND topic_sync_attempts >= ?", maxTopicSyncAttempts).
+		UpdateColumn("topics_synced_at", now); result.Error != nil {
+		return fmt.Errorf("give up topic sync for revoked devices: %w", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("⚠️ [FCM] Gave up unsubscribing %d revoked device(s) after %d attempts", result.RowsAffected, maxTopicSyncAttempts)
+	}
+
+	var tokens []models.FCMToken
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().
+			Where("topics_synced_at IS NULL AND (topic_sync_next_at IS NULL OR topic_sync_next_at <= ?)", now).
+			Order("updated_at").
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Limit(topicSyncBatch).
+			Find(&tokens).Error; err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(tokens))
+		for i, t := range tokens {
+			ids[i] = t.ID
+		}
+		return tx.Unscoped().Model(&models.FCMToken{}).
+			Where("id IN ?", ids).
+			UpdateColumns(map[string]interface{}{
+				"topic_sync_attempts": gorm.Expr("topic_sync_attempts + 1"),
+				"topic_sync_next_at":  gorm.Expr("?::timestamptz + make_interval(secs => LEAST(60 * power(2, topic_sync_attempts), 3600))", now),
+			}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("claim devices for topic sync: %w", err)
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	add := make(map[string][]string)
+	remove := make(map[string][]string)
+	desired := make(map[uuid.UUID][]string, len(tokens))
+	for _, t := range tokens {
+		var want []string
+		if !t.DeletedAt.Valid {
+			want = fcm.TopicsFor(t.Platform, t.Locale)
+		}
+		have := splitTopics(t.Topics)
+		for _, topic := range difference(want, have) {
+			add[topic] = append(add[topic], t.Token)
+		}
+		for _, topic := range difference(have, want) {
+			remove[topic] = append(remove[topic], t.Token)
+		}
+		desired[t.ID] = want
+	}
+
+	failed := make(map[string]bool)
+	var invalid []fcm.TokenFailure
+	apply := func(op string, byTopic map[string][]string, call func(context.Context, []string, string) ([]fcm.TokenFailure, error)) {
+		for topic, list := range byTopic {
+			failures, err := call(ctx, list, topic)
+			if err != nil {
+				log.Printf("⚠️ [FCM] Topic %s %s failed for %d token(s): %v", op, topic, len(list), err)
+				for _, t := range list {
+					failed[t] = true
+				}
+				continue
+			}
+			for _, f := range failures {
+				invalid = append(invalid, f)
+				// A dead token is in no topic, so unsubscribing it is done
+				if op == "subscribe" {
+					failed[f.Token] = true
+				}
+			}
+		}
+	}
+	apply("subscribe", add, s.push.SubscribeToTopic)
+	apply("unsubscribe", remove, s.push.UnsubscribeFromTopic)
+
+	synced := 0
+	for _, t := range tokens {
+		if failed[t.Token] {
+			continue
+		}
+		// updated_at guards against the device changing during the pass
+		if err := s.db.WithContext(ctx).Unscoped().Model(&models.FCMToken{}).
+			Where("id = ? AND updated_at = ? AND token = ?", t.ID, t.UpdatedAt, t.Token).
+			UpdateColumns(map[string]interface{}{
+				"topics":              strings.Join(desired[t.ID], ","),
+				"topics_synced_at":    now,
+				"topic_sync_attempts": 0,
+				"topic_sync_next_at":  nil,
+			}).Error; err != nil {
+			log.Printf("⚠️ [FCM] Failed to record topics for device %s: %v", t.ID, err)
+			continue
+		}
+		synced++
+	}
+	s.pruneTokens(invalid)
+	log.Printf("🔁 [FCM] Topic sync: %d/%d device(s) reconciled, %d pruned", synced, len(tokens), len(invalid))
+	return nil
+}
+
+// syncTopicsNow runs a reconciliation pass in the background right after a
+// device changed, instead of waiting for the worker. Devices another pass has
+// claimed are skipped.
+func (s *NotifyService) syncTopicsNow() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.SyncTopicSubscriptions(ctx); err != nil {
+		log.Printf("⚠️ [FCM] Topic sync failed: %v", err)
+	}
+}
+
+// unsubscribeAll removes a replaced token from its old topics (best effort).
+func (s *NotifyService) unsubscribeAll(token string, topics []string) {
+	if s.push == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, topic := range topics {
+		if _, err := s.push.UnsubscribeFromTopic(ctx, []string{token}, topic); err != nil {
+			log.Printf("⚠️ [FCM] Unsubscribe replaced token from %s failed: %v", topic, err)
+		}
+	}
+}
+
+// PublishBroadcast publishes a draft to every user: in-app recipient rows are
+// written with one INSERT ... SELECT and the push goes out as a single FCM
+// topic/condition message, plus a multicast to devices whose topic
+// subscriptions haven't been synced yet. The audience narrows only the push;
+// the in-app inbox item reaches every user.
+func (s *NotifyService) PublishBroadcast(ctx context.Context, id uuid.UUID, audience fcm.Audience) error {
+	topic, condition, err := audience.Target()
+	if err != nil {
+		return err
+	}
+
+	var template models.Notification
+	if err := s.db.WithContext(ctx).Where("id = ? AND is_draft = true", id).First(&template).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("notification %s %w", id, ErrNotDraft)
+		}
+		return err
+	}
+	if notificationExpired(&template) {
+		return fmt.Errorf("notification %s %w at %s", id, ErrNotificationExpired, template.ExpiresAt.Format(time.RFC3339))
+	}
+
+	audienceJSON, err := json.Marshal(audience)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var delivered int64
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(`
+			INSERT INTO notification_recipients (id, notification_id, user_id, status, delivered_at, created_at, updated_at)
+			SELECT gen_random_uuid(), ?, u.id::uuid, ?, ?, ?, ?
+			FROM users u
+			WHERE u.deleted_at IS NULL
+			  AND u.id ~* '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$'`,
+			id, models.RecipientStatusPending, now, now, now)
+		if result.Error != nil {
+			return fmt.Errorf("failed to create recipients: %w", result.Error)
+		}
+		delivered = result.RowsAffected
+		if err := tx.Model(&template).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"is_draft":           false,
+				"delivered_at":       &now,
+				"broadcast_audience": datatypes.JSON(audienceJSON),
+			}).Error; err != nil {
+			return fmt.Errorf("failed to update template: %w", err)
+		}
+		return recordInboxEvent(tx, nil, "", string(SyncInboxCreated), map[string]interface{}{
+			"notification_ids": []uuid.UUID{template.ID},
+			"notification":     &template,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("✅ Published broadcast %s to %d users", id, delivered)
+
+	s.sendBroadcastPush(&template, audience, topic, condition)
+	return nil
+}
+
+// sendBroadcastPush sends one push to a topic or condition, then multicasts
+// to devices in the audience that the topic push misses (subscriptions not
+// synced yet, or one of the targeted topics failed). The fallback devices
+// are taken before the topic send, so none gets both. Broadcast pushes
+// deliberately skip the per-user rules of sendPushNotificationToUser: one
+// message can't carry a per-user badge (clients refresh their unread count on
+// receipt), can't leave out users active in-app (presence), and only the
+// notification's own expiry is checked, not per-recipient state.
+func (s *NotifyService) sendBroadcastPush(notif *models.Notification, audience fcm.Audience, topic, condition string) {
+	if s.push == nil {
+		log.Printf("⚠️ [FCM] Skip broadcast push: push sender not configured")
+		return
+	}
+	if notificationExpired(notif) {
+		log.Printf("⌛ [FCM] Skip broadcast push: notification %s has expired", notif.ID)
+		return
+	}
+	fallback, err := s.broadcastFallbackTokens(audience)
+	if err != nil {
+		log.Printf("⚠️ [FCM] Broadcast fallback for %s: failed to load devices: %v", notif.ID, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	payload := pushPayload(notif, map[string]interface{}{
+		"notification_id": notif.ID.String(),
+		"type":            string(notif.Type),
+		"click_action":    "OPEN_NOTIFICATION",
+		"broadcast":       true,
+	})
+	if condition != "" {
+		err = s.push.SendToCondition(ctx, condition, payload)
+	} else {
+		err = s.push.SendToTopic(ctx, topic, payload)
+	}
+	if err != nil {
+		log.Printf("❌ [FCM] Broadcast push for %s failed: %v", notif.ID, err)
+	} else {
+		log.Printf("📣 [FCM] Broadcast push for %s sent (topic=%q condition=%q)", notif.ID, topic, condition)
+	}
+	if len(fallback) > 0 {
+		go s.sendBroadcastFallback(notif.ID, fallback, payload)
+	}
+}
+
+// broadcastFallbackTokens lists active devices in the audience that a topic
+// push to it wouldn't reach with their current subscriptions.
+func (s *NotifyService) broadcastFallbackTokens(audience fcm.Audience) ([]string, error) {
+	topicCond, topicArgs := topicCondition(audience)
+	var lastID uuid.UUID
+	var tokens []string
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		var batch []models.FCMToken
+		query := s.db.WithContext(ctx).
+			Table("fcm_tokens t").
+			Select("t.id, t.token, t.platform, t.locale, t.topics").
+			Where("t.deleted_at IS NULL AND NOT ("+topicCond+")", topicArgs...).
+			Order("t.id").
+			Limit(broadcastFallbackBatch)
+		if lastID != uuid.Nil {
+			query = query.Where("t.id > ?", lastID)
+		}
+		err := query.Find(&batch).Error
+		cancel()
+		if err != nil {
+			return tokens, err
+		}
+		for _, t := range batch {
+			if audience.Includes(t.Platform, t.Locale) && !audience.Reaches(splitTopics(t.Topics)) {
+				tokens = append(tokens, t.Token)
+			}
+		}
+		if len(batch) < broadcastFallbackBatch {
+			return tokens, nil
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
+
+// sendBroadcastFallback multicasts a broadcast to the devices the topic push
+// misses, so they don't go without while the sync backlog drains.
+func (s *NotifyService) sendBroadcastFallback(notifID uuid.UUID, tokens []string, payload fcm.Payload) {
+	sent := 0
+	for start := 0; start < len(tokens); start += broadcastFallbackBatch {
+		batch := tokens[start:min(start+broadcastFallbackBatch, len(tokens))]
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		failures, err := s.push.SendToMultipleTokens(ctx, batch, payload)
+		cancel()
+		s.pruneTokens(failures)
+		if err != nil {
+			log.Printf("⚠️ [FCM] Broadcast fallback for %s failed for %d device(s): %v", notifID, len(batch), err)
+			continue
+		}
+		sent += len(batch)
+	}
+	if sent > 0 {
+		log.Printf("📣 [FCM] Broadcast %s also multicast to %d device(s) the topic push misses", notifID, sent)
+	}
+}
+
+func splitTopics(topics string) []string {
+	if topics == "" {
+		return nil
+	}
+	return strings.Split(topics, ",")
+}
+
+// difference returns the items of a not in b.
+func difference(a, b []string) []string {
+	var out []string
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, x)
+		}
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+
+	"notify-service/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// UnreadCounts totals the user's badge-visible unread items by type and
+// category. Every category is present, zero or not, so tabs stay stable.
+func (s *NotifyService) UnreadCounts(ctx context.Context, userID uuid.UUID) (*models.UnreadCounts, error) {
+	var rows []struct {
+		Type     models.NotificationType
+		Category models.NotificationCategory
+		Count    int
+	}
+	err := s.db.WithContext(ctx).
+		Table("notification_recipients nr").
+		Select("n.type, n.category, COUNT(*) AS count").
+		Joins("INNER JOIN notifications n ON n.id = nr.notification_id AND n.deleted_at IS NULL").
+		Where("nr.user_id = ?", userID).
+		Where(visibleUnread("nr.")).
+		Group("n.type, n.category").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := &models.UnreadCounts{
+		ByType:     make(map[models.NotificationType]int),
+		ByCategory: make(map[models.NotificationCategory]int, len(models.Categories)),
+	}
+	for _, c := range models.Categories {
+		counts.ByCategory[c] = 0
+	}
+	for _, r := range rows {
+		counts.Total += r.Count
+		counts.ByType[r.Type] += r.Count
+		counts.ByCategory[r.Category] += r.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"notify-service/internal/email"
+	"notify-service/internal/notification"
+	"notify-service/internal/schema"
+	"notify-service/pkg/models"
+)
+
+// ValidateEmailRequest checks an email request against the declared context
+// schema for its (normalized) type.
+func (s *NotifyService) ValidateEmailRequest(req *models.EmailRequest) []schema.Violation {
+	emailType := strings.ToLower(strings.TrimSpace(req.Type))
+	return email.ValidateRequest(emailType, req.To, req.Context)
+}
+
+// ValidateTemplateVariables checks trigger variables against the template's
+// context schema. Templates without a stored schema fall back to the default
+// schema derived from template_vars.
+func (s *NotifyService) ValidateTemplateVariables(tpl *models.SystemNotificationTemplate, vars map[string]interface{}) ([]schema.Violation, error) {
+	sch, err := schema.Parse(tpl.ContextSchema)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", tpl.EventKey, err)
+	}
+	if sch == nil {
+		var requiredVars []string
+		if len(tpl.TemplateVars) > 0 {
+			if err := json.Unmarshal(tpl.TemplateVars, &requiredVars); err != nil {
+				return nil, fmt.Errorf("template %s: invalid template_vars format: %w", tpl.EventKey, err)
+			}
+		}
+		sch = notification.ContextSchemaForVars(requiredVars)
+	}
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	violations := sch.Validate(vars)
+	for i := range violations {
+		violations[i].Field = "variables." + violations[i].Field
+	}
+	return violations, nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// StartWorkers launches the service's periodic background jobs. They stop
+// when ctx is cancelled.
+func (s *NotifyService) StartWorkers(ctx context.Context) {
+	go s.runEvery(ctx, "fcm-token-expiry", time.Hour, s.ExpireInactiveTokens)
+	go s.runEvery(ctx, "fcm-topic-sync", time.Minute, s.SyncTopicSubscriptions)
+	go s.runEvery(ctx, "presence-cleanup", time.Hour, s.ExpirePresence)
+	go s.runEvery(ctx, "inbox-event-cleanup", time.Hour, s.ExpireInboxEvents)
+	go s.runEvery(ctx, "snooze-wakeup", time.Minute, s.WakeSnoozed)
+	go s.runEvery(ctx, "ack-reminders", 5*time.Minute, s.SendAckReminders)
+	go s.runEvery(ctx, "escalations", time.Minute, s.SendEscalations)
+	go s.runEvery(ctx, "expiry-sweep", time.Minute, s.ExpireNotifications)
+	go s.runEvery(ctx, "deleted-purge", time.Minute, s.PurgeDeletedRecipients)
+	go s.listenInboxEvents(ctx)
+}
+
+// runEvery runs job immediately and then on every tick until ctx is done.
+func (s *NotifyService) runEvery(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	log.Printf("⏰ [WORKER] %s started (every %v)", name, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		jobCtx, cancel := context.WithTimeout(ctx, interval)
+		if err := job(jobCtx); err != nil {
+			log.Printf("⚠️ [WORKER] %s failed: %v", name, err)
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			log.Printf("🛑 [WORKER] %s stopped", name)
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// internal/sms/sender.go
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Sender delivers text messages. WebhookSender hands them to an SMS gateway
+// over HTTP; Recorder keeps everything in memory.
+type Sender interface {
+	Send(ctx context.Context, to, body string) error
+}
+
+var (
+	_ Sender = (*WebhookSender)(nil)
+	_ Sender = (*Recorder)(nil)
+)
+
+// WebhookSender POSTs {"to": "+15551234567", "body": "..."} to the gateway URL.
+type WebhookSender struct {
+	url    string
+	token  string // sent as a bearer token when set
+	client *http.Client
+}
+
+func NewWebhookSender(url, token string) *WebhookSender {
+	return &WebhookSender{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (w *WebhookSender) Send(ctx context.Context, to, body string) error {
+	payload, err := json.Marshal(map[string]string{"to": to, "body": body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("SMS gateway request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("SMS gateway returned %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// RecordedSMS is one message captured by a Recorder.
+type RecordedSMS struct {
+	To     string    `json:"to"`
+	Body   string    `json:"body"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// Recorder is an in-memory Sender for tests and offline environments.
+type Recorder struct {
+	mu   sync.Mutex
+	sent []RecordedSMS
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Send(ctx context.Context, to, body string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, RecordedSMS{To: to, Body: body, SentAt: time.Now()})
+	return nil
+}
+
+// Sent returns a copy of everything recorded so far.
+func (r *Recorder) Sent() []RecordedSMS {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedSMS(nil), r.sent...)
+}
+
+// Reset forgets recorded messages.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = nil
+}
@@ -20,6 +20,7 @@ type User struct {
 	FirstName         *string `json:"first_name,omitempty"`
 	LastName          *string `json:"last_name,omitempty"`
 	ProfilePictureURL *string `json:"profile_picture_url,omitempty"`
+	Phone             *string `json:"phone,omitempty"`
 	UpdatedAt         time.Time `json:"updated_at"`
 }
 
@@ -247,6 +248,7 @@ func (s *UserSyncService) syncUserToDB(ctx context.Context, user User) error {
 		existingUser.FirstName = user.FirstName
 		existingUser.LastName = user.LastName
 		existingUser.ProfilePictureURL = user.ProfilePictureURL
+		existingUser.Phone = user.Phone
 		existingUser.UpdatedAt = user.UpdatedAt
 		
 		return s.db.WithContext(ctx).Save(&existingUser).Error
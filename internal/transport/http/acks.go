@@ -0,0 +1,104 @@
+package http
+
+import (
+	"errors"
+	"log"
+
+	"notify-service/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// AcknowledgeNotification - POST /user/:user_id/notifications/:notification_id/ack
+// Records the acknowledgement of a notification that requires one.
+func (h *NotificationHandler) AcknowledgeNotification(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+	notificationID, err := uuid.Parse(c.Params("notification_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid notification_id"})
+	}
+	recipient, err := h.notifyService.Acknowledge(c.Context(), userID, c.Get("X-Device-ID"), notificationID)
+	switch {
+	case errors.Is(err, service.ErrNotRecipient):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "notification not found"})
+	case errors.Is(err, service.ErrAckNotRequired):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		log.Printf("❌ AcknowledgeNotification: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to acknowledge notification"})
+	}
+	return c.JSON(fiber.Map{
+		"status":          "success",
+		"notification_id": notificationID,
+		"acknowledged_at": recipient.AcknowledgedAt,
+	})
+}
+
+// ListOutstandingAcks - GET /admin/acks
+// Ack-required notifications still waiting on some recipients.
+func (h *NotificationHandler) ListOutstandingAcks(c *fiber.Ctx) error {
+	page, err := getPageRequest(c, 20, 100)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
+	}
+	acks, err := h.notifyService.ListOutstandingAcks(c.Context(), page)
+	if err != nil {
+		log.Printf("❌ ListOutstandingAcks: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch acknowledgements"})
+	}
+	return c.JSON(pageBody("notifications", acks))
+}
+
+// GetNotificationAcks - GET /admin/notifications/:id/acks?status=outstanding|acknowledged
+func (h *NotificationHandler) GetNotificationAcks(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid notification id"})
+	}
+	var acknowledged *bool
+	switch c.Query("status") {
+	case "", "all":
+	case "outstanding":
+		no := false
+		acknowledged = &no
+	case "acknowledged":
+		yes := true
+		acknowledged = &yes
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "status must be outstanding, acknowledged or all"})
+	}
+	page, err := getPageRequest(c, 50, 200)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
+	}
+	acks, err := h.notifyService.GetNotificationAcks(c.Context(), id, acknowledged, page)
+	if err != nil {
+		log.Printf("❌ GetNotificationAcks: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch acknowledgements"})
+	}
+	return c.JSON(pageBody("recipients", acks))
+}
+
+// GetNotificationEscalations - GET /admin/notifications/:id/escalations
+// Recipients that were escalated for leaving a notification unread, with
+// every step taken.
+func (h *NotificationHandler) GetNotificationEscalations(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid notification id"})
+	}
+	page, err := getPageRequest(c, 50, 200)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
+	}
+	escalations, err := h.notifyService.GetNotificationEscalations(c.Context(), id, page)
+	if err != nil {
+		log.Printf("❌ GetNotificationEscalations: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch escalations"})
+	}
+	return c.JSON(pageBody("recipients", escalations))
+}
@@ -2,7 +2,9 @@
 package http
 
 import (
+	"errors"
 	"log"
+	"notify-service/internal/schema"
 	"notify-service/internal/service"
 	"notify-service/pkg/models"
 
@@ -35,6 +37,10 @@ func (h *Handler) SendEmail(c *fiber.Ctx) error {
 	log.Printf("📬 [EMAIL REQUEST] From: %s | User: %s | Type: %s", c.Locals("device_id"), req.UserID, req.Type)
 
 	err := h.notifyService.SendEmail(c.Context(), &req)
+	var validationErr *schema.Error
+	if errors.As(err, &validationErr) {
+		return validationFailed(c, validationErr.Violations)
+	}
 	if err != nil {
 		log.Printf("❌ SendEmail failed: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to queue email"})
@@ -44,4 +50,12 @@ func (h *Handler) SendEmail(c *fiber.Ctx) error {
 		"status":  "queued",
 		"message": "Email queued for delivery",
 	})
-}
\ No newline at end of file
+}
+
+// validationFailed responds 422 with every schema violation
+func validationFailed(c *fiber.Ctx, violations []schema.Violation) error {
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"error":      "validation failed",
+		"violations": violations,
+	})
+}
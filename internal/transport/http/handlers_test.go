@@ -0,0 +1,69 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"notify-service/internal/config"
+	"notify-service/internal/schema"
+	"notify-service/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func TestSendEmailRejectsInvalidContext(t *testing.T) {
+	h := NewHandler(service.NewNotifyService(&config.Config{}, nil, nil, nil, nil, nil))
+	app := fiber.New()
+	app.Post("/email", h.SendEmail)
+
+	tests := []struct {
+		name string
+		body string
+		want []string // field:rule
+	}{
+		{
+			name: "unknown type",
+			body: `{"user_id":"` + uuid.NewString() + `","to":"a@b.co","type":"nope"}`,
+			want: []string{"type:enum"},
+		},
+		{
+			name: "bad address and otp",
+			body: `{"user_id":"` + uuid.NewString() + `","to":"nope","type":"otp","context":{"otp":"12ab"}}`,
+			want: []string{"to:format", "context.otp:pattern"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/email", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusUnprocessableEntity {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("status = %d, want 422: %s", resp.StatusCode, body)
+			}
+			var out struct {
+				Error      string             `json:"error"`
+				Violations []schema.Violation `json:"violations"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			got := map[string]bool{}
+			for _, v := range out.Violations {
+				got[v.Field+":"+v.Rule] = true
+			}
+			for _, w := range tt.want {
+				if !got[w] {
+					t.Errorf("missing violation %s in %+v", w, out.Violations)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"notify-service/internal/service"
+	"notify-service/pkg/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// v3 user feed: same routes as v2, but each entry is an InboxItem carrying the
+// recipient's status, delivered/read times and archived/pinned flags. The
+// feed can be filtered and searched, see parseInboxFilter.
+
+// GetInbox - GET /v3/user/:user_id
+func (h *NotificationHandler) GetInbox(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+	since, err := parseSince(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid since parameter, must be RFC3339 format"})
+	}
+
+	filter, err := parseInboxFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	page, err := getPageRequest(c, 20, 100)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
+	}
+	items, err := h.notifyService.GetInboxPage(c.Context(), userID, since, filter, page)
+	if err != nil {
+		log.Printf("❌ GetInbox: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch notifications"})
+	}
+	return c.JSON(pageBody("items", items))
+}
+
+// GetUnreadInbox - GET /v3/user/:user_id/unread
+func (h *NotificationHandler) GetUnreadInbox(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+	items, err := h.notifyService.GetUnreadInbox(c.Context(), userID)
+	if err != nil {
+		log.Printf("❌ GetUnreadInbox: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch unread notifications"})
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// GetInboxSince - GET /v3/user/:user_id/since
+func (h *NotificationHandler) GetInboxSince(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+	since, err := parseSince(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid since parameter, must be RFC3339 format"})
+	}
+	filter, err := parseInboxFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	page, err := getPageRequest(c, 100, sinceMaxItems)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
+	}
+	items, err := h.notifyService.GetInboxPage(c.Context(), userID, since, filter, page)
+	if err != nil {
+		log.Printf("❌ Failed to get inbox since %v: %v", since, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get notifications"})
+	}
+	return c.JSON(pageBody("items", items))
+}
+
+// SyncInbox - GET /v3/user/:user_id/sync?token=
+// Delta sync: changes since the token the previous call returned. Without a
+// token (or once it has expired) the response has reset=true and the client
+// reloads its inbox before syncing from the returned token.
+func (h *NotificationHandler) SyncInbox(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+	limit := getQueryInt(c, "limit", 200, 1, 1000)
+	delta, err := h.notifyService.GetInboxDelta(c.Context(), userID, c.Query("token"), limit)
+	if errors.Is(err, service.ErrInvalidSyncToken) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid sync token"})
+	}
+	if err != nil {
+		log.Printf("❌ SyncInbox: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to sync inbox"})
+	}
+	return c.JSON(delta)
+}
+
+// parseSince reads the optional RFC3339 ?since= parameter.
+func parseSince(c *fiber.Ctx) (*time.Time, error) {
+	since := c.Query("since")
+	if since == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// maxSearchQuery bounds the ?q= full-text query.
+const maxSearchQuery = 200
+
+// parseInboxFilter reads the feed filters:
+//   - archived, pinned, snoozed, read, expired: true, false or all. By default
+//     archived, snoozed and expired items are hidden; expired=all returns
+//     expired items flagged in their delivery state.
+//   - type, category: comma-separated lists
+//   - from, to: RFC3339 bounds on delivery time
+//   - event_key: metadata event key, "wallet.*" for a prefix
+//   - q: full-text search over heading, title and message
+func parseInboxFilter(c *fiber.Ctx) (service.InboxFilter, error) {
+	filter := service.DefaultInboxFilter()
+	for key, field := range map[string]**bool{
+		"archived": &filter.Archived,
+		"pinned":   &filter.Pinned,
+		"snoozed":  &filter.Snoozed,
+		"read":     &filter.Read,
+		"expired":  &filter.Expired,
+	} {
+		switch c.Query(key) {
+		case "":
+		case "all":
+			*field = nil
+		case "true":
+			yes := true
+			*field = &yes
+		case "false":
+			no := false
+			*field = &no
+		default:
+			return filter, fmt.Errorf("%s must be true, false or all", key)
+		}
+	}
+
+	for _, t := range splitQueryList(c.Query("type")) {
+		filter.Types = append(filter.Types, models.NotificationType(t))
+	}
+	for _, cat := range splitQueryList(c.Query("category")) {
+		category := models.NotificationCategory(cat)
+		if !category.Valid() {
+			return filter, fmt.Errorf("invalid category %q", cat)
+		}
+		filter.Categories = append(filter.Categories, category)
+	}
+	for key, field := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := c.Query(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s parameter, must be RFC3339 format", key)
+			}
+			*field = &t
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return filter, fmt.Errorf("to must be after from")
+	}
+	filter.EventKey = strings.TrimSpace(c.Query("event_key"))
+	filter.Query = strings.TrimSpace(c.Query("q"))
+	if len(filter.Query) > maxSearchQuery {
+		return filter, fmt.Errorf("q must be at most %d characters", maxSearchQuery)
+	}
+	return filter, nil
+}
+
+// splitQueryList splits a comma-separated query value, dropping blanks.
+func splitQueryList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// ApplyInboxAction returns the handler for one archive/pin/snooze/mark-unread
+// action, on a single item (POST /user/:user_id/notifications/:notification_id/<action>)
+// or in bulk (POST /user/:user_id/<action> with notification_ids).
+func (h *NotificationHandler) ApplyInboxAction(action service.InboxAction) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := uuid.Parse(c.Params("user_id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+		}
+		var req struct {
+			NotificationIDs []uuid.UUID `json:"notification_ids"`
+			Until           *time.Time  `json:"until,omitempty"`  // snooze only
+			Repush          bool        `json:"repush,omitempty"` // snooze only
+		}
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+			}
+		}
+		if idStr := c.Params("notification_id"); idStr != "" {
+			id, err := uuid.Parse(idStr)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid notification_id"})
+			}
+			req.NotificationIDs = []uuid.UUID{id}
+		}
+		if len(req.NotificationIDs) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "notification_ids required"})
+		}
+		if len(req.NotificationIDs) > 500 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "at most 500 notification_ids per request"})
+		}
+		var snooze service.SnoozeOptions
+		if action == service.InboxSnooze {
+			if req.Until == nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "until is required to snooze"})
+			}
+			snooze = service.SnoozeOptions{Until: *req.Until, Repush: req.Repush}
+		}
+
+		updated, err := h.notifyService.ApplyInboxAction(c.Context(), userID, c.Get("X-Device-ID"), action, req.NotificationIDs, snooze)
+		if errors.Is(err, service.ErrInvalidSnooze) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			log.Printf("❌ ApplyInboxAction(%s): %v", action, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update notifications"})
+		}
+		return c.JSON(fiber.Map{"status": "success", "action": action, "updated": updated})
+	}
+}
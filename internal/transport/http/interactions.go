@@ -0,0 +1,65 @@
+package http
+
+import (
+	"errors"
+	"log"
+
+	"notify-service/internal/service"
+	"notify-service/pkg/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxInteractionBatch bounds how many events one report may carry.
+const maxInteractionBatch = 100
+
+// RecordInteractions - POST /user/:user_id/interactions
+// Clients report impressions, opens, action clicks and dismissals, batched:
+//
+//	{"events": [{"notification_id": "...", "type": "action_click", "action_index": 0}]}
+//
+// A push open may send the push's data payload as "push_data" instead of
+// notification_id. Events may carry an "event_id" so retries aren't counted
+// twice.
+func (h *NotificationHandler) RecordInteractions(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+	var req struct {
+		Events []models.InteractionEvent `json:"events"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	if len(req.Events) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "events required"})
+	}
+	if len(req.Events) > maxInteractionBatch {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "at most 100 events per request"})
+	}
+	recorded, err := h.notifyService.RecordInteractions(c.Context(), userID, c.Get("X-Device-ID"), req.Events)
+	if errors.Is(err, service.ErrInvalidInteraction) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Printf("❌ RecordInteractions: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to record interactions"})
+	}
+	return c.JSON(fiber.Map{"status": "success", "recorded": recorded})
+}
+
+// GetInteractionSummary - GET /admin/notifications/:id/interactions
+func (h *NotificationHandler) GetInteractionSummary(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid notification id"})
+	}
+	summary, err := h.notifyService.GetInteractionSummary(c.Context(), id)
+	if err != nil {
+		log.Printf("❌ GetInteractionSummary: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch interactions"})
+	}
+	return c.JSON(summary)
+}
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"notify-service/internal/calendar"
+	"notify-service/internal/fcm"
+	"notify-service/internal/schema"
 	"notify-service/internal/service"
 	"notify-service/pkg/models"
 	"strconv"
@@ -13,8 +16,8 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 type NotificationHandler struct {
@@ -65,6 +68,12 @@ func (h *NotificationHandler) CreateNotification(c *fiber.Ctx) error {
 	if req.Heading == "" || req.Title == "" || req.Message == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "heading, title, and message are required"})
 	}
+	if req.Category != "" && !models.NotificationCategory(req.Category).Valid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid category"})
+	}
+	if msg := expiryError(req.ExpiresAt, req.ScheduledAt); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
 	notification, err := h.notifyService.CreateNotification(c.Context(), &req)
 	if err != nil {
 		log.Printf("❌ CreateNotification failed: %v", err)
@@ -90,6 +99,12 @@ func (h *NotificationHandler) UpdateNotification(c *fiber.Ctx) error {
 	if req.Heading == "" || req.Title == "" || req.Message == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "heading, title, and message are required"})
 	}
+	if req.Category != "" && !models.NotificationCategory(req.Category).Valid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid category"})
+	}
+	if msg := expiryError(req.ExpiresAt, req.ScheduledAt); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
 	notification, err := h.notifyService.UpdateNotification(c.Context(), id, &req)
 	if err != nil {
 		log.Printf("❌ UpdateNotification failed: %v", err)
@@ -119,7 +134,9 @@ func (h *NotificationHandler) DeleteNotification(c *fiber.Ctx) error {
 	})
 }
 
-// PublishNotification — sends to targeted users (idempotent on recipients)
+// PublishNotification — sends to targeted users (idempotent on recipients).
+// With dry_run (body or ?dry_run=true) it only reports the expected reach;
+// preferences, frequency caps and quiet hours are not applied to it.
 func (h *NotificationHandler) PublishNotification(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := uuid.Parse(idStr)
@@ -127,11 +144,45 @@ func (h *NotificationHandler) PublishNotification(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid notification id"})
 	}
 	var req struct {
-		TargetUserIDs []uuid.UUID `json:"target_user_ids"`
+		TargetUserIDs []uuid.UUID   `json:"target_user_ids"`
+		Audience      *fcm.Audience `json:"audience,omitempty"`   // broadcast push filter (platforms/locales)
+		SegmentID     *uuid.UUID    `json:"segment_id,omitempty"` // saved segment, resolved now
+		DryRun        bool          `json:"dry_run,omitempty"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
 	}
+	if req.SegmentID != nil && (len(req.TargetUserIDs) > 0 || req.Audience != nil) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "segment_id can't be combined with target_user_ids or audience"})
+	}
+	if req.Audience != nil {
+		if len(req.TargetUserIDs) > 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "audience applies to broadcasts only; omit target_user_ids"})
+		}
+		if _, _, err := req.Audience.Target(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if req.DryRun || c.QueryBool("dry_run") {
+		return h.estimatePublish(c, id, service.PublishTarget{
+			UserIDs:   req.TargetUserIDs,
+			SegmentID: req.SegmentID,
+			Audience:  req.Audience,
+		})
+	}
+	if req.SegmentID != nil {
+		return h.publishToSegment(c, id, *req.SegmentID)
+	}
+	if req.Audience != nil {
+		if err := h.notifyService.PublishBroadcast(c.Context(), id, *req.Audience); err != nil {
+			log.Printf("❌ PublishNotification (broadcast) failed: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{
+			"status":  "success",
+			"message": "notification broadcast to all users",
+		})
+	}
 	if err := h.notifyService.PublishNotification(c.Context(), id, req.TargetUserIDs); err != nil {
 		log.Printf("❌ PublishNotification failed: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
@@ -144,8 +195,10 @@ func (h *NotificationHandler) PublishNotification(c *fiber.Ctx) error {
 
 // ✅ GetAllDrafts
 func (h *NotificationHandler) GetAllDrafts(c *fiber.Ctx) error {
-	limit := getQueryInt(c, "limit", 20, 1, 100)
-	offset := getQueryInt(c, "offset", 0, 0, 10000)
+	page, err := getPageRequest(c, 20, 100)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
+	}
 	creatorIDStr := c.Query("creator_id")
 	var creatorID *uuid.UUID
 	if creatorIDStr != "" {
@@ -155,12 +208,12 @@ func (h *NotificationHandler) GetAllDrafts(c *fiber.Ctx) error {
 		}
 		creatorID = &id
 	}
-	drafts, err := h.notifyService.GetAllDrafts(c.Context(), limit, offset, creatorID)
+	drafts, err := h.notifyService.GetAllDrafts(c.Context(), page, creatorID)
 	if err != nil {
 		log.Printf("❌ GetAllDrafts: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch drafts"})
 	}
-	return c.JSON(fiber.Map{"drafts": drafts})
+	return c.JSON(pageBody("drafts", drafts))
 }
 
 // ✅ GetNotificationReceipts
@@ -197,8 +250,10 @@ func (h *NotificationHandler) ConvertToDraft(c *fiber.Ctx) error {
 
 // ✅ GetNotificationHistory
 func (h *NotificationHandler) GetNotificationHistory(c *fiber.Ctx) error {
-	limit := getQueryInt(c, "limit", 20, 1, 100)
-	offset := getQueryInt(c, "offset", 0, 0, 10000)
+	page, err := getPageRequest(c, 20, 100)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
+	}
 	creatorIDStr := c.Query("creator_id")
 	var creatorID *uuid.UUID
 	if creatorIDStr != "" {
@@ -225,41 +280,47 @@ func (h *NotificationHandler) GetNotificationHistory(c *fiber.Ctx) error {
 		}
 		endDate = &t
 	}
-	result, err := h.notifyService.GetNotificationHistory(c.Context(), limit, offset, creatorID, "", startDate, endDate)
+	result, err := h.notifyService.GetNotificationHistory(c.Context(), page, creatorID, "", startDate, endDate)
 	if err != nil {
 		log.Printf("❌ GetNotificationHistory: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch history"})
 	}
-	return c.JSON(fiber.Map{"notifications": result})
+	return c.JSON(pageBody("notifications", result))
 }
 
-// ✅ BulkDeliverNotification — alias to Publish with target_all
+// ✅ BulkDeliverNotification — alias to Publish with target_all (dry_run supported)
 func (h *NotificationHandler) BulkDeliverNotification(c *fiber.Ctx) error {
 	var req struct {
 		NotificationID uuid.UUID   `json:"notification_id"`
 		UserIDs        []uuid.UUID `json:"user_ids"`
 		TargetAll      bool        `json:"target_all"`
+		SegmentID      *uuid.UUID  `json:"segment_id"`
+		DryRun         bool        `json:"dry_run"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid JSON"})
 	}
-	var targetUserIDs []uuid.UUID
-	if req.TargetAll {
-		all, err := h.notifyService.GetAllUsers(c.Context())
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch all users"})
+	dryRun := req.DryRun || c.QueryBool("dry_run")
+	if req.SegmentID != nil {
+		if req.TargetAll || len(req.UserIDs) > 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "segment_id can't be combined with user_ids or target_all"})
 		}
-		for _, u := range all {
-			if uid, err := uuid.Parse(u.ID); err == nil {
-				targetUserIDs = append(targetUserIDs, uid)
-			}
+		if dryRun {
+			return h.estimatePublish(c, req.NotificationID, service.PublishTarget{SegmentID: req.SegmentID})
 		}
-	} else {
+		return h.publishToSegment(c, req.NotificationID, *req.SegmentID)
+	}
+	// No targets = broadcast (bulk recipients + topic push)
+	var targetUserIDs []uuid.UUID
+	if !req.TargetAll {
 		targetUserIDs = req.UserIDs
 		if len(targetUserIDs) == 0 {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "user_ids required if target_all=false"})
 		}
 	}
+	if dryRun {
+		return h.estimatePublish(c, req.NotificationID, service.PublishTarget{UserIDs: targetUserIDs})
+	}
 	if err := h.notifyService.PublishNotification(c.Context(), req.NotificationID, targetUserIDs); err != nil {
 		log.Printf("❌ BulkDeliverNotification: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
@@ -272,8 +333,10 @@ func (h *NotificationHandler) BulkDeliverNotification(c *fiber.Ctx) error {
 
 // Admin list: supports status filter
 func (h *NotificationHandler) GetAllNotificationsAdmin(c *fiber.Ctx) error {
-	limit := getQueryInt(c, "limit", 20, 1, 100)
-	offset := getQueryInt(c, "offset", 0, 0, 10000)
+	page, err := getPageRequest(c, 20, 100)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
+	}
 	creatorIDStr := c.Query("creator_id")
 	var creatorID *uuid.UUID
 	if creatorIDStr != "" {
@@ -284,12 +347,12 @@ func (h *NotificationHandler) GetAllNotificationsAdmin(c *fiber.Ctx) error {
 		creatorID = &id
 	}
 	status := c.Query("status")
-	notifications, err := h.notifyService.GetAllNotificationsAdmin(c.Context(), limit, offset, creatorID, status)
+	notifications, err := h.notifyService.GetAllNotificationsAdmin(c.Context(), page, creatorID, status)
 	if err != nil {
 		log.Printf("❌ GetAllNotificationsAdmin: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch notifications"})
 	}
-	return c.JSON(fiber.Map{"notifications": notifications})
+	return c.JSON(pageBody("notifications", notifications))
 }
 
 func (h *NotificationHandler) ScheduleNotification(c *fiber.Ctx) error {
@@ -301,11 +364,19 @@ func (h *NotificationHandler) ScheduleNotification(c *fiber.Ctx) error {
 	var req struct {
 		ScheduledAt   time.Time   `json:"scheduled_at"`
 		TargetUserIDs []uuid.UUID `json:"target_user_ids"`
+		SegmentID     *uuid.UUID  `json:"segment_id"` // members resolved at send time
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
 	}
-	if err := h.notifyService.ScheduleNotificationWithTargets(c.Context(), id, req.ScheduledAt, req.TargetUserIDs); err != nil {
+	if req.SegmentID != nil && len(req.TargetUserIDs) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "use either segment_id or target_user_ids"})
+	}
+	err = h.notifyService.ScheduleNotificationWithTargets(c.Context(), id, req.ScheduledAt, req.TargetUserIDs, req.SegmentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "notification or segment not found"})
+	}
+	if err != nil {
 		log.Printf("❌ ScheduleNotification failed: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -366,23 +437,18 @@ func (h *NotificationHandler) GetAll(c *fiber.Ctx) error {
 		sinceTime = &t
 	}
 	
-	limit := getQueryInt(c, "limit", 20, 1, 100)
-	offset := getQueryInt(c, "offset", 0, 0, 10000)
-	
-	// Use the new GetNotificationsSince method or modify GetAllNotifications to accept since
-	var notifications []*models.Notification
-	if sinceTime != nil {
-		notifications, err = h.notifyService.GetNotificationsSince(c.Context(), userID, sinceTime)
-	} else {
-		notifications, err = h.notifyService.GetAllNotifications(c.Context(), userID, limit, offset, sinceTime)
+	page, err := getPageRequest(c, 20, 100)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
 	}
-	
+
+	notifications, err := h.notifyService.GetNotificationsPage(c.Context(), userID, sinceTime, page)
 	if err != nil {
 		log.Printf("❌ GetAll: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch notifications"})
 	}
-	
-	return c.JSON(fiber.Map{"notifications": notifications})
+
+	return c.JSON(pageBody("notifications", notifications))
 }
 
 func (h *NotificationHandler) MarkRead(c *fiber.Ctx) error {
@@ -400,7 +466,7 @@ func (h *NotificationHandler) MarkRead(c *fiber.Ctx) error {
 	if len(req.NotificationIDs) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "notification_ids required"})
 	}
-	if err := h.notifyService.MarkNotificationsRead(c.Context(), userID, req.NotificationIDs); err != nil {
+	if err := h.notifyService.MarkNotificationsRead(c.Context(), userID, c.Get("X-Device-ID"), req.NotificationIDs); err != nil {
 		log.Printf("❌ MarkRead: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to mark notifications as read"})
 	}
@@ -413,13 +479,83 @@ func (h *NotificationHandler) MarkAllRead(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
 	}
-	if err := h.notifyService.MarkAllRead(c.Context(), userID); err != nil {
+	if err := h.notifyService.MarkAllRead(c.Context(), userID, c.Get("X-Device-ID")); err != nil {
 		log.Printf("❌ MarkAllRead: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to mark all as read"})
 	}
 	return c.JSON(fiber.Map{"status": "success", "message": "all notifications marked as read"})
 }
 
+// SetReadWatermark - POST /user/:user_id/read-watermark
+// Marks everything delivered up to a point as read. The point is one of
+// read_up_to (RFC3339), cursor (a feed cursor; its item and everything older)
+// or notification_id (that item and everything older).
+func (h *NotificationHandler) SetReadWatermark(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+	var req struct {
+		ReadUpTo       *time.Time `json:"read_up_to,omitempty"`
+		Cursor         string     `json:"cursor,omitempty"`
+		NotificationID *uuid.UUID `json:"notification_id,omitempty"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	given := 0
+	for _, set := range []bool{req.ReadUpTo != nil, req.Cursor != "", req.NotificationID != nil} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "exactly one of read_up_to, cursor or notification_id is required"})
+	}
+
+	var readUpTo time.Time
+	switch {
+	case req.ReadUpTo != nil:
+		readUpTo = *req.ReadUpTo
+	case req.Cursor != "":
+		cursor, err := service.DecodeCursor(req.Cursor)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
+		}
+		readUpTo = cursor.At
+	default:
+		readUpTo, err = h.notifyService.WatermarkAt(c.Context(), userID, *req.NotificationID)
+		if errors.Is(err, service.ErrWatermarkTarget) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "notification not found"})
+		}
+		if err != nil {
+			log.Printf("❌ SetReadWatermark: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to set read watermark"})
+		}
+	}
+
+	wm, err := h.notifyService.SetReadWatermark(c.Context(), userID, c.Get("X-Device-ID"), readUpTo)
+	if err != nil {
+		log.Printf("❌ SetReadWatermark: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to set read watermark"})
+	}
+	return c.JSON(fiber.Map{"status": "success", "watermark": wm})
+}
+
+// GetReadWatermark - GET /user/:user_id/read-watermark
+func (h *NotificationHandler) GetReadWatermark(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+	wm, err := h.notifyService.GetReadWatermark(c.Context(), userID)
+	if err != nil {
+		log.Printf("❌ GetReadWatermark: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get read watermark"})
+	}
+	return c.JSON(fiber.Map{"watermark": wm})
+}
+
 // Helper
 func getQueryInt(c *fiber.Ctx, key string, def, min, max int) int {
 	s := c.Query(key)
@@ -436,6 +572,37 @@ func getQueryInt(c *fiber.Ctx, key string, def, min, max int) int {
 	return v
 }
 
+// sinceMaxItems bounds paged /since responses.
+const sinceMaxItems = 500
+
+// getPageRequest reads ?limit= and ?cursor= (plus legacy ?offset=) for list endpoints.
+func getPageRequest(c *fiber.Ctx, def, max int) (service.PageRequest, error) {
+	page := service.PageRequest{
+		Limit:  getQueryInt(c, "limit", def, 1, max),
+		Offset: getQueryInt(c, "offset", 0, 0, 10000),
+	}
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := service.DecodeCursor(raw)
+		if err != nil {
+			return page, err
+		}
+		page.Cursor = cursor
+	}
+	return page, nil
+}
+
+// pageBody is a list response with the page's cursors (omitted when there is no such page).
+func pageBody[T any](key string, page service.Page[T]) fiber.Map {
+	body := fiber.Map{key: page.Items}
+	if page.NextCursor != "" {
+		body["next_cursor"] = page.NextCursor
+	}
+	if page.PrevCursor != "" {
+		body["prev_cursor"] = page.PrevCursor
+	}
+	return body
+}
+
 func (h *NotificationHandler) UpdateSystemTemplate(c *fiber.Ctx) error {
 	eventKey := c.Params("event_key")
 	var req struct {
@@ -445,6 +612,13 @@ func (h *NotificationHandler) UpdateSystemTemplate(c *fiber.Ctx) error {
 		Type    *string `json:"type,omitempty"`
 		Icon    *string `json:"icon,omitempty"`
 		Enabled *bool   `json:"enabled,omitempty"`
+
+		ContextSchema json.RawMessage     `json:"context_schema,omitempty"`
+		PushOptions   *models.PushOptions `json:"push_options,omitempty"`
+		RequiresAck   *bool               `json:"requires_ack,omitempty"`
+		AckPolicy     *models.AckPolicy   `json:"ack_policy,omitempty"`
+
+		EscalationPolicy *models.EscalationPolicy `json:"escalation_policy,omitempty"` // {"steps": []} turns escalation off
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid JSON"})
@@ -468,6 +642,36 @@ func (h *NotificationHandler) UpdateSystemTemplate(c *fiber.Ctx) error {
 	if req.Enabled != nil {
 		updateFields["enabled"] = *req.Enabled
 	}
+	if len(req.ContextSchema) > 0 {
+		if _, err := schema.Parse(req.ContextSchema); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		updateFields["context_schema"] = datatypes.JSON(req.ContextSchema)
+	}
+	if req.PushOptions != nil {
+		if err := req.PushOptions.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid push_options: " + err.Error()})
+		}
+		b, _ := json.Marshal(req.PushOptions)
+		updateFields["push_options"] = datatypes.JSON(b)
+	}
+	if req.RequiresAck != nil {
+		updateFields["requires_ack"] = *req.RequiresAck
+	}
+	if req.AckPolicy != nil {
+		if err := req.AckPolicy.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid ack_policy: " + err.Error()})
+		}
+		b, _ := json.Marshal(req.AckPolicy)
+		updateFields["ack_policy"] = datatypes.JSON(b)
+	}
+	if req.EscalationPolicy != nil {
+		if err := req.EscalationPolicy.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid escalation_policy: " + err.Error()})
+		}
+		b, _ := json.Marshal(req.EscalationPolicy)
+		updateFields["escalation_policy"] = datatypes.JSON(b)
+	}
 	if len(updateFields) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "no fields to update"})
 	}
@@ -501,10 +705,14 @@ func (h *NotificationHandler) TriggerSystemNotification(c *fiber.Ctx) error {
 		UserID    uuid.UUID              `json:"user_id" validate:"required"`
 		Variables map[string]interface{} `json:"variables" validate:"required"`
 		DedupKey  *string                `json:"dedup_key,omitempty"`
+		ExpiresAt *time.Time             `json:"expires_at,omitempty"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid JSON"})
 	}
+	if msg := expiryError(req.ExpiresAt, nil); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
 
 	db := h.notifyService.GetDB()
 
@@ -519,23 +727,17 @@ func (h *NotificationHandler) TriggerSystemNotification(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "template lookup failed"})
 	}
 
-	// Decode template.TemplateVars (JSONB) → []string
-	var requiredVars []string
-	if len(template.TemplateVars) > 0 {
-		if err := json.Unmarshal(template.TemplateVars, &requiredVars); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "invalid template_vars format",
-			})
-		}
+	// Validate variables against the template's context schema
+	violations, err := h.notifyService.ValidateTemplateVariables(&template, req.Variables)
+	if err != nil {
+		log.Printf("[TRIGGER] ❌ Bad schema on template %s: %v", req.EventKey, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "invalid template schema",
+		})
 	}
-
-	// Validate required variables
-	for _, v := range requiredVars {
-		if _, ok := req.Variables[v]; !ok {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": fmt.Sprintf("missing required variable: %s", v),
-			})
-		}
+	if len(violations) > 0 {
+		log.Printf("[TRIGGER] ⚠️ Rejected %s for %s: %d violation(s)", req.EventKey, req.UserID, len(violations))
+		return validationFailed(c, violations)
 	}
 
 	// Render
@@ -544,6 +746,11 @@ func (h *NotificationHandler) TriggerSystemNotification(c *fiber.Ctx) error {
 	renderedMessage := renderTemplateString(template.Message, req.Variables)
 
 	// Deduplication
+	if req.Variables == nil {
+		req.Variables = make(map[string]interface{})
+	}
+	req.Variables["event_key"] = req.EventKey
+
 	if req.DedupKey != nil {
 		var count int64
 		err := db.Model(&models.NotificationRecipient{}).
@@ -568,19 +775,66 @@ func (h *NotificationHandler) TriggerSystemNotification(c *fiber.Ctx) error {
 		req.Variables["dedup_key"] = *req.DedupKey
 	}
 
+	// Calendar invite (match events) — failure never blocks the in-app/push delivery
+	var invite *models.CalendarInvite
+	var actionLinks []models.ActionLink
+	if service.IsCalendarEvent(req.EventKey) {
+		inv, err := h.notifyService.PrepareCalendarInvite(c.Context(), req.EventKey, req.UserID, req.Variables)
+		if err != nil {
+			log.Printf("[TRIGGER] ⚠️ Calendar invite skipped for %s/%s: %v", req.EventKey, req.UserID, err)
+		} else {
+			invite = inv
+			actionLinks = append(actionLinks, h.notifyService.CalendarActionLink(invite))
+			if req.Variables == nil {
+				req.Variables = make(map[string]interface{})
+			}
+			req.Variables["calendar_uid"] = invite.UID
+		}
+	}
+
+	// Push delivery options — collapse keys are per-entity, e.g. "match-{{match_id}}"
+	pushOptions, err := models.ParsePushOptions(template.PushOptions)
+	if err != nil {
+		log.Printf("[TRIGGER] ⚠️ Ignoring bad push_options on template %s: %v", req.EventKey, err)
+		pushOptions = models.PushOptions{}
+	}
+	pushOptions.CollapseKey = models.TruncateCollapseKey(renderTemplateString(pushOptions.CollapseKey, req.Variables))
+
+	var ackPolicy *models.AckPolicy
+	if template.RequiresAck {
+		policy, err := models.ParseAckPolicy(template.AckPolicy)
+		if err != nil {
+			log.Printf("[TRIGGER] ⚠️ Ignoring bad ack_policy on template %s: %v", req.EventKey, err)
+		}
+		ackPolicy = &policy
+	}
+
+	// Escalation to email/SMS while the notification stays unread
+	escalationPolicy, err := models.ParseEscalationPolicy(template.EscalationPolicy)
+	if err != nil {
+		log.Printf("[TRIGGER] ⚠️ Ignoring bad escalation_policy on template %s: %v", req.EventKey, err)
+		escalationPolicy = models.EscalationPolicy{}
+	}
+
 	// Build request — note: NotificationRequest in models has no `SystemEventKey` or `RecipientUserID` (per current KB)
 	// So we use CreatorID = nil (or &uuid.Nil), and pass UserID separately to service.
 	notifReq := &models.NotificationRequest{
-		CreatorID:       &uuid.Nil,
-		Heading:         renderedHeading,
-		Title:           renderedTitle,
-		Message:         renderedMessage,
-		Type:            template.Type,
-		ContentImageURL: nil,
-		ThumbnailURL:    nil,
-		MediaURLs:       nil,
-		ContentLink:     nil,
-		Metadata:        req.Variables,
+		CreatorID:        &uuid.Nil,
+		Heading:          renderedHeading,
+		Title:            renderedTitle,
+		Message:          renderedMessage,
+		Type:             template.Type,
+		ContentImageURL:  nil,
+		ThumbnailURL:     nil,
+		MediaURLs:        nil,
+		ContentLink:      nil,
+		ActionLinks:      actionLinks,
+		Metadata:         req.Variables,
+		PushOptions:      &pushOptions,
+		RequiresAck:      template.RequiresAck,
+		AckPolicy:        ackPolicy,
+		EscalationPolicy: &escalationPolicy,
+		ExpiresAt:        req.ExpiresAt,
 		// ScheduledAt, etc. — left nil
 	}
 
@@ -591,6 +845,15 @@ func (h *NotificationHandler) TriggerSystemNotification(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "delivery failed"})
 	}
 
+	// Only a delivered invite is stored, so a retry reuses its SEQUENCE
+	if invite != nil {
+		if err := h.notifyService.SaveCalendarInvite(c.Context(), invite); err != nil {
+			log.Printf("[TRIGGER] ⚠️ Calendar invite not stored for %s/%s: %v", req.EventKey, req.UserID, err)
+		} else {
+			h.notifyService.SendCalendarInviteEmail(invite, req.Variables)
+		}
+	}
+
 	return c.JSON(fiber.Map{
 		"status":       "success",
 		"notification": notification,
@@ -635,13 +898,6 @@ func (h *NotificationHandler) GetSystemTemplates(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"templates": templates})
 }
 
-// StreamNotifications is removed as SSE is replaced by FCM
-func (h *NotificationHandler) StreamNotifications(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
-		"error": "SSE streaming is deprecated. Use FCM push notifications instead.",
-	})
-}
-
 func (h *NotificationHandler) RegisterFCMToken(c *fiber.Ctx) error {
 	userIDStr := c.Params("user_id") // Get from route parameter
 	if userIDStr == "" {
@@ -661,37 +917,26 @@ func (h *NotificationHandler) RegisterFCMToken(c *fiber.Ctx) error {
 	}
 
 	var req struct {
-		Token    string `json:"token" validate:"required"`
-		DeviceID string `json:"device_id" validate:"required"`
-		Platform string `json:"platform"` // e.g., "android", "ios", "web"
+		Token      string `json:"token" validate:"required"`
+		DeviceID   string `json:"device_id" validate:"required"`
+		Platform   string `json:"platform"`    // e.g., "android", "ios", "web"
+		AppVersion string `json:"app_version"` // e.g., "2.4.1"
+		Locale     string `json:"locale"`      // e.g., "fr-CA"; falls back to Accept-Language
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid JSON"})
 	}
-
-	if req.Platform == "" {
-		req.Platform = "unknown"
+	if req.Token == "" || req.DeviceID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "token and device_id are required"})
 	}
 
 	// Upsert: update if (user_id + device_id) exists
-	token := models.FCMToken{
-		UserID:   userID,
-		DeviceID: req.DeviceID,
-		Token:    req.Token,
-		Platform: req.Platform,
-	}
-
-	result := h.notifyService.GetDB().Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "user_id"}, {Name: "device_id"}},
-		DoUpdates: clause.Assignments(map[string]interface{}{
-			"token":      req.Token,
-			"platform":   req.Platform,
-			"updated_at": time.Now(),
-		}),
-	}).Create(&token)
-
-	if result.Error != nil {
-		log.Printf("❌ Failed to register FCM token: %v", result.Error)
+	if req.Locale == "" {
+		req.Locale = primaryLanguage(c.Get("Accept-Language"))
+	}
+	token, err := h.notifyService.RegisterFCMToken(c.Context(), userID, req.DeviceID, req.Token, req.Platform, req.AppVersion, req.Locale)
+	if err != nil {
+		log.Printf("❌ Failed to register FCM token: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "registration failed"})
 	}
 
@@ -715,15 +960,28 @@ func (h *NotificationHandler) UnregisterFCMToken(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "device_id required"})
 	}
 
-	err = h.notifyService.GetDB().Where("user_id = ? AND device_id = ?", userID, req.DeviceID).
-		Update("deleted_at", time.Now()).Error
-
-	if err != nil {
+	if err := h.notifyService.UnregisterFCMToken(c.Context(), userID, req.DeviceID); err != nil {
+		log.Printf("❌ UnregisterFCMToken failed: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "unregister failed"})
 	}
 	return c.JSON(fiber.Map{"status": "success"})
 }
 
+// GetUserDevices — admin: a user's registered push devices
+func (h *NotificationHandler) GetUserDevices(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+	includeRevoked := c.QueryBool("include_revoked", false)
+	devices, err := h.notifyService.ListUserDevices(c.Context(), userID, includeRevoked)
+	if err != nil {
+		log.Printf("❌ GetUserDevices: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch devices"})
+	}
+	return c.JSON(fiber.Map{"devices": devices})
+}
+
 func (h *NotificationHandler) GetAllSince(c *fiber.Ctx) error {
 	userID := c.Params("user_id")
 	since := c.Query("since")
@@ -752,16 +1010,35 @@ func (h *NotificationHandler) GetAllSince(c *fiber.Ctx) error {
 		sinceTime = &t
 	}
 	
-	// Get notifications from service - use h.notifyService instead of h.service
-	notifications, err := h.notifyService.GetNotificationsSince(c.Context(), uid, sinceTime)
+	// Old clients get everything; paging is opt-in via ?limit= or ?cursor=
+	if c.Query("limit") == "" && c.Query("cursor") == "" {
+		notifications, err := h.notifyService.GetNotificationsSince(c.Context(), uid, sinceTime)
+		if err != nil {
+			log.Printf("❌ Failed to get notifications since %v: %v", sinceTime, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to get notifications",
+			})
+		}
+		return c.JSON(notifications)
+	}
+
+	// Bare array either way; further pages via the X-Next-Cursor header
+	page, err := getPageRequest(c, sinceMaxItems, sinceMaxItems)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
+	}
+	notifications, err := h.notifyService.GetNotificationsPage(c.Context(), uid, sinceTime, page)
 	if err != nil {
 		log.Printf("❌ Failed to get notifications since %v: %v", sinceTime, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to get notifications",
 		})
 	}
+	if notifications.NextCursor != "" {
+		c.Set("X-Next-Cursor", notifications.NextCursor)
+	}
 	
-	return c.JSON(notifications)
+	return c.JSON(notifications.Items)
 }
 
 // DeleteNotification - User deletes their notification
@@ -779,11 +1056,10 @@ func (h *NotificationHandler) DeleteNotificationForUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid notification_id"})
 	}
 	
-	// Soft delete the notification recipient record
-	err = h.notifyService.GetDB().Where("user_id = ? AND notification_id = ?", userID, notificationID).
-		Delete(&models.NotificationRecipient{}).Error
-	
-	if err != nil {
+	if _, err := h.notifyService.DeleteForUser(c.Context(), userID, c.Get("X-Device-ID"), []uuid.UUID{notificationID}); err != nil {
+		if errors.Is(err, service.ErrAckPending) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
 		log.Printf("❌ DeleteNotificationForUser failed: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to delete notification"})
 	}
@@ -811,19 +1087,12 @@ func (h *NotificationHandler) ClearAllNotifications(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
 	}
 	
-	// If specific IDs provided, delete only those
-	if len(req.NotificationIDs) > 0 {
-		err = h.notifyService.GetDB().
-			Where("user_id = ? AND notification_id IN ?", userID, req.NotificationIDs).
-			Delete(&models.NotificationRecipient{}).Error
-	} else {
-		// Delete all notifications for user
-		err = h.notifyService.GetDB().
-			Where("user_id = ?", userID).
-			Delete(&models.NotificationRecipient{}).Error
-	}
-	
+	// If specific IDs provided, delete only those; otherwise everything
+	count, err := h.notifyService.DeleteForUser(c.Context(), userID, c.Get("X-Device-ID"), req.NotificationIDs)
 	if err != nil {
+		if errors.Is(err, service.ErrAckPending) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
 		log.Printf("❌ ClearAllNotifications failed: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to clear notifications"})
 	}
@@ -831,7 +1100,7 @@ func (h *NotificationHandler) ClearAllNotifications(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "notifications cleared",
-		"count":   len(req.NotificationIDs),
+		"count":   count,
 	})
 }
 
@@ -843,17 +1112,8 @@ func (h *NotificationHandler) HasUnreadNotifications(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
 	}
 
-	var hasUnread bool
-	// Simple EXISTS query - very efficient
-	err = h.notifyService.GetDB().Raw(`
-		SELECT EXISTS(
-			SELECT 1 
-			FROM notification_recipients 
-			WHERE user_id = ? 
-			AND status = 'delivered'
-			LIMIT 1
-		)`, userID).Scan(&hasUnread).Error
-	
+	hasUnread, err := h.notifyService.HasUnread(c.Context(), userID)
+
 	if err != nil {
 		log.Printf("❌ HasUnreadNotifications failed: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to check"})
@@ -864,4 +1124,165 @@ func (h *NotificationHandler) HasUnreadNotifications(c *fiber.Ctx) error {
 		"has_unread": hasUnread,
 		"ts":         time.Now().UTC().Unix(),
 	})
-}
\ No newline at end of file
+}
+
+// GetUnreadCounts - badge and tab counts. Supports If-None-Match: the ETag is
+// derived from the counts themselves, so unchanged counts answer 304.
+func (h *NotificationHandler) GetUnreadCounts(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+
+	counts, err := h.notifyService.UnreadCounts(c.Context(), userID)
+	if err != nil {
+		log.Printf("❌ GetUnreadCounts: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to count notifications"})
+	}
+	etag := `"` + counts.Version() + `"`
+	c.Set("ETag", etag)
+	c.Set("Cache-Control", "private, no-cache")
+	if etagMatches(c.Get("If-None-Match"), etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.JSON(counts)
+}
+
+// etagMatches evaluates an If-None-Match header against etag (RFC 9110
+// §13.1.2): "*" matches anything, otherwise any entity-tag in the list
+// matches if its opaque part is equal (weak comparison, W/ is ignored).
+func etagMatches(header, etag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for header != "" {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			break
+		}
+		tag := strings.TrimPrefix(header, "W/")
+		if !strings.HasPrefix(tag, `"`) {
+			return false // malformed; ignore the header
+		}
+		end := strings.IndexByte(tag[1:], '"')
+		if end < 0 {
+			return false
+		}
+		if tag[:end+2] == want {
+			return true
+		}
+		header = tag[end+2:]
+	}
+	return false
+}
+
+// DownloadCalendarInvite serves a user's match invite as an .ics file (in-app action link)
+func (h *NotificationHandler) DownloadCalendarInvite(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+	inviteID, err := uuid.Parse(strings.TrimSuffix(c.Params("invite_id"), ".ics"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid invite_id"})
+	}
+	invite, err := h.notifyService.GetCalendarInvite(c.Context(), userID, inviteID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "invite not found"})
+		}
+		log.Printf("❌ DownloadCalendarInvite: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch invite"})
+	}
+	c.Set(fiber.HeaderContentType, calendar.ContentType(calendar.Method(invite.Method)))
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="invite.ics"`)
+	return c.SendString(invite.Content)
+}
+
+// primaryLanguage returns the first tag of an Accept-Language header ("fr-CA,fr;q=0.9" → "fr-CA").
+func primaryLanguage(header string) string {
+	tag := strings.TrimSpace(strings.Split(header, ",")[0])
+	tag = strings.TrimSpace(strings.Split(tag, ";")[0])
+	if tag == "*" || len(tag) > 20 {
+		return ""
+	}
+	return tag
+}
+
+// UpdatePresence — client heartbeat: {"device_id": "...", "state": "active|background"}
+func (h *NotificationHandler) UpdatePresence(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+	var req struct {
+		DeviceID string `json:"device_id"`
+		State    string `json:"state"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid JSON"})
+	}
+	if req.DeviceID == "" {
+		req.DeviceID = c.Get("X-Device-ID")
+	}
+	if req.State == "" {
+		req.State = models.PresenceActive
+	}
+	if req.DeviceID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "device_id is required"})
+	}
+	if req.State != models.PresenceActive && req.State != models.PresenceBackground {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "state must be active or background"})
+	}
+	if err := h.notifyService.RecordPresence(c.Context(), userID, req.DeviceID, req.State); err != nil {
+		log.Printf("❌ UpdatePresence: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to record presence"})
+	}
+	return c.JSON(fiber.Map{
+		"status":         "ok",
+		"window_seconds": h.notifyService.PresenceWindowSeconds(),
+	})
+}
+
+// PreferencesChanged — POST /svc/v1/users/:user_id/preferences-changed
+// Called by the profile service after a user's notification preferences
+// change, so their devices sync: {"device_id": "..."} (optional, the device
+// that made the change).
+func (h *NotificationHandler) PreferencesChanged(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+	var req struct {
+		DeviceID string `json:"device_id"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid JSON"})
+		}
+	}
+	if err := h.notifyService.PreferencesChanged(c.Context(), userID, req.DeviceID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to record preference change"})
+	}
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// expiryError checks an optional expires_at: it must be in the future and,
+// for scheduled notifications, after the scheduled time.
+func expiryError(expiresAt, scheduledAt *time.Time) string {
+	if expiresAt == nil {
+		return ""
+	}
+	if !expiresAt.After(time.Now()) {
+		return "expires_at must be in the future"
+	}
+	if scheduledAt != nil && !expiresAt.After(*scheduledAt) {
+		return "expires_at must be after scheduled_at"
+	}
+	return ""
+}
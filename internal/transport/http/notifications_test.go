@@ -0,0 +1,28 @@
+package http
+
+import "testing"
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"abc123"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{``, false},
+		{`*`, true},
+		{`"abc123"`, true},
+		{`W/"abc123"`, true},
+		{`"other"`, false},
+		{`"other", "abc123"`, true},
+		{`"other",W/"abc123"`, true},
+		{`"a,b", "abc123"`, true},
+		{`"abc"`, false},
+		{`abc123`, false},
+		{`"unterminated`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
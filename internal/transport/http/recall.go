@@ -0,0 +1,86 @@
+package http
+
+import (
+	"errors"
+	"log"
+
+	"notify-service/internal/service"
+	"notify-service/pkg/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RecallNotification - POST /admin/notifications/:id/recall
+// Body: {"reason": "...", "correction": {heading, title, message, ...}}
+// Withdraws a published notification from every recipient, keeping it for
+// audit, and optionally publishes a correction to the same recipients.
+func (h *NotificationHandler) RecallNotification(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid notification id"})
+	}
+	var req struct {
+		Reason     string                      `json:"reason"`
+		Correction *models.NotificationRequest `json:"correction"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+		}
+	}
+	opts := service.RecallOptions{Reason: req.Reason, Correction: req.Correction}
+	if actor := c.Get("X-User-ID"); actor != "" {
+		actorID, err := uuid.Parse(actor)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid X-User-ID"})
+		}
+		opts.ActorID = &actorID
+	}
+	if corr := req.Correction; corr != nil {
+		if corr.CreatorID == nil {
+			if opts.ActorID == nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "X-User-ID required to publish a correction",
+				})
+			}
+			corr.CreatorID = opts.ActorID
+		}
+		if corr.Heading == "" || corr.Title == "" || corr.Message == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "correction heading, title, and message are required"})
+		}
+		if corr.Category != "" && !models.NotificationCategory(corr.Category).Valid() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid correction category"})
+		}
+		if msg := expiryError(corr.ExpiresAt, corr.ScheduledAt); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+		}
+	}
+
+	result, err := h.notifyService.RecallNotification(c.Context(), id, opts)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "notification not found"})
+	case errors.Is(err, service.ErrNotPublished), errors.Is(err, service.ErrAlreadyRecalled):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil && result != nil:
+		// The recall went through; only the correction failed
+		log.Printf("❌ RecallNotification: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":        err.Error(),
+			"notification": result.Notification,
+			"retracted":    result.Retracted,
+			"correction":   result.Correction,
+		})
+	case err != nil:
+		log.Printf("❌ RecallNotification: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to recall notification"})
+	}
+	return c.JSON(fiber.Map{
+		"status":       "success",
+		"notification": result.Notification,
+		"retracted":    result.Retracted,
+		"correction":   result.Correction,
+	})
+}
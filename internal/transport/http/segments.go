@@ -0,0 +1,185 @@
+package http
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"notify-service/internal/service"
+	"notify-service/pkg/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ListSegments - GET /admin/segments
+func (h *NotificationHandler) ListSegments(c *fiber.Ctx) error {
+	segments, err := h.notifyService.ListSegments(c.Context())
+	if err != nil {
+		log.Printf("❌ ListSegments: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to fetch segments"})
+	}
+	return c.JSON(fiber.Map{"segments": segments})
+}
+
+// GetSegment - GET /admin/segments/:id
+func (h *NotificationHandler) GetSegment(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid segment id"})
+	}
+	segment, err := h.notifyService.GetSegment(c.Context(), id)
+	if err != nil {
+		return segmentError(c, "GetSegment", err)
+	}
+	return c.JSON(fiber.Map{"segment": segment})
+}
+
+// CreateSegment - POST /admin/segments
+// Body: {"name": "...", "description": "...", "definition": {"match": "all", "rules": [...]}}
+func (h *NotificationHandler) CreateSegment(c *fiber.Ctx) error {
+	req, errMsg := parseSegmentRequest(c)
+	if errMsg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errMsg})
+	}
+	creatorID, err := uuid.Parse(c.Get("X-User-ID"))
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "X-User-ID required"})
+	}
+	segment, err := h.notifyService.CreateSegment(c.Context(), creatorID, req)
+	if err != nil {
+		return segmentError(c, "CreateSegment", err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "segment": segment})
+}
+
+// UpdateSegment - PUT /admin/segments/:id
+func (h *NotificationHandler) UpdateSegment(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid segment id"})
+	}
+	req, errMsg := parseSegmentRequest(c)
+	if errMsg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errMsg})
+	}
+	segment, err := h.notifyService.UpdateSegment(c.Context(), id, req)
+	if err != nil {
+		return segmentError(c, "UpdateSegment", err)
+	}
+	return c.JSON(fiber.Map{"status": "success", "segment": segment})
+}
+
+// DeleteSegment - DELETE /admin/segments/:id
+// Notifications already sent to the segment keep their recipients.
+func (h *NotificationHandler) DeleteSegment(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid segment id"})
+	}
+	if err := h.notifyService.DeleteSegment(c.Context(), id); err != nil {
+		return segmentError(c, "DeleteSegment", err)
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "segment deleted"})
+}
+
+// PreviewSegment - GET /admin/segments/:id/preview
+// Current size of a saved segment with a sample of members.
+func (h *NotificationHandler) PreviewSegment(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid segment id"})
+	}
+	preview, err := h.notifyService.PreviewSavedSegment(c.Context(), id)
+	if err != nil {
+		return segmentError(c, "PreviewSegment", err)
+	}
+	return c.JSON(preview)
+}
+
+// PreviewSegmentDefinition - POST /admin/segments/preview
+// Body: {"match": "all", "rules": [...]}; previews rules before saving them.
+func (h *NotificationHandler) PreviewSegmentDefinition(c *fiber.Ctx) error {
+	var def models.SegmentDefinition
+	if err := c.BodyParser(&def); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	if err := def.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid definition: " + err.Error()})
+	}
+	preview, err := h.notifyService.PreviewSegment(c.Context(), def)
+	if err != nil {
+		log.Printf("❌ PreviewSegmentDefinition: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to preview segment"})
+	}
+	return c.JSON(preview)
+}
+
+// publishToSegment publishes a draft to a saved segment's current members.
+func (h *NotificationHandler) publishToSegment(c *fiber.Ctx, notifID, segmentID uuid.UUID) error {
+	count, err := h.notifyService.PublishToSegment(c.Context(), notifID, segmentID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "segment not found"})
+	case errors.Is(err, service.ErrNotDraft):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrEmptySegment), errors.Is(err, service.ErrNotificationExpired):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		log.Printf("❌ PublishToSegment failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{
+		"status":        "success",
+		"message":       "notification published to segment",
+		"audience_size": count,
+	})
+}
+
+func parseSegmentRequest(c *fiber.Ctx) (*models.SegmentRequest, string) {
+	var req models.SegmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, "invalid request body"
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return nil, "name is required (max 100 characters)"
+	}
+	if err := req.Definition.Validate(); err != nil {
+		return nil, "invalid definition: " + err.Error()
+	}
+	return &req, ""
+}
+
+func segmentError(c *fiber.Ctx, op string, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "segment not found"})
+	case errors.Is(err, gorm.ErrDuplicatedKey), strings.Contains(err.Error(), "idx_segments_name"):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "a segment with this name already exists"})
+	}
+	log.Printf("❌ %s: %v", op, err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "segment operation failed"})
+}
+
+// estimatePublish answers a dry-run publish with the expected reach.
+func (h *NotificationHandler) estimatePublish(c *fiber.Ctx, notifID uuid.UUID, target service.PublishTarget) error {
+	estimate, err := h.notifyService.EstimatePublish(c.Context(), notifID, target)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "segment not found"})
+	case errors.Is(err, service.ErrNotDraft):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrNotificationExpired):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		log.Printf("❌ EstimatePublish failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{
+		"status":   "success",
+		"dry_run":  true,
+		"estimate": estimate,
+	})
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"notify-service/internal/middleware"
+	"notify-service/internal/realtime"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+)
+
+// streamReplayLimit is the most missed events replayed on resume; a client
+// further behind gets a "reset" event and should refetch its inbox.
+const streamReplayLimit = 500
+
+// StreamNotifications serves the user's inbox events over SSE. Auth comes
+// from SSEAuthMiddleware (EventSource can't set headers). Events carry their
+// log ID, so a reconnecting client resumes via Last-Event-ID.
+func (h *NotificationHandler) StreamNotifications(c *fiber.Ctx) error {
+	userIDStr, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	deviceID, _ := middleware.GetDeviceIDFromContext(c)
+
+	lastEventID := c.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var lastID int64
+	if lastEventID != "" {
+		if lastID, err = strconv.ParseInt(lastEventID, 10, 64); err != nil || lastID < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Last-Event-ID"})
+		}
+	}
+
+	hub := h.notifyService.Hub()
+	sub, err := hub.Subscribe(userID, deviceID)
+	if errors.Is(err, realtime.ErrTooManyConnections) || errors.Is(err, realtime.ErrServerFull) {
+		c.Set("Retry-After", "30")
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open stream"})
+	}
+
+	// Replay before going live; live events already replayed are skipped below.
+	// Events can commit out of ID order: one with a lower ID than the client's
+	// last may be missed while it was away, which delta sync picks up.
+	replay, reset, err := h.streamBacklog(c.Context(), userID, lastID)
+	if err != nil {
+		hub.Unsubscribe(sub)
+		log.Printf("❌ [STREAM] Replay failed for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open stream"})
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	heartbeat := h.notifyService.StreamHeartbeat()
+	log.Printf("📡 [STREAM] Opened for user %s (device %s, resume after %d)", userID, deviceID, lastID)
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer func() {
+			hub.Unsubscribe(sub)
+			log.Printf("📴 [STREAM] Closed for user %s (device %s)", userID, deviceID)
+		}()
+
+		fmt.Fprintf(w, "retry: 3000\n\n")
+		if reset {
+			writeSSE(w, lastID, "reset", []byte(`{}`))
+		}
+		replayed := make(map[int64]bool, len(replay))
+		for _, ev := range replay {
+			writeSSE(w, ev.ID, ev.Type, ev.Data)
+			replayed[ev.ID] = true
+		}
+		if w.Flush() != nil {
+			return
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sub.Done:
+				return
+			case ev := <-sub.Events:
+				// Live events arrive once each, but may repeat the replay
+				if replayed[ev.ID] {
+					continue
+				}
+				writeSSE(w, ev.ID, ev.Type, ev.Data)
+			case <-ticker.C:
+				fmt.Fprintf(w, ": ping\n\n")
+			}
+			if w.Flush() != nil {
+				return
+			}
+		}
+	}))
+	return nil
+}
+
+// streamBacklog returns the events after lastID, or reset=true when the gap
+// is too old or too long to replay.
+func (h *NotificationHandler) streamBacklog(ctx context.Context, userID uuid.UUID, lastID int64) ([]realtime.Event, bool, error) {
+	if lastID == 0 {
+		return nil, false, nil
+	}
+	oldest, err := h.notifyService.OldestInboxEventID(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if oldest == 0 || lastID < oldest-1 {
+		return nil, true, nil
+	}
+	events, err := h.notifyService.InboxEventsAfter(ctx, &userID, lastID, streamReplayLimit)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(events) == streamReplayLimit {
+		return nil, true, nil
+	}
+	return events, false, nil
+}
+
+func writeSSE(w *bufio.Writer, id int64, event string, data []byte) {
+	if len(data) == 0 {
+		data = []byte(`{}`)
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+}
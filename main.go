@@ -13,8 +13,10 @@ import (
 	"notify-service/internal/config"
 	"notify-service/internal/email"
 	"notify-service/internal/fcm"
+	"notify-service/internal/middleware"
 	"notify-service/internal/notification"
 	"notify-service/internal/service"
+	"notify-service/internal/sms"
 	"notify-service/internal/sync"
 	"notify-service/internal/transport/http"
 	"notify-service/utils"
@@ -51,32 +53,22 @@ func main() {
 
 	emailSender := email.NewSender(cfg)
 
-	// Initialize FCM client
-	var fcmClient *fcm.FCMClient
-	fcmCredsJSON := os.Getenv("FIREBASE_CREDENTIALS_JSON")
-	if fcmCredsJSON != "" {
-		client, err := fcm.NewFCMClient(context.Background(), []byte(fcmCredsJSON))
-		if err != nil {
-			log.Fatalf("❌ Failed to initialize FCM: %v", err)
-		}
-		fcmClient = client
-		log.Println("✅ FCM client initialized")
-	} else {
-		log.Println("⚠️ FCM disabled (no FIREBASE_CREDENTIALS_JSON)")
-	}
+	// Initialize push sender
+	pushSender, pushRecorder := newPushSender(cfg)
+	smsSender, smsRecorder := newSMSSender(cfg)
 
-	notifyService := service.NewNotifyService(emailSender, r2Client, userSyncService, fcmClient)
+	notifyService := service.NewNotifyService(cfg, emailSender, r2Client, userSyncService, pushSender, smsSender)
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	notifyService.StartWorkers(workersCtx)
 	handler := http.NewHandler(notifyService)
 	log.Println("✅ [SERVICE] NotifyService & Handler initialized")
 
-	// NOTE: AuthServiceURL and MS_SERVICE_TOKEN are still loaded from config/env
-	// but the authClient for SSE is no longer initialized or used.
-	authServiceURL := os.Getenv("AUTH_SERVICE_URL")
-	msServiceToken := os.Getenv("MS_SERVICE_TOKEN")
-	if authServiceURL == "" || msServiceToken == "" {
-		log.Println("⚠️ AUTH_SERVICE_URL and MS_SERVICE_TOKEN are missing. SSE auth was previously required, but SSE is now removed.")
-		// No longer fatal if SSE is removed
+	// SSE clients authenticate with ?token=&device_id=, validated by the auth service
+	if cfg.AuthServiceToken == "" {
+		log.Println("⚠️ MS_SERVICE_TOKEN is missing. SSE stream auth against the auth service will fail.")
 	}
+	authClient := service.NewAuthServiceClient(cfg.AuthServiceURL, cfg.AuthServiceToken)
 
 	app := fiber.New(fiber.Config{
 		AppName:      "notify-service",
@@ -107,18 +99,25 @@ func main() {
 	gatewayUserRoutes.Get("/user/:user_id", notifHandler.GetAll)
 	gatewayUserRoutes.Get("/user/:user_id/since", notifHandler.GetAllSince)
 	gatewayUserRoutes.Get("/user/:user_id/unread", notifHandler.GetUnread)
-	gatewayUserRoutes.Post("/user/:user_id/mark-read", notifHandler.MarkRead)
-	gatewayUserRoutes.Post("/user/:user_id/mark-all-read", notifHandler.MarkAllRead)
-	gatewayUserRoutes.Get("/user/:user_id/has-unread", notifHandler.HasUnreadNotifications)
-	gatewayUserRoutes.Delete("/user/:user_id/notifications/:notification_id", notifHandler.DeleteNotificationForUser)
-	gatewayUserRoutes.Post("/user/:user_id/clear-all", notifHandler.ClearAllNotifications)
-	gatewayUserRoutes.Post("/user/:user_id/fcm-token", notifHandler.RegisterFCMToken)     // Add FCM token registration
-	gatewayUserRoutes.Delete("/user/:user_id/fcm-token", notifHandler.UnregisterFCMToken) // Add FCM token unregistration
-	log.Println("✅ [ROUTES] Registered user routes: /v1/notify/s/user/:user_id*")
+	registerUserActions(gatewayUserRoutes, notifHandler)
+
+	// v3: feed entries are inbox items with per-recipient state
+	inboxRoutes := app.Group("/v3", gatewayAuth())
+	inboxRoutes.Get("/user/:user_id", notifHandler.GetInbox)
+	inboxRoutes.Get("/user/:user_id/since", notifHandler.GetInboxSince)
+	inboxRoutes.Get("/user/:user_id/unread", notifHandler.GetUnreadInbox)
+	inboxRoutes.Get("/user/:user_id/sync", notifHandler.SyncInbox)
+	registerUserActions(inboxRoutes, notifHandler)
+	log.Println("✅ [ROUTES] Registered user routes: /v2/user/:user_id*, /v3/user/:user_id*")
+
+	// 1b. Real-time stream (EventSource can't send gateway headers; token in query)
+	app.Get("/stream", middleware.SSEAuthMiddleware(authClient), notifHandler.StreamNotifications)
+	log.Println("✅ [ROUTES] Registered SSE stream: /stream")
 
 	// 2. Admin routes (via Gateway + admin role)
 	gatewayAdminRoutes := app.Group("/admin", gatewayAuth(), adminRoleAuth())
 	gatewayAdminRoutes.Get("/users", notifHandler.GetAllUsers)
+	gatewayAdminRoutes.Get("/users/:user_id/devices", notifHandler.GetUserDevices)
 	gatewayAdminRoutes.Get("/notifications", notifHandler.GetAllNotificationsAdmin)
 	gatewayAdminRoutes.Post("/notifications", notifHandler.CreateNotification)
 	gatewayAdminRoutes.Post("/upload", notifHandler.UploadNotificationFiles)
@@ -130,8 +129,43 @@ func main() {
 	gatewayAdminRoutes.Get("/notifications/history", notifHandler.GetNotificationHistory)
 	gatewayAdminRoutes.Post("/notifications/bulk", notifHandler.BulkDeliverNotification)
 	gatewayAdminRoutes.Get("/notifications/:id/receipts", notifHandler.GetNotificationReceipts)
+	gatewayAdminRoutes.Get("/notifications/:id/interactions", notifHandler.GetInteractionSummary)
+	gatewayAdminRoutes.Get("/notifications/:id/acks", notifHandler.GetNotificationAcks)
+	gatewayAdminRoutes.Get("/notifications/:id/escalations", notifHandler.GetNotificationEscalations)
+	gatewayAdminRoutes.Post("/notifications/:id/recall", notifHandler.RecallNotification)
+	gatewayAdminRoutes.Get("/acks", notifHandler.ListOutstandingAcks)
+	gatewayAdminRoutes.Get("/segments", notifHandler.ListSegments)
+	gatewayAdminRoutes.Post("/segments", notifHandler.CreateSegment)
+	gatewayAdminRoutes.Post("/segments/preview", notifHandler.PreviewSegmentDefinition)
+	gatewayAdminRoutes.Get("/segments/:id", notifHandler.GetSegment)
+	gatewayAdminRoutes.Put("/segments/:id", notifHandler.UpdateSegment)
+	gatewayAdminRoutes.Delete("/segments/:id", notifHandler.DeleteSegment)
+	gatewayAdminRoutes.Get("/segments/:id/preview", notifHandler.PreviewSegment)
 	gatewayAdminRoutes.Get("/system-templates/", notifHandler.GetSystemTemplates)
 	gatewayAdminRoutes.Patch("/system-templates/:event_key", notifHandler.UpdateSystemTemplate)
+	if pushRecorder != nil {
+		// PUSH_DRIVER=memory: inspect/reset what would have been pushed
+		gatewayAdminRoutes.Get("/push/outbox", func(c *fiber.Ctx) error {
+			if token := c.Query("token"); token != "" {
+				return c.JSON(fiber.Map{"pushes": pushRecorder.SentTo(token)})
+			}
+			return c.JSON(fiber.Map{"pushes": pushRecorder.Sent()})
+		})
+		gatewayAdminRoutes.Delete("/push/outbox", func(c *fiber.Ctx) error {
+			pushRecorder.Reset()
+			return c.SendStatus(fiber.StatusNoContent)
+		})
+	}
+	if smsRecorder != nil {
+		// SMS_DRIVER=memory: inspect/reset what would have been texted
+		gatewayAdminRoutes.Get("/sms/outbox", func(c *fiber.Ctx) error {
+			return c.JSON(fiber.Map{"messages": smsRecorder.Sent()})
+		})
+		gatewayAdminRoutes.Delete("/sms/outbox", func(c *fiber.Ctx) error {
+			smsRecorder.Reset()
+			return c.SendStatus(fiber.StatusNoContent)
+		})
+	}
 
 	log.Println("✅ [ROUTES] Registered admin routes: /admin/*")
 
@@ -140,7 +174,8 @@ func main() {
 	serviceRoutes.Post("/notify/email", handler.SendEmail)
 	serviceRoutes.Post("/notifications/trigger", notifHandler.TriggerSystemNotification)
 	serviceRoutes.Post("/notifications", notifHandler.CreateNotification)
-	log.Println("✅ [ROUTES] Registered service routes: /svc/v1/notify/email, /notifications")
+	serviceRoutes.Post("/users/:user_id/preferences-changed", notifHandler.PreferencesChanged)
+	log.Println("✅ [ROUTES] Registered service routes: /svc/v1/notify/email, /notifications, /users/:user_id/preferences-changed")
 
 	// 4. Sync routes
 	syncRoutes := app.Group("/svc/v1/sync", serviceAuth(cfg))
@@ -175,13 +210,18 @@ func main() {
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
 		uptime := time.Since(startTime).Round(time.Second)
+		streamUsers, streamConns := notifyService.Hub().Stats()
+		streams := fiber.Map{"users": streamUsers, "connections": streamConns}
 		return c.JSON(fiber.Map{
 			"status":      "ok",
 			"service":     "notify-service",
 			"uptime":      uptime.String(),
 			"timestamp":   time.Now().UTC().Format(time.RFC3339),
 			"profile_url": cfg.ProfileServiceURL,
-			"fcm_enabled": fcmClient != nil, // Show FCM status instead of SSE
+			"fcm_enabled": pushSender != nil,
+			"push_driver": pushDriver(cfg),
+			"sms_driver":  smsDriver(cfg),
+			"streams":     streams,
 		})
 	})
 	log.Println("✅ [ROUTES] Registered /health")
@@ -192,6 +232,7 @@ func main() {
 	go func() {
 		<-c
 		log.Println("🛑 [SHUTDOWN] Graceful shutdown initiated...")
+		stopWorkers()
 		if err := app.Shutdown(); err != nil {
 			log.Printf("❌ [SHUTDOWN] Error: %v", err)
 		}
@@ -211,6 +252,28 @@ func main() {
 	}
 }
 
+// registerUserActions mounts the user routes shared by every API version.
+func registerUserActions(r fiber.Router, notifHandler *http.NotificationHandler) {
+	r.Post("/user/:user_id/mark-read", notifHandler.MarkRead)
+	r.Post("/user/:user_id/mark-all-read", notifHandler.MarkAllRead)
+	r.Get("/user/:user_id/read-watermark", notifHandler.GetReadWatermark)
+	r.Post("/user/:user_id/read-watermark", notifHandler.SetReadWatermark)
+	r.Get("/user/:user_id/has-unread", notifHandler.HasUnreadNotifications)
+	r.Get("/user/:user_id/unread-counts", notifHandler.GetUnreadCounts)
+	r.Delete("/user/:user_id/notifications/:notification_id", notifHandler.DeleteNotificationForUser)
+	r.Post("/user/:user_id/notifications/:notification_id/ack", notifHandler.AcknowledgeNotification)
+	r.Post("/user/:user_id/clear-all", notifHandler.ClearAllNotifications)
+	r.Post("/user/:user_id/fcm-token", notifHandler.RegisterFCMToken)     // Add FCM token registration
+	r.Delete("/user/:user_id/fcm-token", notifHandler.UnregisterFCMToken) // Add FCM token unregistration
+	r.Get("/user/:user_id/calendar/:invite_id", notifHandler.DownloadCalendarInvite)
+	r.Post("/user/:user_id/presence", notifHandler.UpdatePresence)
+	r.Post("/user/:user_id/interactions", notifHandler.RecordInteractions)
+	for _, action := range service.InboxActions {
+		r.Post("/user/:user_id/notifications/:notification_id/"+string(action), notifHandler.ApplyInboxAction(action))
+		r.Post("/user/:user_id/"+string(action), notifHandler.ApplyInboxAction(action))
+	}
+}
+
 func customErrorHandler(c *fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
 	var errMsg string
@@ -309,6 +372,92 @@ func adminRoleAuth() fiber.Handler {
 	}
 }
 
+// pushDriver resolves PUSH_DRIVER, defaulting to FCM when it can connect.
+func pushDriver(cfg *config.Config) string {
+	if cfg.PushDriver != "" {
+		return strings.ToLower(cfg.PushDriver)
+	}
+	if os.Getenv("FIREBASE_CREDENTIALS_JSON") != "" || cfg.FCMEndpoint != "" {
+		return "fcm"
+	}
+	return "none"
+}
+
+// newPushSender builds the configured push sender. The recorder is returned
+// as well for the memory driver so its outbox can be inspected.
+func newPushSender(cfg *config.Config) (fcm.PushSender, *fcm.Recorder) {
+	brands, err := fcm.ParseBrands(cfg.PushDefaultBrand, cfg.PushBrandsJSON)
+	if err != nil {
+		log.Fatalf("❌ Invalid push brand configuration: %v", err)
+	}
+
+	switch driver := pushDriver(cfg); driver {
+	case "fcm":
+		if cfg.FCMEndpoint != "" {
+			client, err := fcm.NewFCMClientForEndpoint(context.Background(), cfg.FCMEndpoint, cfg.FCMProjectID, brands)
+			if err != nil {
+				log.Fatalf("❌ Failed to initialize FCM (endpoint %s): %v", cfg.FCMEndpoint, err)
+			}
+			log.Printf("✅ FCM client initialized against %s (project %s)", cfg.FCMEndpoint, cfg.FCMProjectID)
+			return client, nil
+		}
+		fcmCredsJSON := os.Getenv("FIREBASE_CREDENTIALS_JSON")
+		if fcmCredsJSON == "" {
+			log.Fatalf("❌ PUSH_DRIVER=fcm requires FIREBASE_CREDENTIALS_JSON or FCM_ENDPOINT")
+		}
+		client, err := fcm.NewFCMClient(context.Background(), []byte(fcmCredsJSON), brands)
+		if err != nil {
+			log.Fatalf("❌ Failed to initialize FCM: %v", err)
+		}
+		log.Println("✅ FCM client initialized")
+		return client, nil
+	case "memory":
+		recorder := fcm.NewRecorder(brands)
+		log.Println("🧪 Push driver: in-memory recorder (nothing leaves this process)")
+		return recorder, recorder
+	case "none":
+		log.Println("⚠️ Push disabled (PUSH_DRIVER=none or no FIREBASE_CREDENTIALS_JSON)")
+		return nil, nil
+	default:
+		log.Fatalf("❌ Unknown PUSH_DRIVER %q (want fcm, memory or none)", driver)
+		return nil, nil
+	}
+}
+
+// smsDriver resolves the configured SMS driver name.
+func smsDriver(cfg *config.Config) string {
+	if cfg.SMSDriver != "" {
+		return strings.ToLower(cfg.SMSDriver)
+	}
+	if cfg.SMSWebhookURL != "" {
+		return "webhook"
+	}
+	return "none"
+}
+
+// newSMSSender builds the configured SMS sender. The recorder is returned as
+// well for the memory driver so its outbox can be inspected.
+func newSMSSender(cfg *config.Config) (sms.Sender, *sms.Recorder) {
+	switch driver := smsDriver(cfg); driver {
+	case "webhook":
+		if cfg.SMSWebhookURL == "" {
+			log.Fatalf("❌ SMS_DRIVER=webhook requires SMS_WEBHOOK_URL")
+		}
+		log.Printf("✅ SMS gateway webhook: %s", cfg.SMSWebhookURL)
+		return sms.NewWebhookSender(cfg.SMSWebhookURL, cfg.SMSWebhookToken), nil
+	case "memory":
+		recorder := sms.NewRecorder()
+		log.Println("🧪 SMS driver: in-memory recorder (nothing leaves this process)")
+		return recorder, recorder
+	case "none":
+		log.Println("⚠️ SMS disabled (SMS_DRIVER=none or no SMS_WEBHOOK_URL); sms escalation steps are skipped")
+		return nil, nil
+	default:
+		log.Fatalf("❌ Unknown SMS_DRIVER %q (want webhook, memory or none)", driver)
+		return nil, nil
+	}
+}
+
 func getEnv(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AckChannelPush  = "push"
+	AckChannelEmail = "email"
+)
+
+// AckPolicy tunes the reminders for a notification that requires
+// acknowledgement. Unset fields fall back to the service defaults.
+type AckPolicy struct {
+	ReminderIntervalHours int      `json:"reminder_interval_hours,omitempty"`
+	MaxReminders          *int     `json:"max_reminders,omitempty"` // 0 = never remind
+	Channels              []string `json:"channels,omitempty"`      // push, email
+}
+
+func (p AckPolicy) Validate() error {
+	if p.ReminderIntervalHours < 0 || p.ReminderIntervalHours > 24*30 {
+		return fmt.Errorf("reminder_interval_hours must be between 1 and 720")
+	}
+	if p.MaxReminders != nil && (*p.MaxReminders < 0 || *p.MaxReminders > 20) {
+		return fmt.Errorf("max_reminders must be between 0 and 20")
+	}
+	for _, ch := range p.Channels {
+		if ch != AckChannelPush && ch != AckChannelEmail {
+			return fmt.Errorf("channels must be %q or %q", AckChannelPush, AckChannelEmail)
+		}
+	}
+	return nil
+}
+
+// ParseAckPolicy decodes a stored (JSONB) policy. Empty input returns the zero policy.
+func ParseAckPolicy(raw []byte) (AckPolicy, error) {
+	var p AckPolicy
+	if len(raw) == 0 || string(raw) == "null" {
+		return p, nil
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return p, fmt.Errorf("invalid ack policy: %w", err)
+	}
+	return p, nil
+}
+
+// AckSummary is one ack-required notification with its acknowledgement tally.
+type AckSummary struct {
+	NotificationID uuid.UUID `json:"notification_id"`
+	Title          string    `json:"title"`
+	CreatedAt      time.Time `json:"created_at"`
+	Recipients     int       `json:"recipients"`
+	Acknowledged   int       `json:"acknowledged"`
+	Outstanding    int       `json:"outstanding"`
+}
+
+// AckView is one recipient's acknowledgement state, for admins.
+type AckView struct {
+	UserID         uuid.UUID  `json:"user_id"`
+	Username       string     `json:"username"`
+	Email          string     `json:"email"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AckDeviceID    *string    `json:"ack_device_id,omitempty"`
+	RemindersSent  int        `json:"reminders_sent"`
+	LastReminderAt *time.Time `json:"last_reminder_at,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CalendarInvite is the latest iCalendar object issued to a user for an event
+// (e.g. a match). UID is stable across updates/cancellations; Sequence bumps.
+type CalendarInvite struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UID       string    `json:"uid" gorm:"type:varchar(255);not null;uniqueIndex:idx_calendar_invites_uid_user"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_calendar_invites_uid_user"`
+	EventKey  string    `json:"event_key" gorm:"type:varchar(100);not null"`
+	Method    string    `json:"method" gorm:"type:varchar(20);not null"` // REQUEST | CANCEL
+	Sequence  int       `json:"sequence" gorm:"not null;default:0"`
+	Summary   string    `json:"summary" gorm:"type:varchar(255)"`
+	JoinURL   *string   `json:"join_url,omitempty" gorm:"type:varchar(500)"`
+	StartsAt  time.Time `json:"starts_at" gorm:"type:timestamptz;not null"`
+	EndsAt    time.Time `json:"ends_at" gorm:"type:timestamptz;not null"`
+	Content   string    `json:"-" gorm:"type:text;not null"` // rendered .ics
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// NotificationCategory groups notifications into the app's inbox tabs.
+type NotificationCategory string
+
+const (
+	CategorySecurity    NotificationCategory = "security"
+	CategoryWallet      NotificationCategory = "wallet"
+	CategorySocial      NotificationCategory = "social"
+	CategoryPromotional NotificationCategory = "promotional"
+	CategoryGeneral     NotificationCategory = "general"
+)
+
+// Categories lists every category, in tab order.
+var Categories = []NotificationCategory{
+	CategorySecurity, CategoryWallet, CategorySocial, CategoryPromotional, CategoryGeneral,
+}
+
+func (c NotificationCategory) Valid() bool {
+	for _, known := range Categories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// categoryByPrefix maps system event keys and email types to a category;
+// the first matching prefix wins.
+var categoryByPrefix = []struct {
+	prefix   string
+	category NotificationCategory
+}{
+	{"user.login.", CategorySecurity},
+	{"account.", CategorySecurity},
+	{"kyc.", CategorySecurity},
+	{"pin.", CategorySecurity},
+	{"profile.email.", CategorySecurity},
+	{"wallet.", CategoryWallet},
+	{"conversion.", CategoryWallet},
+	{"match.", CategorySocial},
+	{"quiz.", CategorySocial},
+	{"post.", CategorySocial},
+	{"profile.", CategorySocial},
+	// email types
+	{"email_verification", CategorySecurity},
+	{"password_reset", CategorySecurity},
+	{"otp", CategorySecurity},
+	{"new_login", CategorySecurity},
+	{"pin_recovery", CategorySecurity},
+	{"deposit_", CategoryWallet},
+	{"withdraw_", CategoryWallet},
+	{"conversion_", CategoryWallet},
+}
+
+// CategoryFor picks a notification's category: an explicit one wins, then the
+// system event key or email type it came from, then its type.
+func CategoryFor(explicit string, notifType NotificationType, source string) NotificationCategory {
+	if c := NotificationCategory(explicit); c.Valid() {
+		return c
+	}
+	for _, m := range categoryByPrefix {
+		if source != "" && strings.HasPrefix(source, m.prefix) {
+			return m.category
+		}
+	}
+	switch notifType {
+	case NotificationTypeSecurity:
+		return CategorySecurity
+	case NotificationTypePromotional, NotificationTypeVideo:
+		return CategoryPromotional
+	}
+	return CategoryGeneral
+}
+
+// UnreadCounts is the badge summary for a user's inbox.
+type UnreadCounts struct {
+	Total      int                          `json:"total"`
+	ByType     map[NotificationType]int     `json:"by_type"`
+	ByCategory map[NotificationCategory]int `json:"by_category"`
+}
+
+// Version identifies the counts for ETags: equal counts, equal version.
+func (c *UnreadCounts) Version() string {
+	b, _ := json.Marshal(c) // map keys are sorted, so this is stable
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:12])
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+const (
+	EscalationChannelEmail = "email"
+	EscalationChannelSMS   = "sms"
+)
+
+// Results recorded for an escalation step.
+const (
+	EscalationSent    = "sent"
+	EscalationFailed  = "failed"
+	EscalationSkipped = "skipped" // no address on file or channel disabled
+)
+
+// EscalationPolicy re-sends a notification over other channels while the
+// recipient leaves it unread. Steps run in order; each one is due
+// AfterMinutes after delivery.
+type EscalationPolicy struct {
+	Steps []EscalationStep `json:"steps"`
+}
+
+type EscalationStep struct {
+	Channel      string `json:"channel"`       // email, sms
+	AfterMinutes int    `json:"after_minutes"` // since delivery
+}
+
+func (p EscalationPolicy) Validate() error {
+	if len(p.Steps) > 5 {
+		return fmt.Errorf("at most 5 steps")
+	}
+	prev := 0
+	for i, step := range p.Steps {
+		if step.Channel != EscalationChannelEmail && step.Channel != EscalationChannelSMS {
+			return fmt.Errorf("step %d: channel must be %q or %q", i, EscalationChannelEmail, EscalationChannelSMS)
+		}
+		if step.AfterMinutes < 1 || step.AfterMinutes > 7*24*60 {
+			return fmt.Errorf("step %d: after_minutes must be between 1 and 10080", i)
+		}
+		if step.AfterMinutes < prev {
+			return fmt.Errorf("step %d: after_minutes must not be earlier than the previous step", i)
+		}
+		prev = step.AfterMinutes
+	}
+	return nil
+}
+
+// ParseEscalationPolicy decodes a stored (JSONB) policy. Empty input returns the zero policy.
+func ParseEscalationPolicy(raw []byte) (EscalationPolicy, error) {
+	var p EscalationPolicy
+	if len(raw) == 0 || string(raw) == "null" {
+		return p, nil
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return p, fmt.Errorf("invalid escalation policy: %w", err)
+	}
+	return p, nil
+}
+
+// EscalationRecord is one escalation step taken for a recipient, appended to
+// NotificationRecipient.EscalationLog.
+type EscalationRecord struct {
+	Step    int       `json:"step"` // 1-based index into the policy's steps
+	Channel string    `json:"channel"`
+	Result  string    `json:"result"`
+	Error   string    `json:"error,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// EscalationView is one recipient's escalation state, for admins.
+type EscalationView struct {
+	UserID      uuid.UUID      `json:"user_id"`
+	Username    string         `json:"username"`
+	Status      string         `json:"status"`
+	DeliveredAt *time.Time     `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time     `json:"read_at,omitempty"`
+	StepsTaken  int            `json:"steps_taken"`
+	EscalatedAt *time.Time     `json:"escalated_at,omitempty"`
+	Steps       datatypes.JSON `json:"steps,omitempty"` // []EscalationRecord
+}
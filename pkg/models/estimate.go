@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Publish targets reported by a dry run.
+const (
+	PublishTargetUsers     = "users"
+	PublishTargetSegment   = "segment"
+	PublishTargetBroadcast = "broadcast"
+)
+
+// Reasons a dry run gives for target users a push would not reach. Each user
+// is counted under the first reason that applies, in this order.
+const (
+	SuppressedPushDisabled = "push_disabled"   // no push sender configured
+	SuppressedNoDevice     = "no_push_device"  // no active push token
+	SuppressedAudience     = "audience_filter" // no device the broadcast's topic push or fallback reaches
+	SuppressedActiveInApp  = "active_in_app"   // in the app right now; gets a silent sync instead
+)
+
+// Suppressions a dry run does NOT apply: user preferences, frequency caps and
+// quiet hours are enforced outside this service, so no user is counted under
+// them and push_reachable is an upper bound. They are only listed in
+// not_modelled.
+const (
+	SuppressedPreferences  = "preferences"
+	SuppressedFrequencyCap = "frequency_cap"
+	SuppressedQuietHours   = "quiet_hours"
+)
+
+// PublishEstimate is what publishing a draft would do right now, without
+// doing it.
+type PublishEstimate struct {
+	NotificationID uuid.UUID        `json:"notification_id"`
+	Target         string           `json:"target"` // users, segment or broadcast
+	SegmentID      *uuid.UUID       `json:"segment_id,omitempty"`
+	Total          int64            `json:"total"`           // distinct target users
+	InApp          int64            `json:"in_app"`          // inbox items that would be created
+	PushReachable  int64            `json:"push_reachable"`  // users a push would alert, before the not_modelled rules
+	EmailReachable int64            `json:"email_reachable"` // users with an email on file (escalations, reminders)
+	Suppressed     map[string]int64 `json:"suppressed"`      // push suppressions by reason
+	NotModelled    []string         `json:"not_modelled"`    // suppressions not applied to push_reachable
+	EstimatedAt    time.Time        `json:"estimated_at"`
+}
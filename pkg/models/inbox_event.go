@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// InboxEvent is one entry in the short-lived log behind the real-time stream.
+// Streams resume from it via Last-Event-ID. Rows are written in the
+// transaction making the change, so IDs can commit out of order.
+type InboxEvent struct {
+	ID             int64          `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID         *uuid.UUID     `json:"user_id,omitempty" gorm:"type:uuid;index"` // nil = every user
+	Type           string         `json:"type" gorm:"type:varchar(50);not null"`
+	OriginDeviceID string         `json:"origin_device_id,omitempty" gorm:"type:varchar(100)"`
+	Payload        datatypes.JSON `json:"payload" gorm:"type:jsonb"`
+	CreatedAt      time.Time      `json:"created_at" gorm:"index"`
+}
+
+// InboxDelta is what changed in a user's inbox since a sync token.
+type InboxDelta struct {
+	Upserts    []*InboxItem      `json:"upserts"`    // new or changed items, full state
+	ReadState  []ReadStateChange `json:"read_state"` // items whose only change was read/unread
+	Tombstones []Tombstone       `json:"tombstones"` // items gone from the inbox
+	SyncToken  string            `json:"sync_token"`
+	HasMore    bool              `json:"has_more"` // call again with SyncToken for the rest
+	Reset      bool              `json:"reset"`    // token missing or too old: reload the inbox, then sync from SyncToken
+}
+
+type ReadStateChange struct {
+	NotificationID uuid.UUID                   `json:"notification_id"`
+	Status         NotificationRecipientStatus `json:"status"`
+	ReadAt         *time.Time                  `json:"read_at,omitempty"`
+}
+
+type Tombstone struct {
+	NotificationID uuid.UUID `json:"notification_id"`
+	DeletedAt      time.Time `json:"deleted_at"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InteractionType is something a user did with a notification on a client.
+type InteractionType string
+
+const (
+	InteractionImpression  InteractionType = "impression"   // shown on screen (inbox row or banner)
+	InteractionOpen        InteractionType = "open"         // tapped, from the inbox or a push
+	InteractionActionClick InteractionType = "action_click" // tapped one of the ActionLinks
+	InteractionDismiss     InteractionType = "dismiss"      // swiped away
+)
+
+func (t InteractionType) Valid() bool {
+	switch t {
+	case InteractionImpression, InteractionOpen, InteractionActionClick, InteractionDismiss:
+		return true
+	}
+	return false
+}
+
+const (
+	InteractionSourceInbox = "inbox"
+	InteractionSourcePush  = "push"
+)
+
+// NotificationInteraction is one client-reported interaction. The latest
+// state per recipient is rolled up onto NotificationRecipient.
+type NotificationInteraction struct {
+	ID             uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	NotificationID uuid.UUID       `json:"notification_id" gorm:"type:uuid;not null;index:idx_interactions_notification_type"`
+	UserID         uuid.UUID       `json:"user_id" gorm:"type:uuid;not null;index;uniqueIndex:idx_interactions_client_event,where:client_event_id IS NOT NULL"`
+	Type           InteractionType `json:"type" gorm:"type:varchar(20);not null;index:idx_interactions_notification_type"`
+	ActionIndex    *int            `json:"action_index,omitempty"` // action_click only
+	Source         string          `json:"source" gorm:"type:varchar(20);not null;default:'inbox'"`
+	DeviceID       *string         `json:"device_id,omitempty" gorm:"type:varchar(100)"`
+	ClientEventID  *string         `json:"client_event_id,omitempty" gorm:"type:varchar(100);uniqueIndex:idx_interactions_client_event,where:client_event_id IS NOT NULL"` // dedups client retries
+	OccurredAt     time.Time       `json:"occurred_at" gorm:"type:timestamptz;not null"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// InteractionEvent is one event as reported by a client. Push opens may send
+// the push's data payload instead of notification_id.
+type InteractionEvent struct {
+	NotificationID uuid.UUID         `json:"notification_id"`
+	Type           InteractionType   `json:"type"`
+	ActionIndex    *int              `json:"action_index,omitempty"`
+	Source         string            `json:"source,omitempty"`
+	EventID        string            `json:"event_id,omitempty"`
+	OccurredAt     *time.Time        `json:"occurred_at,omitempty"`
+	PushData       map[string]string `json:"push_data,omitempty"`
+}
+
+// InteractionSummary aggregates a notification's interactions for admins.
+// Counts are unique users; Events is the raw number of events per type.
+type InteractionSummary struct {
+	NotificationID uuid.UUID               `json:"notification_id"`
+	Recipients     int                     `json:"recipients"`
+	Seen           int                     `json:"seen"`
+	Opened         int                     `json:"opened"`
+	Clicked        int                     `json:"clicked"`
+	Dismissed      int                     `json:"dismissed"`
+	ActionClicks   map[int]int             `json:"action_clicks"` // action index -> unique users
+	Events         map[InteractionType]int `json:"events"`
+}
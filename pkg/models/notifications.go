@@ -21,14 +21,22 @@ const (
 	NotificationTypeVideo          NotificationType = "video"
 )
 
+// NotificationSearchVector is the full-text document of a notification:
+// heading weighs most, then title, then message. The search index is on this
+// exact expression, so queries must use it verbatim to hit the index.
+const NotificationSearchVector = `(setweight(to_tsvector('english'::regconfig, coalesce(heading, '')), 'A') || ` +
+	`setweight(to_tsvector('english'::regconfig, coalesce(title, '')), 'B') || ` +
+	`setweight(to_tsvector('english'::regconfig, coalesce(message, '')), 'C'))`
+
 // Notification is the template/draft/published notification — *one per campaign*.
 type Notification struct {
-	ID        uuid.UUID        `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	CreatorID uuid.UUID        `json:"creator_id" gorm:"type:uuid;index;not null"` // admin/gamer who created it
-	Type      NotificationType `json:"type" gorm:"type:varchar(30);not null;default:'info'"`
-	Heading   string           `json:"heading" gorm:"type:varchar(100);not null"`
-	Title     string           `json:"title" gorm:"type:varchar(100);not null"`
-	Message   string           `json:"message" gorm:"type:text;not null"`
+	ID        uuid.UUID            `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	CreatorID uuid.UUID            `json:"creator_id" gorm:"type:uuid;index;not null"` // admin/gamer who created it
+	Type      NotificationType     `json:"type" gorm:"type:varchar(30);not null;default:'info'"`
+	Category  NotificationCategory `json:"category" gorm:"type:varchar(30);not null;default:'general';index"`
+	Heading   string               `json:"heading" gorm:"type:varchar(100);not null"`
+	Title     string               `json:"title" gorm:"type:varchar(100);not null"`
+	Message   string               `json:"message" gorm:"type:text;not null"`
 	// Media
 	ContentImageURL *string        `json:"content_image_url,omitempty" gorm:"type:varchar(500)"` // external
 	ThumbnailURL    *string        `json:"thumbnail_url,omitempty" gorm:"type:varchar(500)"`     // uploaded thumbnail
@@ -37,10 +45,29 @@ type Notification struct {
 	ContentLink  *string        `json:"content_link,omitempty" gorm:"type:varchar(500)"`
 	ActionLinks  datatypes.JSON `json:"action_links,omitempty" gorm:"type:jsonb"` // []ActionLink
 	Metadata     datatypes.JSON `json:"metadata,omitempty" gorm:"type:jsonb"`
+	PushOptions  datatypes.JSON `json:"push_options,omitempty" gorm:"type:jsonb"` // PushOptions overrides on top of the type defaults
+	// Acknowledgement
+	RequiresAck bool           `json:"requires_ack" gorm:"not null;default:false"`
+	AckPolicy   datatypes.JSON `json:"ack_policy,omitempty" gorm:"type:jsonb"` // AckPolicy overrides on top of the service defaults
+	// Escalation
+	EscalationPolicy datatypes.JSON `json:"escalation_policy,omitempty" gorm:"type:jsonb"` // EscalationPolicy while a recipient leaves it unread
+	// Audience
+	SegmentID    *uuid.UUID `json:"segment_id,omitempty" gorm:"type:uuid;index"` // saved segment it targets; resolved at send time
+	AudienceSize *int       `json:"audience_size,omitempty"`                     // segment members it was sent to
+	// Push audience ({"platforms","locales"}) when published as a broadcast
+	BroadcastAudience datatypes.JSON `json:"broadcast_audience,omitempty" gorm:"type:jsonb"`
 	// Lifecycle
 	IsDraft     bool       `json:"is_draft" gorm:"not null;default:true"`
 	ScheduledAt *time.Time `json:"scheduled_at,omitempty" gorm:"index"`
 	DeliveredAt *time.Time `json:"delivered_at,omitempty"` // when *first* sent (or nil if draft/scheduled)
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`   // stale after this: hidden from feeds, actions disabled
+	// Set once the expiry sweeper has marked every recipient expired
+	ExpirySweptAt *time.Time `json:"-"`
+	// Recall
+	RetractedAt      *time.Time `json:"retracted_at,omitempty"`
+	RetractedBy      *uuid.UUID `json:"retracted_by,omitempty" gorm:"type:uuid"`
+	RetractionReason *string    `json:"retraction_reason,omitempty" gorm:"type:text"`
+	CorrectionOf     *uuid.UUID `json:"correction_of,omitempty" gorm:"type:uuid;index"` // the recalled notification this one corrects
 	// Timestamps
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -67,6 +94,7 @@ type NotificationRequest struct {
 	Title           string       `json:"title" validate:"required"`
 	Message         string       `json:"message" validate:"required"`
 	Type            string       `json:"type,omitempty"`
+	Category        string       `json:"category,omitempty"` // derived from type/event when empty
 	CreatorID       *uuid.UUID   `json:"creator_id,omitempty"`
 	UserID          *uuid.UUID   `json:"user_id,omitempty"` // DEPRECATED in new logic (for backward compat only)
 	ContentLink     *string      `json:"content_link,omitempty"`
@@ -76,6 +104,12 @@ type NotificationRequest struct {
 	ThumbnailURL    *string      `json:"thumbnail_url,omitempty"`
 	MediaURLs       []string     `json:"media_urls,omitempty"`
 	ScheduledAt     *time.Time   `json:"scheduled_at,omitempty"`
+	ExpiresAt       *time.Time   `json:"expires_at,omitempty"`
+	PushOptions     *PushOptions `json:"push_options,omitempty"`
+	RequiresAck     bool         `json:"requires_ack,omitempty"`
+	AckPolicy       *AckPolicy   `json:"ack_policy,omitempty"`
+
+	EscalationPolicy *EscalationPolicy `json:"escalation_policy,omitempty"`
 }
 
 // ✅ Renamed & enhanced: per-user delivery state
@@ -97,6 +131,25 @@ type NotificationRecipient struct {
 	ReadAt         *time.Time                  `gorm:"type:timestamptz" json:"read_at,omitempty"`
 	ErrorMessage   *string                     `gorm:"type:text" json:"error_message,omitempty"`
 	DeviceID       *string                     `gorm:"type:varchar(100)" json:"device_id,omitempty"`
+	ArchivedAt     *time.Time                  `gorm:"type:timestamptz" json:"archived_at,omitempty"`
+	PinnedAt       *time.Time                  `gorm:"type:timestamptz" json:"pinned_at,omitempty"`
+	SnoozedUntil   *time.Time                  `gorm:"type:timestamptz;index:idx_recipients_snoozed,where:snoozed_until IS NOT NULL" json:"snoozed_until,omitempty"`
+	SnoozeRepush   bool                        `gorm:"not null;default:false" json:"snooze_repush,omitempty"` // push again when the snooze ends
+	MarkedUnreadAt *time.Time                  `gorm:"type:timestamptz" json:"marked_unread_at,omitempty"`    // overrides an older read watermark
+	AcknowledgedAt *time.Time                  `gorm:"type:timestamptz" json:"acknowledged_at,omitempty"`
+	AckDeviceID    *string                     `gorm:"type:varchar(100)" json:"ack_device_id,omitempty"`
+	AckReminders   int                         `gorm:"not null;default:0" json:"ack_reminders,omitempty"` // reminders sent while unacknowledged
+	AckRemindedAt  *time.Time                  `gorm:"type:timestamptz" json:"ack_reminded_at,omitempty"` // latest reminder
+	Escalations    int                         `gorm:"not null;default:0" json:"escalations,omitempty"`   // escalation steps taken while unread
+	EscalatedAt    *time.Time                  `gorm:"type:timestamptz" json:"escalated_at,omitempty"`    // latest step
+	EscalationLog  datatypes.JSON              `gorm:"type:jsonb" json:"escalation_log,omitempty"`        // []EscalationRecord
+	SeenAt         *time.Time                  `gorm:"type:timestamptz" json:"seen_at,omitempty"`         // interaction rollups: first occurrence of each
+	OpenedAt       *time.Time                  `gorm:"type:timestamptz" json:"opened_at,omitempty"`
+	ClickedAt      *time.Time                  `gorm:"type:timestamptz" json:"clicked_at,omitempty"`
+	ClickedAction  *int                        `json:"clicked_action,omitempty"` // index of the latest ActionLink clicked
+	DismissedAt    *time.Time                  `gorm:"type:timestamptz" json:"dismissed_at,omitempty"`
+	ExpiredAt      *time.Time                  `gorm:"type:timestamptz" json:"expired_at,omitempty"`   // set by the expiry sweeper
+	RetractedAt    *time.Time                  `gorm:"type:timestamptz" json:"retracted_at,omitempty"` // recalled: hidden everywhere, kept for audit
 	CreatedAt      time.Time                   `gorm:"not null" json:"created_at"`
 	UpdatedAt      time.Time                   `gorm:"not null" json:"updated_at"`
 }
@@ -109,6 +162,11 @@ type ReceiptView struct {
 	Status      string     `json:"status"`
 	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
 	ReadAt      *time.Time `json:"read_at,omitempty"`
+	SeenAt      *time.Time `json:"seen_at,omitempty"`
+	OpenedAt    *time.Time `json:"opened_at,omitempty"`
+	ClickedAt   *time.Time `json:"clicked_at,omitempty"`
+	DismissedAt *time.Time `json:"dismissed_at,omitempty"`
+	RetractedAt *time.Time `json:"retracted_at,omitempty"`
 }
 
 
@@ -123,6 +181,11 @@ type SystemNotificationTemplate struct {
     Type         string         `json:"type"`
     Icon         string         `json:"icon"`
     TemplateVars datatypes.JSON `json:"template_vars" gorm:"type:jsonb"`
+    ContextSchema datatypes.JSON `json:"context_schema,omitempty" gorm:"type:jsonb"` // JSON-Schema-style declaration of Variables
+    PushOptions  datatypes.JSON `json:"push_options,omitempty" gorm:"type:jsonb"` // PushOptions; collapse_key may use {{vars}}
+    RequiresAck  bool           `json:"requires_ack" gorm:"not null;default:false"`
+    AckPolicy    datatypes.JSON `json:"ack_policy,omitempty" gorm:"type:jsonb"` // AckPolicy for notifications from this template
+    EscalationPolicy datatypes.JSON `json:"escalation_policy,omitempty" gorm:"type:jsonb"` // EscalationPolicy for notifications from this template
     CreatedAt    time.Time      `json:"created_at"`
     UpdatedAt    time.Time      `json:"updated_at"`
 }
@@ -137,7 +200,24 @@ type NotificationEvent struct {
 }
 
 type DeliveryInfo struct {
-	Status      NotificationRecipientStatus `json:"status"`
-	DeliveredAt *time.Time                  `json:"delivered_at,omitempty"`
-	ReadAt      *time.Time                  `json:"read_at,omitempty"`
+	Status       NotificationRecipientStatus `json:"status"`
+	DeliveredAt  *time.Time                  `json:"delivered_at,omitempty"`
+	ReadAt       *time.Time                  `json:"read_at,omitempty"`
+	Archived     bool                        `json:"archived"`
+	ArchivedAt   *time.Time                  `json:"archived_at,omitempty"`
+	Pinned       bool                        `json:"pinned"`
+	PinnedAt     *time.Time                  `json:"pinned_at,omitempty"`
+	Snoozed      bool                        `json:"snoozed"`
+	SnoozedUntil *time.Time                  `json:"snoozed_until,omitempty"`
+	Acknowledged bool                        `json:"acknowledged"`
+	AckedAt      *time.Time                  `json:"acknowledged_at,omitempty"`
+	Expired      bool                        `json:"expired"` // action links are withheld once expired
+	ExpiredAt    *time.Time                  `json:"expired_at,omitempty"`
+}
+
+// InboxItem is one entry of a user's feed: the notification plus this
+// recipient's state (v3 user API).
+type InboxItem struct {
+	Notification *Notification `json:"notification"`
+	Delivery     DeliveryInfo  `json:"delivery"`
 }
\ No newline at end of file
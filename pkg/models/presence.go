@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PresenceActive     = "active"     // app in the foreground
+	PresenceBackground = "background" // app backgrounded or closed
+)
+
+// DevicePresence is the latest heartbeat from one client device.
+type DevicePresence struct {
+	UserID     uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey"`
+	DeviceID   string    `json:"device_id" gorm:"type:varchar(100);primaryKey"`
+	State      string    `json:"state" gorm:"type:varchar(20);not null"`
+	LastSeenAt time.Time `json:"last_seen_at" gorm:"type:timestamptz;not null;index"`
+}
+
+func (DevicePresence) TableName() string {
+	return "device_presence"
+}
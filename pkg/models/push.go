@@ -0,0 +1,158 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// PushOptions controls how a push is delivered (not what it says). Defaults
+// come from the notification type; system templates and campaigns override
+// individual fields.
+type PushOptions struct {
+	ChannelID         string `json:"channel_id,omitempty"`         // Android notification channel
+	Priority          string `json:"priority,omitempty"`           // high | normal
+	TTLSeconds        *int   `json:"ttl_seconds,omitempty"`        // drop undelivered pushes after this long (0 = now or never)
+	CollapseKey       string `json:"collapse_key,omitempty"`       // newer pushes replace older ones with the same key; templates may use {{vars}}
+	Sound             string `json:"sound,omitempty"`              // "default", a bundled sound file, or "none"
+	InterruptionLevel string `json:"interruption_level,omitempty"` // iOS: passive | active | time-sensitive | critical
+}
+
+const (
+	PushPriorityHigh   = "high"
+	PushPriorityNormal = "normal"
+	PushSoundNone      = "none"
+
+	MaxCollapseKeyBytes = 64
+)
+
+func ttl(seconds int) *int { return &seconds }
+
+// pushOptionsByType are the per-type delivery defaults.
+var pushOptionsByType = map[NotificationType]PushOptions{
+	NotificationTypeSecurity: {
+		ChannelID:         "security",
+		Priority:          PushPriorityHigh,
+		TTLSeconds:        ttl(3600),
+		Sound:             "default",
+		InterruptionLevel: "time-sensitive",
+	},
+	NotificationTypeActionRequired: {
+		ChannelID:         "actions",
+		Priority:          PushPriorityHigh,
+		Sound:             "default",
+		InterruptionLevel: "time-sensitive",
+	},
+	NotificationTypeWarning: {
+		ChannelID:         "alerts",
+		Priority:          PushPriorityHigh,
+		Sound:             "default",
+		InterruptionLevel: "active",
+	},
+	NotificationTypeSuccess: {
+		ChannelID:         "activity",
+		Priority:          PushPriorityHigh,
+		Sound:             "default",
+		InterruptionLevel: "active",
+	},
+	NotificationTypeInfo: {
+		ChannelID:         "activity",
+		Priority:          PushPriorityHigh,
+		Sound:             "default",
+		InterruptionLevel: "active",
+	},
+	NotificationTypeGeneric: {
+		ChannelID:         "general",
+		Priority:          PushPriorityNormal,
+		Sound:             "default",
+		InterruptionLevel: "active",
+	},
+	NotificationTypePromotional: {
+		ChannelID:         "marketing",
+		Priority:          PushPriorityNormal,
+		TTLSeconds:        ttl(24 * 3600),
+		Sound:             PushSoundNone,
+		InterruptionLevel: "passive",
+	},
+	NotificationTypeVideo: {
+		ChannelID:         "media",
+		Priority:          PushPriorityNormal,
+		TTLSeconds:        ttl(24 * 3600),
+		Sound:             PushSoundNone,
+		InterruptionLevel: "passive",
+	},
+}
+
+// PushOptionsForType returns the delivery defaults for a notification type.
+func PushOptionsForType(t NotificationType) PushOptions {
+	return pushOptionsByType[t]
+}
+
+// Merge returns o with every field set in over replacing the default.
+func (o PushOptions) Merge(over PushOptions) PushOptions {
+	if over.ChannelID != "" {
+		o.ChannelID = over.ChannelID
+	}
+	if over.Priority != "" {
+		o.Priority = over.Priority
+	}
+	if over.TTLSeconds != nil {
+		o.TTLSeconds = over.TTLSeconds
+	}
+	if over.CollapseKey != "" {
+		o.CollapseKey = over.CollapseKey
+	}
+	if over.Sound != "" {
+		o.Sound = over.Sound
+	}
+	if over.InterruptionLevel != "" {
+		o.InterruptionLevel = over.InterruptionLevel
+	}
+	return o
+}
+
+// Validate rejects values FCM/APNs would refuse.
+func (o PushOptions) Validate() error {
+	switch o.Priority {
+	case "", PushPriorityHigh, PushPriorityNormal:
+	default:
+		return fmt.Errorf("priority must be %q or %q", PushPriorityHigh, PushPriorityNormal)
+	}
+	switch o.InterruptionLevel {
+	case "", "passive", "active", "time-sensitive", "critical":
+	default:
+		return fmt.Errorf("interruption_level must be one of: passive, active, time-sensitive, critical")
+	}
+	if o.TTLSeconds != nil && (*o.TTLSeconds < 0 || *o.TTLSeconds > 28*24*3600) {
+		return fmt.Errorf("ttl_seconds must be between 0 and 2419200 (28 days)")
+	}
+	if len(o.CollapseKey) > MaxCollapseKeyBytes {
+		return fmt.Errorf("collapse_key must be at most 64 bytes")
+	}
+	return nil
+}
+
+// TruncateCollapseKey shortens a rendered collapse key to MaxCollapseKeyBytes
+// without splitting a UTF-8 character.
+func TruncateCollapseKey(key string) string {
+	if len(key) <= MaxCollapseKeyBytes {
+		return key
+	}
+	cut := MaxCollapseKeyBytes
+	for cut > 0 && !utf8.RuneStart(key[cut]) {
+		cut--
+	}
+	return key[:cut]
+}
+
+// ParsePushOptions decodes stored (JSONB) push options. Empty input returns zero options.
+func ParsePushOptions(raw []byte) (PushOptions, error) {
+	var o PushOptions
+	if len(raw) == 0 || string(raw) == "null" {
+		return o, nil
+	}
+	if err := json.Unmarshal(raw, &o); err != nil {
+		return o, fmt.Errorf("invalid push options: %w", err)
+	}
+	return o, o.Validate()
+}
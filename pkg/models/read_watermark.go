@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReadWatermark marks everything a user had delivered at or before ReadUpTo
+// as read, without touching the recipient rows. Items marked unread after
+// UpdatedAt stay unread.
+type ReadWatermark struct {
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey"`
+	ReadUpTo  time.Time `json:"read_up_to" gorm:"type:timestamptz;not null"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"type:timestamptz;not null"`
+}
+
+func (ReadWatermark) TableName() string {
+	return "inbox_read_watermarks"
+}
@@ -0,0 +1,264 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Segment match modes.
+const (
+	SegmentMatchAll = "all" // every rule must hold
+	SegmentMatchAny = "any" // at least one rule must hold
+)
+
+// Segment rule operators.
+const (
+	SegmentOpEq            = "eq"
+	SegmentOpNeq           = "neq"
+	SegmentOpIn            = "in"
+	SegmentOpNotIn         = "not_in"
+	SegmentOpContains      = "contains"
+	SegmentOpStartsWith    = "starts_with"
+	SegmentOpExists        = "exists"
+	SegmentOpNotExists     = "not_exists"
+	SegmentOpGt            = "gt"
+	SegmentOpGte           = "gte"
+	SegmentOpLt            = "lt"
+	SegmentOpLte           = "lte"
+	SegmentOpWithinDays    = "within_days"     // timestamp in the last N days
+	SegmentOpOlderThanDays = "older_than_days" // timestamp before the last N days (or never)
+	SegmentOpOccurred      = "occurred"        // the user got the event (optionally within_days)
+	SegmentOpNotOccurred   = "not_occurred"
+)
+
+// segmentFieldKind groups fields that take the same operators and values.
+type segmentFieldKind int
+
+const (
+	segmentText segmentFieldKind = iota
+	segmentDeviceText
+	segmentVersion
+	segmentTime
+	segmentNumber
+	segmentEvent
+)
+
+// segmentFields are the fields a rule can test. user.* are synced profile
+// attributes; device.* hold when any active push token matches (neq/not_in
+// mean no active token matches); engagement.* cover the last
+// SegmentEngagementDays; event is a system event the user was notified of.
+var segmentFields = map[string]segmentFieldKind{
+	"user.username":          segmentText,
+	"user.email":             segmentText,
+	"user.first_name":        segmentText,
+	"user.last_name":         segmentText,
+	"user.phone":             segmentText,
+	"user.created_at":        segmentTime,
+	"device.platform":        segmentDeviceText,
+	"device.locale":          segmentDeviceText,
+	"device.app_version":     segmentVersion,
+	"device.last_seen_at":    segmentTime,
+	"engagement.read_rate":   segmentNumber, // 0..1
+	"engagement.received":    segmentNumber, // notifications delivered
+	"engagement.last_active": segmentTime,
+	"event":                  segmentEvent,
+}
+
+var segmentOps = map[segmentFieldKind][]string{
+	segmentText:       {SegmentOpEq, SegmentOpNeq, SegmentOpIn, SegmentOpNotIn, SegmentOpContains, SegmentOpStartsWith, SegmentOpExists, SegmentOpNotExists},
+	segmentDeviceText: {SegmentOpEq, SegmentOpNeq, SegmentOpIn, SegmentOpNotIn, SegmentOpStartsWith},
+	segmentVersion:    {SegmentOpEq, SegmentOpGt, SegmentOpGte, SegmentOpLt, SegmentOpLte},
+	segmentTime:       {SegmentOpWithinDays, SegmentOpOlderThanDays},
+	segmentNumber:     {SegmentOpGt, SegmentOpGte, SegmentOpLt, SegmentOpLte},
+	segmentEvent:      {SegmentOpOccurred, SegmentOpNotOccurred},
+}
+
+const (
+	// SegmentEngagementDays is the look-back window for engagement.* rules.
+	SegmentEngagementDays = 90
+	maxSegmentRules       = 20
+)
+
+// SegmentRule is one condition on a user. Value's type depends on the
+// operator: a string, a list of strings (in/not_in), a number, a day count
+// (within_days/older_than_days), a dotted version, or an event key.
+type SegmentRule struct {
+	Field      string          `json:"field"`
+	Op         string          `json:"op"`
+	Value      json.RawMessage `json:"value,omitempty"`
+	WithinDays int             `json:"within_days,omitempty"` // event rules only; 0 = ever
+}
+
+// SegmentDefinition is a set of rules matched against synced users.
+type SegmentDefinition struct {
+	Match string        `json:"match,omitempty"` // all (default) or any
+	Rules []SegmentRule `json:"rules"`
+}
+
+func (d SegmentDefinition) Validate() error {
+	if d.Match != "" && d.Match != SegmentMatchAll && d.Match != SegmentMatchAny {
+		return fmt.Errorf("match must be %q or %q", SegmentMatchAll, SegmentMatchAny)
+	}
+	if len(d.Rules) == 0 {
+		return fmt.Errorf("at least one rule is required")
+	}
+	if len(d.Rules) > maxSegmentRules {
+		return fmt.Errorf("at most %d rules are allowed", maxSegmentRules)
+	}
+	for i, r := range d.Rules {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("rules[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r SegmentRule) Validate() error {
+	kind, ok := segmentFields[r.Field]
+	if !ok {
+		return fmt.Errorf("unknown field %q", r.Field)
+	}
+	allowed := false
+	for _, op := range segmentOps[kind] {
+		if op == r.Op {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("op must be one of %s for %s", strings.Join(segmentOps[kind], ", "), r.Field)
+	}
+	if r.WithinDays < 0 || r.WithinDays > 3650 || (r.WithinDays > 0 && kind != segmentEvent) {
+		return fmt.Errorf("within_days must be between 1 and 3650, on event rules only")
+	}
+	var err error
+	switch {
+	case r.Op == SegmentOpExists || r.Op == SegmentOpNotExists:
+	case r.Op == SegmentOpIn || r.Op == SegmentOpNotIn:
+		var list []string
+		if list, err = r.Strings(); err == nil && len(list) == 0 {
+			err = fmt.Errorf("value must be a non-empty list")
+		}
+	case kind == segmentVersion:
+		_, err = r.Version()
+	case kind == segmentTime:
+		var days int
+		if days, err = r.Days(); err == nil && (days < 1 || days > 3650) {
+			err = fmt.Errorf("value must be between 1 and 3650 days")
+		}
+	case kind == segmentNumber:
+		var n float64
+		if n, err = r.Number(); err == nil && (n < 0 || (r.Field == "engagement.read_rate" && n > 1)) {
+			err = fmt.Errorf("value out of range")
+		}
+	default:
+		var s string
+		if s, err = r.Text(); err == nil && s == "" {
+			err = fmt.Errorf("value is required")
+		}
+	}
+	return err
+}
+
+// Text returns a string value.
+func (r SegmentRule) Text() (string, error) {
+	var s string
+	if err := json.Unmarshal(r.Value, &s); err != nil {
+		return "", fmt.Errorf("value must be a string")
+	}
+	return s, nil
+}
+
+// Strings returns a list value.
+func (r SegmentRule) Strings() ([]string, error) {
+	var list []string
+	if err := json.Unmarshal(r.Value, &list); err != nil {
+		return nil, fmt.Errorf("value must be a list of strings")
+	}
+	return list, nil
+}
+
+// Number returns a numeric value.
+func (r SegmentRule) Number() (float64, error) {
+	var n float64
+	if err := json.Unmarshal(r.Value, &n); err != nil {
+		return 0, fmt.Errorf("value must be a number")
+	}
+	return n, nil
+}
+
+// Days returns a whole number of days.
+func (r SegmentRule) Days() (int, error) {
+	var n int
+	if err := json.Unmarshal(r.Value, &n); err != nil {
+		return 0, fmt.Errorf("value must be a whole number of days")
+	}
+	return n, nil
+}
+
+// Version returns a dotted numeric version ("2.14.1") as its parts.
+func (r SegmentRule) Version() ([]int, error) {
+	s, err := r.Text()
+	if err != nil {
+		return nil, fmt.Errorf("value must be a version like \"2.14.1\"")
+	}
+	parts := strings.Split(s, ".")
+	version := make([]int, len(parts))
+	for i, p := range parts {
+		if version[i], err = strconv.Atoi(p); err != nil || version[i] < 0 {
+			return nil, fmt.Errorf("value must be a version like \"2.14.1\"")
+		}
+	}
+	return version, nil
+}
+
+// ParseSegmentDefinition decodes a stored (JSONB) definition.
+func ParseSegmentDefinition(raw []byte) (SegmentDefinition, error) {
+	var d SegmentDefinition
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return d, fmt.Errorf("invalid segment definition: %w", err)
+	}
+	return d, nil
+}
+
+// Segment is a saved audience, evaluated against users whenever it is
+// previewed or sent to.
+type Segment struct {
+	ID            uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	Name          string         `json:"name" gorm:"type:varchar(100);not null;uniqueIndex:idx_segments_name,where:deleted_at IS NULL"`
+	Description   *string        `json:"description,omitempty" gorm:"type:text"`
+	Definition    datatypes.JSON `json:"definition" gorm:"type:jsonb;not null"` // SegmentDefinition
+	CreatorID     uuid.UUID      `json:"creator_id" gorm:"type:uuid;not null"`
+	LastCount     *int           `json:"last_count,omitempty"` // size at the last preview or send
+	LastCountedAt *time.Time     `json:"last_counted_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// SegmentRequest creates or replaces a saved segment.
+type SegmentRequest struct {
+	Name        string            `json:"name"`
+	Description *string           `json:"description,omitempty"`
+	Definition  SegmentDefinition `json:"definition"`
+}
+
+// SegmentPreview is a segment's current size with a few sample members.
+type SegmentPreview struct {
+	Count  int64         `json:"count"`
+	Sample []SegmentUser `json:"sample"`
+}
+
+// SegmentUser is one member in a segment preview.
+type SegmentUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
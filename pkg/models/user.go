@@ -16,6 +16,7 @@ type User struct {
 	FirstName         *string `json:"first_name,omitempty" gorm:"type:varchar(100)"`
 	LastName          *string `json:"last_name,omitempty" gorm:"type:varchar(100)"`
 	ProfilePictureURL *string `json:"profile_picture_url,omitempty" gorm:"type:varchar(500)"`
+	Phone             *string `json:"phone,omitempty" gorm:"type:varchar(32)"` // E.164, for SMS escalation
 	UpdatedAt         time.Time `json:"updated_at"`
 	CreatedAt         time.Time `json:"created_at"`
 	DeletedAt         gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
@@ -27,12 +28,47 @@ func (User) TableName() string {
 }
 
 type FCMToken struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	UserID    uuid.UUID `gorm:"type:uuid;index"`
-	DeviceID  string    `gorm:"index;not null"` // e.g., device UUID or push ID
-	Token     string    `gorm:"not null"`       // FCM registration token
-	Platform  string    `gorm:"type:varchar(20);default:'unknown'"` // e.g., "android", "ios", "web"
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"` // soft delete for revoked tokens
-}
\ No newline at end of file
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID         uuid.UUID  `gorm:"type:uuid;index"`
+	DeviceID       string     `gorm:"index;not null"`                     // e.g., device UUID or push ID
+	Token          string     `gorm:"not null"`                           // FCM registration token
+	Platform       string     `gorm:"type:varchar(20);default:'unknown'"` // e.g., "android", "ios", "web"
+	AppVersion     string     `gorm:"type:varchar(50)"`
+	Locale         string     `gorm:"type:varchar(20)"`                      // e.g., "fr-CA"; drives the locale topic
+	LastSeenAt     *time.Time `gorm:"type:timestamptz;index"`                // last registration/refresh from the device
+	RevokedReason  *string    `gorm:"type:varchar(50)"`                      // why the token was soft-deleted (unregistered, expired, ...)
+	Topics         string     `gorm:"type:varchar(255);not null;default:''"` // comma-separated FCM topics the token is subscribed to
+	TopicsSyncedAt *time.Time `gorm:"type:timestamptz;index"`                // nil = subscriptions need reconciling
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"` // soft delete for revoked tokens
+
+	// Topic sync retries: attempts since the device last changed, and when
+	// the next one is due (nil = right away)
+	TopicSyncAttempts int        `gorm:"not null;default:0"`
+	TopicSyncNextAt   *time.Time `gorm:"type:timestamptz"`
+}
+
+// Reasons recorded on FCMToken.RevokedReason besides FCM's own (see fcm package).
+const (
+	TokenRevokedByUser     = "user_unregistered"
+	TokenRevokedExpired    = "expired"
+	TokenRevokedReassigned = "reassigned" // the same token was registered for another device
+)
+
+// DeviceView is the admin view of a registered push device (token masked).
+type DeviceView struct {
+	ID            uuid.UUID  `json:"id"`
+	DeviceID      string     `json:"device_id"`
+	Platform      string     `json:"platform"`
+	AppVersion    string     `json:"app_version,omitempty"`
+	Locale        string     `json:"locale,omitempty"`
+	Topics        []string   `json:"topics,omitempty"`
+	TokenSuffix   string     `json:"token_suffix"`
+	Active        bool       `json:"active"`
+	LastSeenAt    *time.Time `json:"last_seen_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason *string    `json:"revoked_reason,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}